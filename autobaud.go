@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Serial settings tried by --auto-baud, most common first.
+var (
+	autoBaudRates    = []int{19200, 9600, 38400, 57600, 115200, 4800, 2400, 1200}
+	autoBaudParities = []string{"even", "none", "odd"}
+)
+
+// runAutoBaud probes the target unit with every common baudrate/parity
+// combination and reports the first one that gets a valid answer.
+func (m *ModbusCLI) runAutoBaud() error {
+	startRef := m.startRef()
+
+	for _, parity := range autoBaudParities {
+		for _, rate := range autoBaudRates {
+			m.config.Baudrate = rate
+			m.config.Parity = parity
+			fmt.Printf("Trying %d-%d%c%d... ", rate, m.config.Databits, m.getParityChar(), m.config.Stopbits)
+
+			if err := m.setupClient(); err != nil {
+				return err
+			}
+			if err := m.client.Open(); err != nil {
+				fmt.Println()
+				return fmt.Errorf("failed to open %s: %v", m.config.Device, err)
+			}
+			m.client.SetUnitId(uint8(m.config.SlaveID))
+
+			err := m.probe(startRef)
+			m.client.Close()
+
+			// An exception response still proves the serial settings are right
+			if err == nil || isExceptionResponse(err) {
+				fmt.Println("response")
+				fmt.Printf("Unit %d answers at %d-%d%c%d (use -b %d -P %s -d %d -s %d)\n",
+					m.config.SlaveID, rate, m.config.Databits, m.getParityChar(), m.config.Stopbits,
+					rate, parity, m.config.Databits, m.config.Stopbits)
+				return nil
+			}
+			fmt.Printf("no valid response (%v)\n", err)
+		}
+	}
+
+	return fmt.Errorf("unit %d did not answer at any tested baudrate/parity", m.config.SlaveID)
+}
+
+// probe issues a single one-value read of the configured data type.
+func (m *ModbusCLI) probe(startRef int) error {
+	return probeRead(m.client, m.config.DataType, startRef)
+}
+
+// probeRead issues a single one-value read of the given data type.
+func probeRead(client *modbus.ModbusClient, dataType string, startRef int) error {
+	var err error
+	switch {
+	case dataType == "0":
+		_, err = client.ReadCoils(uint16(startRef), 1)
+	case dataType == "1":
+		_, err = client.ReadDiscreteInputs(uint16(startRef), 1)
+	case strings.HasPrefix(dataType, "3"):
+		_, err = client.ReadRegisters(uint16(startRef), 1, modbus.INPUT_REGISTER)
+	default:
+		_, err = client.ReadRegisters(uint16(startRef), 1, modbus.HOLDING_REGISTER)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Bounds applied to an auto-tuned timeout, matching --timeout validation.
+const (
+	minTunedTimeout = 10 * time.Millisecond
+	maxTunedTimeout = 10 * time.Second
+)
+
+// timeoutTuner collects response latencies during a warm-up phase and derives
+// a timeout of twice their 99th percentile.
+type timeoutTuner struct {
+	warmup  int
+	samples []time.Duration
+	applied bool
+}
+
+func newTimeoutTuner(warmup int) *timeoutTuner {
+	return &timeoutTuner{warmup: warmup, samples: make([]time.Duration, 0, warmup)}
+}
+
+// add records the latency of one successful request.
+func (t *timeoutTuner) add(latency time.Duration) {
+	if len(t.samples) < t.warmup {
+		t.samples = append(t.samples, latency)
+	}
+}
+
+// ready reports whether the warm-up is complete and the timeout not yet applied.
+func (t *timeoutTuner) ready() bool {
+	return !t.applied && len(t.samples) >= t.warmup
+}
+
+// tuned returns the p99 latency of the warm-up samples and the timeout derived
+// from it.
+func (t *timeoutTuner) tuned() (p99, timeout time.Duration) {
+	sorted := append([]time.Duration(nil), t.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// Nearest-rank percentile
+	rank := (len(sorted)*99 + 99) / 100
+	p99 = sorted[rank-1]
+
+	timeout = 2 * p99
+	if timeout < minTunedTimeout {
+		timeout = minTunedTimeout
+	}
+	if timeout > maxTunedTimeout {
+		timeout = maxTunedTimeout
+	}
+	return p99, timeout
+}
+
+// observe records the latency of a request that started at start.
+func (m *ModbusCLI) observe(start time.Time, err error) {
+	latency := time.Since(start)
+	if err == nil && m.tuner != nil {
+		m.tuner.add(latency)
+	}
+	if m.latency != nil {
+		m.latency.add(latency, err)
+	}
+}
+
+// applyTunedTimeout reconnects with the timeout derived from the warm-up
+// latencies, as the client only takes its timeout at creation.
+func (m *ModbusCLI) applyTunedTimeout() error {
+	p99, timeout := m.tuner.tuned()
+	m.tuner.applied = true
+
+	fmt.Fprintf(m.out, "Auto-tuned timeout: %.1f ms (p99 latency %.1f ms over %d samples)\n",
+		float64(timeout.Microseconds())/1000, float64(p99.Microseconds())/1000, len(m.tuner.samples))
+
+	m.config.Timeout = timeout
+	m.client.Close()
+	if err := m.setupClient(); err != nil {
+		return err
+	}
+	return m.connect()
+}
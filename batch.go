@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// batchRow is one row of a --from sheet: values written to ref.
+type batchRow struct {
+	row  int
+	ref  int
+	args []string
+}
+
+// loadBatch reads the REF,VALUE[,VALUE...] rows of a --from file, a CSV file
+// or a sheet of an Excel workbook (.xlsx), as setpoint lists are handed over
+// as spreadsheets.
+func loadBatch(path, sheet string) ([]batchRow, error) {
+	records, err := loadWriteRows(path, sheet, 1, "REF,VALUE[,VALUE...]")
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]batchRow, 0, len(records))
+	for _, record := range records {
+		ref, err := strconv.Atoi(record.fields[0])
+		if err != nil || ref < 0 || ref > 65535 {
+			return nil, fmt.Errorf("%s:%d: invalid reference %q", path, record.row, record.fields[0])
+		}
+		rows = append(rows, batchRow{row: record.row, ref: ref, args: record.args})
+	}
+	return rows, nil
+}
+
+// runBatch writes the rows of the --from file one after another. Every write
+// is journaled like a normal one, so the whole batch can be reverted with
+// undo --session. The batch stops at the first failed write.
+func (m *ModbusCLI) runBatch() error {
+	rows := m.config.Batch
+	fmt.Fprintf(m.out, "Writing %d row(s) from %s\n", len(rows), m.config.BatchFile)
+
+	for _, row := range rows {
+		fmt.Fprintf(m.out, "Row %d:\n", row.row)
+		m.config.WriteArgs = row.args
+		started := time.Now()
+		m.report.setValues(row.args, m.config.MaskValues)
+		err := m.withLock(func() error { return m.journaledWrite(row.ref) })
+		m.report.request("write", row.ref, len(row.args), started, err)
+		if err != nil {
+			return fmt.Errorf("batch stopped at row %d: %w", row.row, err)
+		}
+	}
+	return nil
+}
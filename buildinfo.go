@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build identification, set by release builds with
+//
+//	-ldflags "-X main.version=1.2.0 -X main.commit=abc1234 -X main.buildDate=2024-07-01T06:00:00Z"
+//
+// Plain go build leaves commit and buildDate empty; they are then taken from
+// the version control stamp the Go toolchain embeds, the commit date standing
+// in for the build date.
+var (
+	version   = "1.0.0"
+	commit    = ""
+	buildDate = ""
+)
+
+const modbusModule = "github.com/simonvetter/modbus"
+
+// buildInfo identifies the binary, so field reports can be matched to it.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty tree
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	Modbus    string `json:"modbus_library,omitempty"`
+}
+
+// currentBuildInfo collects the build identification of the running binary.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	stamped := commit == "" // the commit comes from the embedded stamp
+	for _, setting := range embedded.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = stamped && setting.Value == "true"
+		}
+	}
+	for _, dep := range embedded.Deps {
+		if dep.Path == modbusModule {
+			info.Modbus = dep.Path + " " + dep.Version
+		}
+	}
+	return info
+}
+
+// printBuildInfo prints the build identification for --version.
+func printBuildInfo(w io.Writer) {
+	info := currentBuildInfo()
+	fmt.Fprintf(w, "gomodbus v%s\n", info.Version)
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(w, "  commit:  %s%s\n", info.Commit, modified)
+	}
+	if info.BuildDate != "" {
+		fmt.Fprintf(w, "  built:   %s\n", info.BuildDate)
+	}
+	fmt.Fprintf(w, "  go:      %s %s\n", info.GoVersion, info.Platform)
+	if info.Modbus != "" {
+		fmt.Fprintf(w, "  modbus:  %s\n", info.Modbus)
+	}
+}
+
+// describeBuild summarizes the build identification on one line, for reports.
+func describeBuild(info buildInfo) string {
+	desc := "v" + info.Version
+	if info.Commit != "" {
+		desc += " (" + info.Commit
+		if info.Modified {
+			desc += ", modified"
+		}
+		desc += ")"
+	}
+	if info.BuildDate != "" {
+		desc += ", built " + info.BuildDate
+	}
+	desc += ", " + info.GoVersion + " " + info.Platform
+	if info.Modbus != "" {
+		desc += ", " + info.Modbus
+	}
+	return desc
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// The wait before re-issuing a request the device reported busy starts at
+// busyRetryInterval and doubles up to maxBusyRetryInterval.
+const (
+	busyRetryInterval    = 100 * time.Millisecond
+	maxBusyRetryInterval = time.Second
+)
+
+// isBusy reports whether err asks the master to try again later. Acknowledge
+// only counts for reads: for a write it means the device accepted the request
+// and is still carrying it out, so sending it again would repeat the write.
+func isBusy(err error, write bool) bool {
+	if errors.Is(err, modbus.ErrServerDeviceBusy) {
+		return true
+	}
+	return !write && errors.Is(err, modbus.ErrAcknowledge)
+}
+
+// retryBusy runs op, re-issuing it while the device answers Server Device
+// Busy (or Acknowledge, for reads) until the configured patience runs out.
+func (m *ModbusCLI) retryBusy(write bool, op func() error) error {
+	deadline := time.Now().Add(m.config.BusyPatience)
+	wait := busyRetryInterval
+
+	for {
+		err := op()
+		if err == nil || !isBusy(err, write) {
+			return err
+		}
+		if time.Now().Add(wait).After(deadline) {
+			if m.config.BusyPatience > 0 {
+				return fmt.Errorf("%w (gave up after %v)", err, m.config.BusyPatience)
+			}
+			return err
+		}
+
+		if m.config.Verbose {
+			fmt.Fprintf(m.out, "Device reported %v, retrying in %v\n", err, wait)
+		}
+		time.Sleep(wait)
+
+		wait *= 2
+		if wait > maxBusyRetryInterval {
+			wait = maxBusyRetryInterval
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// capabilities describes what this build supports, for wrapper tools and
+// UIs that adapt to the features of the installed gomodbus.
+type capabilities struct {
+	Build         buildInfo      `json:"build"`
+	Subcommands   []string       `json:"subcommands"`
+	Modes         []string       `json:"modes"`
+	FunctionCodes []functionCode `json:"function_codes"`
+	DataTypes     []dataTypeInfo `json:"data_types"`
+	OutputFormats []string       `json:"output_formats"`
+	ScanProbes    []string       `json:"scan_probes"`
+	Languages     []string       `json:"languages"`
+	ReadOnly      bool           `json:"read_only"` // a locked read-only build
+}
+
+type functionCode struct {
+	Code   int    `json:"code"`
+	Name   string `json:"name"`
+	Option string `json:"option"` // what issues the request
+}
+
+type dataTypeInfo struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Registers   int    `json:"registers"` // per value; 0 for bits
+	Writable    bool   `json:"writable"`
+}
+
+// functionCodes lists the requests gomodbus issues, in code order.
+var functionCodes = []functionCode{
+	{0x01, "Read Coils", "-t 0"},
+	{0x02, "Read Discrete Inputs", "-t 1"},
+	{0x03, "Read Holding Registers", "-t 4"},
+	{0x04, "Read Input Registers", "-t 3"},
+	{0x06, "Write Single Register", "--single, --lock"},
+	{fcReadExceptionStatus, "Read Exception Status", "--exception-status"},
+	{fcDiagnostics, "Diagnostics", "--diag"},
+	{fcCommEventCounter, "Get Comm Event Counter", "--comm-events"},
+	{fcCommEventLog, "Get Comm Event Log", "--comm-log"},
+	{0x0f, "Write Multiple Coils", "-t 0 with write values"},
+	{0x10, "Write Multiple Registers", "-t 4 with write values"},
+	{fcReportServerID, "Report Server ID", "--server-id, --scan-probe fc17"},
+	{fcDeviceIdentification, "Read Device Identification", "--scan-probe fc43"},
+}
+
+// dataTypes lists the -t data types; the fixed-point types take a scale.
+var dataTypes = []string{
+	"0", "1",
+	"3", "3:hex", "3:int", "3:float", "3:fixed:SCALE", "3:fixed32:SCALE",
+	"4", "4:hex", "4:int", "4:float", "4:fixed:SCALE", "4:fixed32:SCALE",
+}
+
+func currentCapabilities() capabilities {
+	caps := capabilities{
+		Build:         currentBuildInfo(),
+		Subcommands:   []string{"capabilities", "ctl", "decode", "encode", "selftest", "serve", "undo", "verify-device"},
+		Modes:         []string{"tcp", "tls", "udp", "rtu", "rtuovertcp", "rtuoverudp"},
+		FunctionCodes: functionCodes,
+		OutputFormats: append([]string{"text"}, sortedKeys(recordFormats)...),
+		ScanProbes:    sortedKeys(scanProbes),
+		Languages:     append([]string{"en"}, sortedKeys(catalogs)...),
+		ReadOnly:      lockedReadOnly == "true",
+	}
+	for _, name := range dataTypes {
+		dataType := strings.TrimSuffix(name, ":SCALE")
+		info := dataTypeInfo{
+			Type:        name,
+			Description: dataTypeDescription(dataType),
+			Writable:    dataType == "0" || strings.HasPrefix(dataType, "4"),
+		}
+		if dataType != "0" && dataType != "1" {
+			info.Registers = (&Config{DataType: dataType}).wordsPerValue()
+		}
+		caps.DataTypes = append(caps.DataTypes, info)
+	}
+	return caps
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runCapabilities implements "gomodbus capabilities [--format text|json]".
+func runCapabilities(args []string) error {
+	format := "text"
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-f", "--format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			format = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("unknown capabilities option: %s", arg)
+		}
+	}
+
+	caps := currentCapabilities()
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "text":
+		printCapabilities(caps)
+	default:
+		return fmt.Errorf("unsupported capabilities format: %s (supported: text, json)", format)
+	}
+	return nil
+}
+
+func printCapabilities(caps capabilities) {
+	out := os.Stdout
+	printBuildInfo(out)
+	fmt.Fprintf(out, "\nSubcommands:    %s\n", strings.Join(caps.Subcommands, ", "))
+	fmt.Fprintf(out, "Modes:          %s\n", strings.Join(caps.Modes, ", "))
+	fmt.Fprintf(out, "Output formats: %s\n", strings.Join(caps.OutputFormats, ", "))
+	fmt.Fprintf(out, "Scan probes:    %s\n", strings.Join(caps.ScanProbes, ", "))
+	fmt.Fprintf(out, "Languages:      %s\n", strings.Join(caps.Languages, ", "))
+	if caps.ReadOnly {
+		fmt.Fprintln(out, "Read-only build: writes are refused")
+	}
+
+	fmt.Fprintln(out, "\nFunction codes:")
+	for _, fc := range caps.FunctionCodes {
+		fmt.Fprintf(out, "  0x%02X  %-28s %s\n", fc.Code, fc.Name, fc.Option)
+	}
+
+	fmt.Fprintln(out, "\nData types:")
+	for _, dt := range caps.DataTypes {
+		access := "read"
+		if dt.Writable {
+			access = "read/write"
+		}
+		fmt.Fprintf(out, "  %-16s %-10s %s\n", dt.Type, access, dt.Description)
+	}
+}
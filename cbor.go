@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// CBOR major types (RFC 8949).
+const (
+	cborUint   byte = 0
+	cborNegint byte = 1
+	cborBytes  byte = 2
+	cborText   byte = 3
+	cborArray  byte = 4
+	cborMap    byte = 5
+	cborTag    byte = 6
+	cborSimple byte = 7
+)
+
+// cborTagEpoch marks a numeric timestamp in seconds since the Unix epoch.
+const cborTagEpoch = 1
+
+// encodeCBOR encodes a sample as a single CBOR data item.
+func encodeCBOR(sample *pollSample) ([]byte, error) {
+	w := &cborWriter{}
+	if err := encodeBinary(w, reflect.ValueOf(sample)); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type cborWriter struct {
+	buf []byte
+}
+
+func (w *cborWriter) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major<<5|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, major<<5|25), uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, major<<5|26), uint32(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, major<<5|27), n)
+	}
+}
+
+func (w *cborWriter) Nil() {
+	w.buf = append(w.buf, cborSimple<<5|22)
+}
+
+func (w *cborWriter) Bool(v bool) {
+	if v {
+		w.buf = append(w.buf, cborSimple<<5|21)
+	} else {
+		w.buf = append(w.buf, cborSimple<<5|20)
+	}
+}
+
+func (w *cborWriter) Int(v int64) {
+	if v < 0 {
+		w.head(cborNegint, uint64(-1-v))
+	} else {
+		w.head(cborUint, uint64(v))
+	}
+}
+
+func (w *cborWriter) Uint(v uint64) {
+	w.head(cborUint, v)
+}
+
+func (w *cborWriter) Float32(v float32) {
+	w.buf = binary.BigEndian.AppendUint32(append(w.buf, cborSimple<<5|26), math.Float32bits(v))
+}
+
+func (w *cborWriter) Float64(v float64) {
+	w.buf = binary.BigEndian.AppendUint64(append(w.buf, cborSimple<<5|27), math.Float64bits(v))
+}
+
+func (w *cborWriter) String(v string) {
+	w.head(cborText, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *cborWriter) Time(v time.Time) {
+	w.head(cborTag, cborTagEpoch)
+	w.Float64(float64(v.UnixNano()) / 1e9)
+}
+
+func (w *cborWriter) ArrayHeader(n int) {
+	w.head(cborArray, uint64(n))
+}
+
+func (w *cborWriter) MapHeader(n int) {
+	w.head(cborMap, uint64(n))
+}
+
+// decodeCBOR reads the next CBOR data item from r. Maps decode to orderedMap
+// and epoch-tagged numbers to time.Time.
+func decodeCBOR(r *bufio.Reader) (interface{}, error) {
+	initial, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial>>5, initial&0x1f
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			bits, err := readUint(r, 2)
+			return halfToFloat(uint16(bits)), unexpectedEOF(err)
+		case 26:
+			bits, err := readUint(r, 4)
+			return math.Float32frombits(uint32(bits)), unexpectedEOF(err)
+		case 27:
+			bits, err := readUint(r, 8)
+			return math.Float64frombits(bits), unexpectedEOF(err)
+		}
+		return nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+	}
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		if n, err = readUint(r, 1<<(info-24)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CBOR length encoding %d", info)
+	}
+
+	switch major {
+	case cborUint:
+		return n, nil
+
+	case cborNegint:
+		return -1 - int64(n), nil
+
+	case cborBytes, cborText:
+		data, err := readData(r, n)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(data), nil
+		}
+		return data, nil
+
+	case cborArray:
+		if err := checkLength(n); err != nil {
+			return nil, err
+		}
+		items := []interface{}{}
+		for i := uint64(0); i < n; i++ {
+			item, err := decodeCBOR(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	case cborMap:
+		if err := checkLength(n); err != nil {
+			return nil, err
+		}
+		entries := orderedMap{}
+		for i := uint64(0); i < n; i++ {
+			key, err := decodeCBOR(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			value, err := decodeCBOR(r)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			entries = append(entries, mapEntry{Key: fmt.Sprint(key), Value: value})
+		}
+		return entries, nil
+
+	default: // cborTag
+		content, err := decodeCBOR(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if n == cborTagEpoch {
+			switch secs := content.(type) {
+			case uint64:
+				return time.Unix(int64(secs), 0), nil
+			case int64:
+				return time.Unix(secs, 0), nil
+			case float64:
+				whole, frac := math.Modf(secs)
+				return time.Unix(int64(whole), int64(frac*1e9)), nil
+			}
+		}
+		return content, nil
+	}
+}
+
+// readUint reads a big-endian unsigned integer of size bytes.
+func readUint(r io.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// maxRecordLength bounds the length of a string or the number of items a
+// record header may announce. Records hold a few hundred values; a corrupt
+// header is rejected instead of being allocated.
+const maxRecordLength = 1 << 24
+
+func checkLength(n uint64) error {
+	if n > maxRecordLength {
+		return fmt.Errorf("record announces a length of %d, more than the %d allowed", n, maxRecordLength)
+	}
+	return nil
+}
+
+// readData reads the n bytes of a string or byte string. The buffer grows
+// with the bytes actually read, so a length beyond the end of the input
+// fails there instead of being allocated up front.
+func readData(r io.Reader, n uint64) ([]byte, error) {
+	if err := checkLength(n); err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, min(n, 512)))
+	if _, err := io.CopyN(buf, r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF turns a clean EOF in the middle of a record into an error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// halfToFloat converts an IEEE 754 half-precision value.
+func halfToFloat(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+
+	var val float64
+	switch exp {
+	case 0:
+		val = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			val = math.Inf(1)
+		} else {
+			val = math.NaN()
+		}
+	default:
+		val = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if bits&0x8000 != 0 {
+		return -val
+	}
+	return val
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSample() *pollSample {
+	return &pollSample{
+		Timestamp: time.Unix(1714641243, 0),
+		Source:    "192.168.1.100:502",
+		UnitID:    1,
+		Label:     "boiler1",
+		DataType:  "4",
+		Start:     100,
+		Values:    []uint16{7, 65535},
+		Hash:      "7c1262ec0cb5ced5",
+	}
+}
+
+// decodeAll decodes one item from data with decode and fails the test if
+// anything is left over.
+func decodeAll(t *testing.T, decode func(*bufio.Reader) (interface{}, error), data []byte) (interface{}, error) {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(data))
+	v, err := decode(r)
+	if err == nil {
+		if _, rerr := r.ReadByte(); rerr != io.EOF {
+			t.Errorf("trailing bytes after the decoded item")
+		}
+	}
+	return v, err
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	data, err := encodeCBOR(testSample())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := decodeAll(t, decodeCBOR, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := orderedMap{
+		{Key: "timestamp", Value: time.Unix(1714641243, 0)},
+		{Key: "source", Value: "192.168.1.100:502"},
+		{Key: "unit_id", Value: uint64(1)},
+		{Key: "label", Value: "boiler1"},
+		{Key: "data_type", Value: "4"},
+		{Key: "start", Value: uint64(100)},
+		{Key: "values", Value: []interface{}{uint64(7), uint64(65535)}},
+		{Key: "hash", Value: "7c1262ec0cb5ced5"},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("decoded %#v, want %#v", v, want)
+	}
+}
+
+func TestDecodeCBORItems(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"uint", []byte{0x18, 0x64}, uint64(100)},
+		{"negint", []byte{0x38, 0x63}, int64(-100)},
+		{"text", []byte{0x63, 'a', 'b', 'c'}, "abc"},
+		{"empty bytes", []byte{0x40}, []byte{}},
+		{"empty array", []byte{0x80}, []interface{}{}},
+		{"empty map", []byte{0xa0}, orderedMap{}},
+		{"half float", []byte{0xf9, 0x3e, 0x00}, 1.5},
+		{"float32", []byte{0xfa, 0x3f, 0xc0, 0x00, 0x00}, float32(1.5)},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeAll(t, decodeCBOR, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("decoded %#v, want %#v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated uint", []byte{0x19, 0x01}},
+		{"truncated text", []byte{0x65, 'a', 'b'}},
+		{"truncated array", []byte{0x83, 0x01, 0x02}},
+		{"truncated map value", []byte{0xa1, 0x61, 'k'}},
+		{"truncated float", []byte{0xfb, 0x3f, 0xf8}},
+		{"huge array", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"huge map", []byte{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"huge text", []byte{0x7b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"text past input", []byte{0x7a, 0x00, 0xff, 0xff, 0xff, 'a'}},
+		{"array past input", []byte{0x9a, 0x00, 0xff, 0xff, 0xff, 0x01}},
+		{"indefinite length", []byte{0x9f, 0x01, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeAll(t, decodeCBOR, tt.data)
+			if err == nil {
+				t.Fatalf("decoded %#v, want an error", v)
+			}
+			if errors.Is(err, io.EOF) {
+				t.Errorf("got a clean EOF in the middle of an item")
+			}
+		})
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	sample, _ := encodeCBOR(testSample())
+	f.Add(sample)
+	f.Add([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0x7a, 0x00, 0xff, 0xff, 0xff, 'a'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeCBOR(bufio.NewReader(bytes.NewReader(data)))
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// sampleHash identifies what a sample read: the device, unit, data type,
+// start reference and values, but not the time of the read. Identical reads
+// hash alike, so change-data-capture pipelines can deduplicate on it.
+func sampleHash(sample *pollSample) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d|%s|%d|%v", sample.Source, sample.UnitID, sample.DataType, sample.Start, sample.Values)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// sampleChanged reports whether sample differs from the last one published
+// for its unit, and remembers its hash. The first sample of a unit counts as
+// changed.
+func (m *ModbusCLI) sampleChanged(sample *pollSample) bool {
+	if m.hashes == nil {
+		m.hashes = make(map[int]string)
+	}
+	if m.hashes[sample.UnitID] == sample.Hash {
+		return false
+	}
+	m.hashes[sample.UnitID] = sample.Hash
+	return true
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// partialWriteError reports a chunked write that failed after some of its
+// requests succeeded, leaving the first written coils or registers changed.
+type partialWriteError struct {
+	written int // coils or registers written before the failure
+	total   int
+	err     error
+}
+
+func (e *partialWriteError) Error() string {
+	return fmt.Sprintf("%v (after writing %d of %d)", e.err, e.written, e.total)
+}
+
+func (e *partialWriteError) Unwrap() error {
+	return e.err
+}
+
+// partialReadError reports a chunked read of which some requests failed.
+// The registers of the failed requests are marked bad and read as zero.
+type partialReadError struct {
+	bad    []bool // per register of the block
+	failed int    // requests that failed
+	total  int
+	err    error // the first failure
+}
+
+func (e *partialReadError) Error() string {
+	return fmt.Sprintf("%v (%d of %d requests failed)", e.err, e.failed, e.total)
+}
+
+func (e *partialReadError) Unwrap() error {
+	return e.err
+}
+
+// badValue stands in for a value that couldn't be read in listings.
+const badValue = "--"
+
+// isBad reports whether any of the n registers at offset of the block being
+// printed failed to read.
+func (m *ModbusCLI) isBad(offset, n int) bool {
+	for i := offset; i < offset+n && i < len(m.bad); i++ {
+		if m.bad[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeChunkSize returns the most coils or registers one write request of
+// the data type carries: the protocol limit, rounded down to whole values so
+// a 32-bit value is never split across two requests.
+func (c *Config) writeChunkSize() int {
+	if journalTable(c.DataType) == "0" {
+		return maxWriteBits
+	}
+	words := c.wordsPerValue()
+	return maxWriteRegisters / words * words
+}
+
+// writeChunked writes n coils or registers in requests of at most size,
+// calling write with the offset and length of each. A failure after the
+// first request is returned as a *partialWriteError.
+func (m *ModbusCLI) writeChunked(n, size int, write func(offset, count int) error) error {
+	if n > size && m.config.Verbose {
+		fmt.Fprintf(os.Stderr, "Splitting the write into %d requests\n", (n+size-1)/size)
+	}
+	for offset := 0; offset < n; offset += size {
+		count := min(size, n-offset)
+		err := m.retryBusy(true, func() error {
+			return write(offset, count)
+		})
+		if err != nil && offset > 0 {
+			return &partialWriteError{written: offset, total: n, err: err}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRaw writes coil states (0 or 1) or raw registers, as journaled,
+// in as many requests as the protocol limits need.
+func (m *ModbusCLI) writeRaw(table string, startRef int, values []uint16) error {
+	if table == "0" {
+		coils := make([]bool, len(values))
+		for i, value := range values {
+			coils[i] = value != 0
+		}
+		return m.writeChunked(len(coils), maxWriteBits, func(offset, count int) error {
+			return m.client.WriteCoils(uint16(startRef+offset), coils[offset:offset+count])
+		})
+	}
+	return m.writeRegisters(startRef, values, maxWriteRegisters)
+}
+
+// writeRegisters writes holding registers with FC16 in requests of at most
+// size, or with --single one FC06 request per register.
+func (m *ModbusCLI) writeRegisters(startRef int, registers []uint16, size int) error {
+	if m.config.Single {
+		return m.writeChunked(len(registers), 1, func(offset, _ int) error {
+			return m.client.WriteRegister(uint16(startRef+offset), registers[offset])
+		})
+	}
+	return m.writeChunked(len(registers), size, func(offset, count int) error {
+		return m.client.WriteRegisters(uint16(startRef+offset), registers[offset:offset+count])
+	})
+}
+
+// registerWriteFunction names the function code holding registers are
+// written with.
+func (c *Config) registerWriteFunction() string {
+	if c.Single {
+		return "FC06"
+	}
+	return "FC16"
+}
+
+// readOriginal reads the coils (as 0 or 1) or holding registers a write is
+// about to change, in as many requests as the protocol limits need.
+func (m *ModbusCLI) readOriginal(table string, startRef, count int) ([]uint16, error) {
+	size := maxReadRegisters
+	if table == "0" {
+		size = maxReadBits
+	}
+
+	values := make([]uint16, 0, count)
+	for offset := 0; offset < count; offset += size {
+		n := min(size, count-offset)
+		addr := uint16(startRef + offset)
+		err := m.retryBusy(false, func() error {
+			if table == "0" {
+				coils, err := m.client.ReadCoils(addr, uint16(n))
+				if err != nil {
+					return err
+				}
+				for _, coil := range coils {
+					values = append(values, uint16(boolToInt(coil)))
+				}
+				return nil
+			}
+
+			registers, err := m.client.ReadRegisters(addr, uint16(n), modbus.HOLDING_REGISTER)
+			values = append(values, registers...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// rollback writes the original values back over the part of a chunked write
+// that succeeded before it failed with err, making the write all-or-nothing.
+func (m *ModbusCLI) rollback(table string, startRef int, before []uint16, err error) error {
+	unit := "register(s)"
+	if table == "0" {
+		unit = "coil(s)"
+	}
+	if rerr := m.writeRaw(table, startRef, before); rerr != nil {
+		return fmt.Errorf("%w; rolling back the %d %s already written failed too: %v", err, len(before), unit, rerr)
+	}
+	fmt.Fprintf(m.out, "Rolled back the %d %s already written\n", len(before), unit)
+	return err
+}
+
+// verifyWrite reads back the coils or registers the configured write just
+// wrote and checks that they hold the values sent, for --atomic.
+func (m *ModbusCLI) verifyWrite(table string, startRef int) error {
+	sent, err := m.encodeWriteRaw(table)
+	if err != nil {
+		return err
+	}
+
+	got, err := m.readOriginal(table, startRef, len(sent))
+	if err != nil {
+		return fmt.Errorf("failed to read back the written values: %w", err)
+	}
+	for i, value := range sent {
+		if got[i] != value {
+			return fmt.Errorf("verification failed: reference %d reads %d after writing %d", startRef+i, got[i], value)
+		}
+	}
+	return nil
+}
+
+// readChunks splits a read of count registers into the lengths of requests of
+// at most maxReadRegisters each. Every request ends on a value boundary, the
+// fields of a --layout included, so no 32-bit or longer value is split across
+// two requests, whose halves could come from different moments. The
+// registers of all requests are decoded as one block.
+func (c *Config) readChunks(count int) []int {
+	widths := []int{c.wordsPerValue()}
+	if c.Layout != nil {
+		widths = widths[:0]
+		for _, field := range c.Layout.fields {
+			widths = append(widths, field.words)
+		}
+	}
+
+	var chunks []int
+	chunk := 0
+	for offset, i := 0, 0; offset < count; i++ {
+		width := min(widths[i%len(widths)], count-offset)
+		if chunk+width > maxReadRegisters {
+			chunks = append(chunks, chunk)
+			chunk = 0
+		}
+		chunk += width
+		offset += width
+	}
+	return append(chunks, chunk)
+}
+
+// readRegisterChunks reads the configured registers of the given kind, in as
+// many requests as readChunks needs. A failed request doesn't stop the
+// others: their registers are returned with a *partialReadError marking the
+// failed ones, unless --fail-fast asks for the first error instead.
+func (m *ModbusCLI) readRegisterChunks(startRef int, kind modbus.RegType) ([]uint16, error) {
+	chunks := m.config.readChunks(m.config.registerCount())
+	if len(chunks) == 1 {
+		return m.readRegisterChunk(startRef, chunks[0], kind)
+	}
+
+	registers := make([]uint16, 0, m.config.registerCount())
+	var partial *partialReadError
+	for _, count := range chunks {
+		values, err := m.readRegisterChunk(startRef+len(registers), count, kind)
+		if err != nil {
+			if m.config.FailFast {
+				return nil, err
+			}
+			if partial == nil {
+				partial = &partialReadError{bad: make([]bool, m.config.registerCount()), total: len(chunks), err: err}
+			}
+			for i := 0; i < count; i++ {
+				partial.bad[len(registers)+i] = true
+			}
+			partial.failed++
+			values = make([]uint16, count)
+		}
+		registers = append(registers, values...)
+	}
+	if partial == nil {
+		return registers, nil
+	}
+	if partial.failed == partial.total {
+		return nil, partial.err
+	}
+	return registers, partial
+}
+
+func (m *ModbusCLI) readRegisterChunk(startRef, count int, kind modbus.RegType) (values []uint16, err error) {
+	err = m.retryBusy(false, func() error {
+		start := time.Now()
+		values, err = m.client.ReadRegisters(uint16(startRef), uint16(count), kind)
+		m.observe(start, err)
+		return err
+	})
+	return values, err
+}
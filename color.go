@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ANSI colors of the terminal output.
+const (
+	colorReset   = "\x1b[0m"
+	colorChanged = "\x1b[1;33m" // a value that differs from the last poll
+	colorAlarm   = "\x1b[1;31m" // a false --expect
+	colorOK      = "\x1b[32m"   // a true --expect
+	colorFault   = "\x1b[31m"   // an exception response
+	colorStale   = "\x1b[35m"   // a timeout or link error: the values shown are stale
+)
+
+// colorsFor reports whether output to f gets colors: f must be a terminal,
+// and neither --no-color nor the NO_COLOR convention (no-color.org) may
+// turn them off. The Windows console only understands them in Windows
+// Terminal.
+func colorsFor(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colored wraps text in color when output to w gets colors.
+func (m *ModbusCLI) colored(w io.Writer, color, text string) string {
+	if w == os.Stdout && m.colorOut || w == os.Stderr && m.colorErr {
+		return color + text + colorReset
+	}
+	return text
+}
+
+// valueChanged reports whether value differs from the one last printed on
+// the output line starting with prefix (the unit tag and address), and
+// remembers it. A value printed for the first time hasn't changed.
+func (m *ModbusCLI) valueChanged(prefix, value []byte) bool {
+	previous, seen := m.previous[string(prefix)]
+	if seen && previous == string(value) {
+		return false
+	}
+	if m.previous == nil {
+		m.previous = make(map[string]string)
+	}
+	m.previous[string(prefix)] = string(value)
+	return seen
+}
+
+// paint returns line with line[start:end] in color, built in a buffer of
+// its own so the line buffer stays intact.
+func (m *ModbusCLI) paint(line []byte, start, end int, color string) []byte {
+	painted := append(m.painted[:0], line[:start]...)
+	painted = append(painted, color...)
+	painted = append(painted, line[start:end]...)
+	painted = append(painted, colorReset...)
+	painted = append(painted, line[end:]...)
+	m.painted = painted
+	return painted
+}
+
+// printPollError prints an error that polling carries on after: exception
+// responses in one color, timeouts and link errors, which leave the values
+// on screen stale, in another.
+func (m *ModbusCLI) printPollError(err error) {
+	color := colorStale
+	if isExceptionResponse(err) {
+		color = colorFault
+	}
+	msg := strings.TrimSuffix(fmt.Sprintf(tr("Error: %v\n"), localizeError(err)), "\n")
+	fmt.Fprintln(os.Stderr, m.colored(os.Stderr, color, msg))
+}
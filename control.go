@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A continuous poll started with --control SOCKET listens on a Unix socket
+// for one-line commands, sent with "gomodbus ctl SOCKET COMMAND...":
+//
+//	pause                     stop polling until resumed
+//	resume                    poll again
+//	rate MS                   change the poll rate
+//	write [@UNIT] REF V...    write values with the configured data type
+//	status                    describe the poll
+//
+// Requests are served by the polling goroutine between polls, so they never
+// race a request in flight and writes are journaled in the running session.
+
+// controlRequest is one command received on the control socket.
+type controlRequest struct {
+	args  []string
+	reply chan string
+}
+
+// listenControl opens the control socket and feeds its commands to
+// m.control until the returned function closes it. A socket file left
+// behind by a crashed run is replaced; the socket is accessible to its
+// owner only, from the moment it is created.
+func (m *ModbusCLI) listenControl(path string) (func(), error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %s is in use by another poller", path)
+		}
+		os.Remove(path)
+	}
+
+	var listener net.Listener
+	err := withUmask(0o177, func() (err error) {
+		listener, err = net.Listen("unix", path)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control socket: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket: %v", err)
+	}
+
+	m.control = make(chan controlRequest)
+	m.controlDone = make(chan struct{})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serveControl(conn)
+		}
+	}()
+	return func() {
+		listener.Close()
+		close(m.controlDone)
+	}, nil
+}
+
+// serveControl reads one command from conn and writes back its reply.
+func (m *ModbusCLI) serveControl(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		fmt.Fprintln(conn, "error: empty command")
+		return
+	}
+
+	// Polling may have stopped while the command was read
+	req := controlRequest{args: args, reply: make(chan string, 1)}
+	select {
+	case m.control <- req:
+		fmt.Fprintln(conn, <-req.reply)
+	case <-m.controlDone:
+		fmt.Fprintln(conn, "error: polling has stopped")
+	}
+}
+
+// handleControl carries out a control command in the polling goroutine.
+func (m *ModbusCLI) handleControl(req controlRequest, stats *pollStats) {
+	reply, err := m.runControl(req.args, stats)
+	if err != nil {
+		reply = "error: " + err.Error()
+	}
+	fmt.Fprintf(m.out, "Control: %s: %s\n", strings.Join(req.args, " "), reply)
+	req.reply <- reply
+}
+
+func (m *ModbusCLI) runControl(args []string, stats *pollStats) (string, error) {
+	switch args[0] {
+	case "pause":
+		m.paused = true
+		return "ok, paused", nil
+
+	case "resume":
+		m.paused = false
+		return "ok, polling", nil
+
+	case "rate":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: rate MS")
+		}
+		rate, err := strconv.Atoi(args[1])
+		if err != nil || rate < 10 {
+			return "", fmt.Errorf("poll rate must be a number of milliseconds, at least 10")
+		}
+		m.config.PollRate = time.Duration(rate) * time.Millisecond
+		return fmt.Sprintf("ok, poll rate %d ms", rate), nil
+
+	case "write":
+		return m.controlWrite(args[1:])
+
+	case "status":
+		state := "polling"
+		if m.paused {
+			state = "paused"
+		}
+		failed := 0
+		for _, count := range stats.failures {
+			failed += count
+		}
+		return fmt.Sprintf("%s, poll rate %d ms, %d request(s), %d failed, session %s",
+			state, m.config.PollRate.Milliseconds(), stats.requests, failed, m.config.session()), nil
+	}
+	return "", fmt.Errorf("unknown command %s (expected pause, resume, rate, write or status)", args[0])
+}
+
+// controlWrite writes values to a reference of the first polled unit, or of
+// the unit given as @UNIT, and leaves the poll configuration as it was.
+func (m *ModbusCLI) controlWrite(args []string) (string, error) {
+	unit := m.config.units()[0]
+	if len(args) > 0 && strings.HasPrefix(args[0], "@") {
+		n, err := strconv.Atoi(args[0][1:])
+		if err != nil || n < 0 || n > 255 {
+			return "", fmt.Errorf("invalid unit %s", args[0])
+		}
+		unit, args = n, args[1:]
+	}
+	if len(args) < 2 {
+		return "", fmt.Errorf("usage: write [@UNIT] REF VALUE...")
+	}
+	ref, err := strconv.Atoi(args[0])
+	if err != nil || ref < 0 || ref > 65535 {
+		return "", fmt.Errorf("invalid reference %s", args[0])
+	}
+	var values []string
+	for _, arg := range args[1:] {
+		for _, value := range strings.Split(arg, ",") {
+			if !isWriteValue(value) {
+				return "", fmt.Errorf("invalid write value: %s", value)
+			}
+			values = append(values, value)
+		}
+	}
+
+	if err := m.selectUnit(unit); err != nil {
+		return "", err
+	}
+	m.config.WriteArgs = values
+	defer func() { m.config.WriteArgs = nil }()
+
+	started := time.Now()
+	m.report.setValues(values, m.config.MaskValues)
+	err = m.withLock(func() error { return m.journaledWrite(ref) })
+	m.report.request("write", ref, len(values), started, err)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ok, wrote %d value(s) to unit %d at %d", len(values), unit, ref), nil
+}
+
+// waitForNextPoll waits out the poll interval, serving control commands
+// meanwhile, and for as long as polling is paused. It reports whether
+// polling was stopped with Ctrl-C.
+func (m *ModbusCLI) waitForNextPoll(wait *time.Timer, stop <-chan os.Signal, stats *pollStats) bool {
+	wait.Reset(m.config.PollRate)
+	for {
+		select {
+		case <-stop:
+			wait.Stop()
+			return true
+		case req := <-m.control:
+			m.handleControl(req, stats)
+		case <-wait.C:
+			if !m.paused {
+				return false
+			}
+			wait.Reset(m.config.PollRate)
+		}
+	}
+}
+
+// runCtl implements "gomodbus ctl SOCKET COMMAND [ARGS...]", sending one
+// command to the control socket of a running poller.
+func runCtl(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: gomodbus ctl SOCKET pause|resume|rate MS|write [@UNIT] REF VALUE...|status")
+	}
+
+	conn, err := net.DialTimeout("unix", args[0], 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to reach the poller: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, strings.Join(args[1:], " ")); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && reply == "" {
+		return fmt.Errorf("no reply from the poller: %v", err)
+	}
+	reply = strings.TrimSuffix(reply, "\n")
+	if msg, failed := strings.CutPrefix(reply, "error: "); failed {
+		return errors.New(msg)
+	}
+	fmt.Println(reply)
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// csvFormatter prints a row per read, one column per value, for logging a
+// continuous poll into spreadsheets and pandas. A header row names the
+// columns by address, or by --layout field name, and is repeated whenever
+// they change, e.g. between units polled with different ranges.
+type csvFormatter struct {
+	m       *ModbusCLI
+	w       *csv.Writer
+	header  []string // columns of the last header row written
+	columns []string
+	row     []string
+}
+
+func newCSVFormatter(m *ModbusCLI) *csvFormatter {
+	return &csvFormatter{m: m, w: csv.NewWriter(os.Stdout)}
+}
+
+// begin starts the row of a read with its timestamp, unit ID and label.
+func (f *csvFormatter) begin() {
+	f.columns = append(f.columns[:0], "timestamp", "unit_id")
+	f.row = append(f.row[:0], time.Now().Format(time.RFC3339Nano), strconv.Itoa(f.m.config.SlaveID))
+	if f.m.tag != "" {
+		f.columns = append(f.columns, "label")
+		f.row = append(f.row, f.m.tag)
+	}
+}
+
+func (f *csvFormatter) add(column string, value interface{}) {
+	cell := ""
+	if f.m.config.MaskValues {
+		cell = maskPlaceholder
+	} else if value != nil {
+		cell = fmt.Sprint(value)
+	}
+	f.columns = append(f.columns, column)
+	f.row = append(f.row, cell)
+}
+
+// end writes the row, after a header row if the columns changed.
+func (f *csvFormatter) end() {
+	if !slices.Equal(f.columns, f.header) {
+		f.header = append(f.header[:0], f.columns...)
+		f.w.Write(f.header)
+	}
+	f.w.Write(f.row)
+	f.w.Flush()
+	if err := f.w.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write CSV: %v\n", err)
+	}
+}
+
+func (f *csvFormatter) formatBits(startRef int, bits []bool, regType string) {
+	f.begin()
+	for i, bit := range bits {
+		f.add(strconv.Itoa(startRef+i), boolToInt(bit))
+	}
+	f.end()
+}
+
+func (f *csvFormatter) formatRegisters(startRef int, registers []uint16, regType string) error {
+	m := f.m
+	f.begin()
+	if layout := m.config.Layout; layout != nil {
+		for offset := 0; offset+layout.words <= len(registers); {
+			for _, field := range layout.fields {
+				column := field.name
+				if column == "" {
+					column = strconv.Itoa(startRef + offset)
+				}
+				var value interface{}
+				if !m.isBad(offset, field.words) {
+					value = m.layoutValue(field, registers[offset:offset+field.words])
+				}
+				f.add(column, value)
+				offset += field.words
+			}
+		}
+	} else {
+		m.decoded = decodeRegisters(m.decoded, registers, m.config.DataType, m.config.BigEndian)
+		words := m.config.wordsPerValue()
+		for _, value := range m.decoded {
+			var cell interface{}
+			if !m.isBad(value.Offset, words) {
+				cell = m.typedValue(value)
+			}
+			f.add(strconv.Itoa(startRef+value.Offset), cell)
+		}
+	}
+	f.end()
+	return nil
+}
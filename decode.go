@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// recordDecoders maps record format names to their stream decoders.
+var recordDecoders = map[string]func(r *bufio.Reader) (interface{}, error){
+	"cbor":    decodeCBOR,
+	"msgpack": decodeMsgPack,
+	"ndjson":  decodeNDJSON,
+}
+
+// runDecode implements "gomodbus decode [OPTIONS] [FILE]", turning a stream
+// of binary records back into one JSON object per line. With --text, or any
+// of the decoding options, the raw values of each record are printed the way
+// a live read would print them, for looking into captures after the fact.
+func runDecode(args []string) error {
+	format := ""
+	path := "-"
+	text := false
+	config := &Config{CountUnit: "registers", BigEndian: true}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-f", "--format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			format = args[i+1]
+			i++
+		case "-i", "--input":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			path = args[i+1]
+			i++
+		case "--text":
+			text = true
+		case "-t", "--type":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			dataType, exponent, fixed, err := parseFixedType(args[i+1])
+			if err != nil {
+				return err
+			}
+			config.DataType = dataType
+			if fixed {
+				config.FixedExponent = exponent
+			}
+			text = true
+			i++
+		case "--layout":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			layout, err := parseLayout(args[i+1])
+			if err != nil {
+				return err
+			}
+			config.Layout = layout
+			text = true
+			i++
+		case "--decoder":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			if strings.TrimSpace(args[i+1]) == "" {
+				return fmt.Errorf("--decoder requires a command")
+			}
+			config.Decoder = args[i+1]
+			text = true
+			i++
+		default:
+			if len(arg) > 1 && arg[0] == '-' {
+				return fmt.Errorf("unknown decode option: %s", arg)
+			}
+			path = arg
+		}
+	}
+
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		defer file.Close()
+		in = file
+	}
+	r := bufio.NewReader(in)
+
+	if format == "" {
+		first, err := r.Peek(1)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if format = detectRecordFormat(first[0]); format == "" {
+			return fmt.Errorf("cannot detect record format, use --format cbor|msgpack|ndjson")
+		}
+	}
+	decode, ok := recordDecoders[format]
+	if !ok {
+		return fmt.Errorf("unsupported record format: %s (supported: cbor, msgpack, ndjson)", format)
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	m := &ModbusCLI{config: config}
+	for n := 1; ; n++ {
+		record, err := decode(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %v", n, err)
+		}
+		if text {
+			if err := m.printRecord(record); err != nil {
+				return fmt.Errorf("record %d: %v", n, err)
+			}
+			continue
+		}
+		if err := out.Encode(record); err != nil {
+			return fmt.Errorf("record %d: %v", n, err)
+		}
+	}
+}
+
+// detectRecordFormat guesses the record format from the first byte of a
+// stream: every record is a map, and the two formats mark maps differently.
+func detectRecordFormat(first byte) string {
+	switch {
+	case first>>5 == cborMap:
+		return "cbor"
+	case first&0xf0 == 0x80, first == 0xde, first == 0xdf:
+		return "msgpack"
+	case first == '{':
+		return "ndjson"
+	}
+	return ""
+}
+
+// decodeNDJSON reads the next line of JSON from r, as written by
+// --format ndjson or decode itself, skipping blank lines.
+func decodeNDJSON(r *bufio.Reader) (interface{}, error) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record json.RawMessage
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, err
+			}
+			return record, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// capturedSample is the part of a recorded sample that decode --text needs.
+type capturedSample struct {
+	Timestamp string        `json:"timestamp"`
+	Source    string        `json:"source"`
+	UnitID    int           `json:"unit_id"`
+	Label     string        `json:"label"`
+	DataType  string        `json:"data_type"`
+	Start     int           `json:"start"`
+	Values    []interface{} `json:"values"`
+}
+
+// printRecord prints the values of a recorded sample under a line telling
+// where and when it was captured. The data type given to decode overrides
+// the recorded one, e.g. to read raw registers as floats.
+func (m *ModbusCLI) printRecord(record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var sample capturedSample
+	if err := json.Unmarshal(data, &sample); err != nil {
+		return fmt.Errorf("not a recorded sample: %v", err)
+	}
+	if sample.DataType == "" {
+		return fmt.Errorf("not a recorded sample: no data_type")
+	}
+
+	fmt.Printf("%s %s unit %d\n", sample.Timestamp, sample.Source, sample.UnitID)
+	m.tag = sample.Label
+	m.config.SlaveID = sample.UnitID
+	m.config.Count = len(sample.Values)
+
+	switch table := sample.DataType[:1]; table {
+	case "0", "1":
+		regType := "Coils"
+		if table == "1" {
+			regType = "Discrete Inputs"
+		}
+		m.printHeader(regType, sample.Start)
+		for i, value := range sample.Values {
+			bit, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("value %d is not a bit: %v", i, value)
+			}
+			m.endValue(strconv.AppendInt(m.beginValue(sample.Start+i), int64(boolToInt(bit)), 10), "")
+		}
+		return nil
+
+	case "3", "4":
+		registers := make([]uint16, len(sample.Values))
+		for i, value := range sample.Values {
+			number, ok := value.(float64)
+			if !ok || number < 0 || number > 0xffff || number != float64(uint16(number)) {
+				return fmt.Errorf("value %d is not a register: %v", i, value)
+			}
+			registers[i] = uint16(number)
+		}
+		regType := "Holding Registers"
+		if table == "3" {
+			regType = "Input Registers"
+		}
+		if m.config.DataType == "" {
+			m.config.DataType = sample.DataType
+			defer func() { m.config.DataType = "" }()
+		}
+		return m.printRegisters(sample.Start, registers, regType)
+	}
+	return fmt.Errorf("unknown data_type %s", sample.DataType)
+}
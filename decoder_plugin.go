@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// decoderTimeout bounds how long an external decoder may take per block.
+const decoderTimeout = 5 * time.Second
+
+// decoderInput is the JSON document written to an external decoder's stdin.
+type decoderInput struct {
+	DataType  string   `json:"data_type"`
+	UnitID    int      `json:"unit_id"`
+	Start     int      `json:"start"`
+	BigEndian bool     `json:"big_endian"`
+	Registers []uint16 `json:"registers"`
+}
+
+// runDecoder hands a block of raw registers to the external decoder command
+// and returns the lines it printed. This lets vendor-specific encodings be
+// handled by a script or binary without changes to gomodbus itself.
+func (m *ModbusCLI) runDecoder(startRef int, registers []uint16) ([]string, error) {
+	args := strings.Fields(m.config.Decoder)
+
+	input, err := json.Marshal(&decoderInput{
+		DataType:  m.config.DataType,
+		UnitID:    m.config.SlaveID,
+		Start:     startRef,
+		BigEndian: m.config.BigEndian,
+		Registers: registers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), decoderTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("decoder %s failed: %v: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("decoder %s failed: %v", args[0], err)
+	}
+
+	output := strings.TrimRight(stdout.String(), "\n")
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
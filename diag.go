@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Function codes sent as raw requests.
+const (
+	fcReadExceptionStatus  = 0x07
+	fcDiagnostics          = 0x08
+	fcCommEventCounter     = 0x0b
+	fcCommEventLog         = 0x0c
+	fcReportServerID       = 0x11
+	fcDeviceIdentification = 0x2b
+)
+
+// meiReadDeviceID is the MEI type of Read Device Identification (FC43/14).
+const meiReadDeviceID = 0x0e
+
+// Diagnostics (FC08) sub-functions with special handling.
+const (
+	diagReturnQueryData     = 0x00
+	diagReturnDiagnosticReg = 0x02
+	diagForceListenOnly     = 0x04
+)
+
+// diagSubFunctions names the serial line diagnostics sub-functions of the
+// Modbus specification.
+var diagSubFunctions = map[uint16]string{
+	0x00: "Return Query Data",
+	0x01: "Restart Communications Option",
+	0x02: "Return Diagnostic Register",
+	0x03: "Change ASCII Input Delimiter",
+	0x04: "Force Listen Only Mode",
+	0x0a: "Clear Counters and Diagnostic Register",
+	0x0b: "Return Bus Message Count",
+	0x0c: "Return Bus Communication Error Count",
+	0x0d: "Return Bus Exception Error Count",
+	0x0e: "Return Server Message Count",
+	0x0f: "Return Server No Response Count",
+	0x10: "Return Server NAK Count",
+	0x11: "Return Server Busy Count",
+	0x12: "Return Bus Character Overrun Count",
+	0x14: "Clear Overrun Counter and Flag",
+}
+
+// diagChangesState reports whether a sub-function alters the device's state,
+// so it is subject to --read-only and write windows like any write.
+func diagChangesState(sub uint16) bool {
+	switch sub {
+	case 0x01, 0x03, 0x04, 0x0a, 0x14:
+		return true
+	}
+	return false
+}
+
+// parseDiagnostic parses a --diagnostic SUB[:DATA] spec, with SUB and DATA
+// in decimal or 0x hex.
+func parseDiagnostic(spec string) (sub, data uint16, err error) {
+	subStr, dataStr, hasData := strings.Cut(spec, ":")
+	n, err := strconv.ParseUint(subStr, 0, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid diagnostic sub-function %q", subStr)
+	}
+	sub = uint16(n)
+
+	if hasData {
+		n, err := strconv.ParseUint(dataStr, 0, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid diagnostic data %q", dataStr)
+		}
+		data = uint16(n)
+	}
+	return sub, data, nil
+}
+
+// parseBitNames parses a --bit-names list such as "0=Overtemp,3=Low battery".
+func parseBitNames(spec string) (map[int]string, error) {
+	names := make(map[int]string)
+	for _, part := range strings.Split(spec, ",") {
+		bitStr, name, ok := strings.Cut(part, "=")
+		bit, err := strconv.Atoi(strings.TrimSpace(bitStr))
+		if !ok || err != nil || bit < 0 || bit > 15 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid bit name %q (expected BIT=NAME with BIT 0-15)", part)
+		}
+		names[bit] = strings.TrimSpace(name)
+	}
+	return names, nil
+}
+
+// runDiagnostics performs the Read Exception Status (FC07), Diagnostics
+// (FC08), Get Comm Event Counter (FC11), Get Comm Event Log (FC12) or Report
+// Server ID (FC17) request and prints its decoded result.
+func (m *ModbusCLI) runDiagnostics() error {
+	if m.config.ServerID {
+		res, err := m.rawRequest([]byte{fcReportServerID})
+		if err != nil {
+			return fmt.Errorf("failed to report server id: %w", err)
+		}
+		desc, err := describeServerID(res)
+		if err != nil {
+			return fmt.Errorf("failed to report server id: %w", err)
+		}
+		fmt.Printf("Server ID: %s\n", desc)
+		return nil
+	}
+
+	if m.config.CommEvents {
+		res, err := m.rawRequest([]byte{fcCommEventCounter})
+		if err != nil {
+			return fmt.Errorf("failed to get comm event counter: %w", err)
+		}
+		if len(res) != 5 {
+			return fmt.Errorf("failed to get comm event counter: %w", modbus.ErrProtocolError)
+		}
+		fmt.Printf("Comm event counter: %d%s\n", binary.BigEndian.Uint16(res[3:5]), describeCommStatus(res[1:3]))
+		return nil
+	}
+
+	if m.config.CommLog {
+		res, err := m.rawRequest([]byte{fcCommEventLog})
+		if err != nil {
+			return fmt.Errorf("failed to get comm event log: %w", err)
+		}
+		if len(res) < 8 || int(res[1]) != len(res)-2 {
+			return fmt.Errorf("failed to get comm event log: %w", modbus.ErrProtocolError)
+		}
+		fmt.Printf("Comm event counter: %d%s\n", binary.BigEndian.Uint16(res[4:6]), describeCommStatus(res[2:4]))
+		fmt.Printf("Message count: %d\n", binary.BigEndian.Uint16(res[6:8]))
+		events := res[8:]
+		if len(events) == 0 {
+			fmt.Println("  no events logged")
+		}
+		for i, event := range events {
+			fmt.Printf("  %2d: 0x%02X %s\n", i, event, describeCommEvent(event))
+		}
+		return nil
+	}
+
+	if m.config.ExceptionStatus {
+		res, err := m.rawRequest([]byte{fcReadExceptionStatus})
+		if err != nil {
+			return fmt.Errorf("failed to read exception status: %w", err)
+		}
+		if len(res) != 2 {
+			return fmt.Errorf("failed to read exception status: %w", modbus.ErrProtocolError)
+		}
+		fmt.Printf("Exception status: 0x%02X\n", res[1])
+		m.printBits(uint16(res[1]), 8)
+		return nil
+	}
+
+	sub, data := m.config.DiagSub, m.config.DiagData
+	name, ok := diagSubFunctions[sub]
+	if !ok {
+		name = "sub-function " + strconv.Itoa(int(sub))
+	}
+	if diagChangesState(sub) {
+		if err := m.config.checkWrite(time.Now()); err != nil {
+			return fmt.Errorf("refusing to write: %v", err)
+		}
+	}
+
+	req := []byte{fcDiagnostics}
+	req = binary.BigEndian.AppendUint16(req, sub)
+	req = binary.BigEndian.AppendUint16(req, data)
+
+	res, err := m.rawRequest(req)
+	if err != nil {
+		// Devices never answer the request that puts them in listen only mode
+		if sub == diagForceListenOnly && errors.Is(err, modbus.ErrRequestTimedOut) {
+			fmt.Printf("Diagnostics 0x%02X %s: sent (no response expected)\n", sub, name)
+			return nil
+		}
+		return fmt.Errorf("diagnostics %s failed: %w", name, err)
+	}
+	if len(res) != 5 || binary.BigEndian.Uint16(res[1:3]) != sub {
+		return fmt.Errorf("diagnostics %s failed: %w", name, modbus.ErrProtocolError)
+	}
+	value := binary.BigEndian.Uint16(res[3:5])
+
+	switch {
+	case sub == diagReturnQueryData:
+		if value != data {
+			return fmt.Errorf("diagnostics %s failed: sent 0x%04X, echoed 0x%04X", name, data, value)
+		}
+		fmt.Printf("Diagnostics 0x%02X %s: 0x%04X echoed\n", sub, name, value)
+	case sub == diagReturnDiagnosticReg:
+		fmt.Printf("Diagnostics 0x%02X %s: 0x%04X\n", sub, name, value)
+		m.printBits(value, 16)
+	case sub >= 0x0b && sub <= 0x12:
+		fmt.Printf("Diagnostics 0x%02X %s: %d\n", sub, name, value)
+	default:
+		fmt.Printf("Diagnostics 0x%02X %s: ok (0x%04X)\n", sub, name, value)
+	}
+	return nil
+}
+
+// printBits lists the set bits of a status value with their --bit-names.
+func (m *ModbusCLI) printBits(value uint16, width int) {
+	var set []int
+	for bit := 0; bit < width; bit++ {
+		if value&(1<<bit) != 0 {
+			set = append(set, bit)
+		}
+	}
+	if len(set) == 0 {
+		fmt.Println("  no bits set")
+		return
+	}
+
+	for _, bit := range set {
+		if name, ok := m.config.BitNames[bit]; ok {
+			fmt.Printf("  bit %2d: %s\n", bit, name)
+		} else {
+			fmt.Printf("  bit %2d: set\n", bit)
+		}
+	}
+}
+
+// describeServerID decodes a Report Server ID (FC17) response: a
+// device-specific id followed by the run indicator status byte.
+func describeServerID(res []byte) (string, error) {
+	if len(res) < 3 || int(res[1]) != len(res)-2 {
+		return "", modbus.ErrProtocolError
+	}
+	data := res[2:]
+	id, run := data[:len(data)-1], data[len(data)-1]
+
+	desc := "0x" + strings.ToUpper(hex.EncodeToString(id))
+	if len(id) == 0 {
+		desc = "(empty)"
+	}
+	if printable := strings.TrimRight(string(id), "\x00 "); printable != "" && isPrintableASCII(printable) {
+		desc += fmt.Sprintf(" (%q)", printable)
+	}
+
+	switch run {
+	case 0x00:
+		desc += ", run indicator off"
+	case 0xff:
+		desc += ", run indicator on"
+	default:
+		desc += fmt.Sprintf(", run indicator 0x%02X", run)
+	}
+	return desc, nil
+}
+
+// describeCommStatus describes the status word of a comm event counter or
+// log response, which is 0xFFFF while the device is still processing a
+// previous program command.
+func describeCommStatus(status []byte) string {
+	if binary.BigEndian.Uint16(status) == 0xffff {
+		return " (device busy)"
+	}
+	return ""
+}
+
+// commReceiveBits and commSendBits name the flags of receive and send events
+// in a comm event log.
+var (
+	commReceiveBits = map[int]string{
+		1: "communication error",
+		4: "character overrun",
+		5: "in listen only mode",
+		6: "broadcast received",
+	}
+	commSendBits = map[int]string{
+		0: "read exception sent",
+		1: "abort exception sent",
+		2: "busy exception sent",
+		3: "NAK exception sent",
+		4: "write timeout",
+		5: "in listen only mode",
+	}
+)
+
+// describeCommEvent decodes one byte of a comm event log (FC12). The log
+// tells requests the device received garbled or never answered apart from
+// ones lost before they reached it.
+func describeCommEvent(event byte) string {
+	var kind string
+	var bits map[int]string
+	switch {
+	case event&0x80 != 0:
+		kind, bits = "receive", commReceiveBits
+	case event&0x40 != 0:
+		kind, bits = "send", commSendBits
+	case event == 0x04:
+		return "entered listen only mode"
+	case event == 0x00:
+		return "communication restart"
+	default:
+		return "unknown event"
+	}
+
+	var flags []string
+	for bit := 0; bit < 7; bit++ {
+		if name, ok := bits[bit]; ok && event&(1<<bit) != 0 {
+			flags = append(flags, name)
+		}
+	}
+	if len(flags) == 0 {
+		return kind
+	}
+	return kind + ": " + strings.Join(flags, ", ")
+}
+
+// isPrintableASCII reports whether s consists of printable ASCII only.
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// deviceIDObjects names the basic Read Device Identification objects.
+var deviceIDObjects = map[byte]string{
+	0x00: "vendor",
+	0x01: "product",
+	0x02: "revision",
+}
+
+// describeDeviceID decodes a basic Read Device Identification (FC43/14)
+// response into its vendor, product code and revision.
+func describeDeviceID(res []byte) (string, error) {
+	if len(res) < 7 || res[1] != meiReadDeviceID {
+		return "", modbus.ErrProtocolError
+	}
+
+	var parts []string
+	objects, rest := int(res[6]), res[7:]
+	for i := 0; i < objects; i++ {
+		if len(rest) < 2 || len(rest) < 2+int(rest[1]) {
+			return "", modbus.ErrProtocolError
+		}
+		id, value := rest[0], string(rest[2:2+int(rest[1])])
+		rest = rest[2+int(rest[1]):]
+
+		name, ok := deviceIDObjects[id]
+		if !ok {
+			name = fmt.Sprintf("object 0x%02X", id)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%q", name, value))
+	}
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// runEncode implements "gomodbus encode [-t TYPE] [-r REF] [--truncate]
+// VALUES...", the inverse of decode: it prints the references and raw words
+// a write of the values would send, without a device connection, for
+// documentation and for tools that take raw registers.
+func runEncode(args []string) error {
+	config := defaultConfig()
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-t", "--type":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			dataType, exponent, fixed, err := parseFixedType(args[i+1])
+			if err != nil {
+				return err
+			}
+			config.DataType = dataType
+			if fixed {
+				config.FixedExponent = exponent
+			}
+			i++
+		case "-r", "--reference":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			ref, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid reference: %v", err)
+			}
+			config.StartRef = ref
+			i++
+		case "--truncate":
+			config.Truncate = true
+		default:
+			if !isWriteValue(arg) {
+				return fmt.Errorf("unknown encode option: %s", arg)
+			}
+			config.WriteArgs = append(config.WriteArgs, arg)
+		}
+	}
+	if len(config.WriteArgs) == 0 {
+		return fmt.Errorf("usage: gomodbus encode [-t TYPE] [-r REF] [--truncate] VALUES...")
+	}
+	table := config.DataType[:1]
+	if table != "0" && table != "4" {
+		return fmt.Errorf("write operations not supported for data type: %s", config.DataType)
+	}
+
+	m := &ModbusCLI{config: config}
+	restore, err := m.expandWriteArgs()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	raw, err := m.encodeWriteRaw(table)
+	if err != nil {
+		return err
+	}
+	// Each value is noted on its first word, so the listing can be checked
+	// by eye
+	words := config.wordsPerValue()
+	for i, word := range raw {
+		line := strconv.AppendUint(m.beginValue(config.StartRef+i), uint64(word), 10)
+		if table == "4" {
+			line = append(appendHex4(append(line, " (0x"...), word), ')')
+		}
+		note := ""
+		if i%words == 0 {
+			note = config.WriteArgs[i/words]
+		}
+		m.endValue(line, note)
+	}
+	return nil
+}
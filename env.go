@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// envPrefix marks environment variables that configure gomodbus. Each one
+// maps onto the long option of the same name: GOMODBUS_POLL_RATE=500 is
+// --poll-rate 500. GOMODBUS_TARGET stands in for the positional
+// DEVICE|HOST. Neither write values nor the options that write on their
+// own can come from the environment, so a stray variable never turns a
+// read into a write.
+const envPrefix = "GOMODBUS_"
+
+// envTarget sets the target when the command line gives none.
+const envTarget = "GOMODBUS_TARGET"
+
+// envWriteSources are the options that write without write values, reading
+// them from a file or generating them, or that repeat a write.
+var envWriteSources = map[string]bool{
+	"--from":       true,
+	"--replay":     true,
+	"--write-loop": true,
+	"--fanout":     true,
+	"--repeat":     true,
+}
+
+// envRepeatable are the options whose values add up when repeated. Given on
+// the command line, they replace the environment's values instead.
+var envRepeatable = map[string]bool{
+	"--label":        true,
+	"--write-window": true,
+	"--sweep":        true,
+}
+
+// envArgs turns the GOMODBUS_* environment variables into command-line
+// options. They are parsed ahead of the real arguments cmdline, so options
+// given on the command line take precedence. Variables that name no option,
+// such as one meant for a wrapper script, are skipped with a warning.
+func envArgs(cmdline []string) ([]string, error) {
+	var args, names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, envPrefix) && name != envTarget {
+			names = append(names, name)
+		}
+	}
+	// os.Environ order is unspecified; keep repeated runs deterministic
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := os.Getenv(name)
+		option := "--" + strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, envPrefix), "_", "-"))
+
+		kind, known := options[option]
+		switch {
+		case !known:
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: gomodbus has no %s option\n", name, option)
+		case envWriteSources[option]:
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %s writes, so it can only be given on the command line\n", name, option)
+		case envRepeatable[option] && slices.Contains(cmdline, option):
+			// The command line's values replace the environment's
+		case kind == optionSwitch:
+			switch strings.ToLower(value) {
+			case "1", "true", "yes", "on":
+				args = append(args, option)
+			case "", "0", "false", "no", "off":
+			default:
+				return nil, fmt.Errorf("invalid value for %s: %q (expected true or false)", name, value)
+			}
+		case kind == optionValue:
+			args = append(args, option, value)
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %s can't be set from the environment\n", name, option)
+		}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every GOMODBUS_ variable for the duration of a test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, envPrefix) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestEnvArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		cmdline []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "value options",
+			env:  map[string]string{"GOMODBUS_POLL_RATE": "500", "GOMODBUS_TYPE": "4:float"},
+			want: []string{"--poll-rate", "500", "--type", "4:float"},
+		},
+		{
+			name: "switches",
+			env:  map[string]string{"GOMODBUS_ONCE": "true", "GOMODBUS_VERBOSE": "off", "GOMODBUS_READ_ONLY": "1"},
+			want: []string{"--once", "--read-only"},
+		},
+		{
+			name:    "invalid switch value",
+			env:     map[string]string{"GOMODBUS_ONCE": "maybe"},
+			wantErr: true,
+		},
+		{
+			name: "unknown variables are skipped",
+			env:  map[string]string{"GOMODBUS_DEBUG": "1", "GOMODBUS_COUNT": "4"},
+			want: []string{"--count", "4"},
+		},
+		{
+			name: "write values and exiting options are skipped",
+			env:  map[string]string{"GOMODBUS_VALUES": "5", "GOMODBUS_VERSION": "2", "GOMODBUS_HELP": "true"},
+			want: nil,
+		},
+		{
+			name: "options that write are skipped",
+			env: map[string]string{"GOMODBUS_FROM": "setpoints.csv", "GOMODBUS_REPLAY": "run.csv",
+				"GOMODBUS_WRITE_LOOP": "ramp:0:100:1", "GOMODBUS_FANOUT": "true", "GOMODBUS_REPEAT": "true"},
+			want: nil,
+		},
+		{
+			name:    "repeatable options given on the command line replace the environment's",
+			env:     map[string]string{"GOMODBUS_LABEL": "a=1", "GOMODBUS_WRITE_WINDOW": "22:00-06:00", "GOMODBUS_SWEEP": "10.0.0.0/24"},
+			cmdline: []string{"--write-window", "Sat 08:00-12:00", "192.168.1.100"},
+			want:    []string{"--label", "a=1", "--sweep", "10.0.0.0/24"},
+		},
+		{
+			name: "the target is left to parseArgs",
+			env:  map[string]string{"GOMODBUS_TARGET": "192.168.1.100"},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			got, err := envArgs(tt.cmdline)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("envArgs() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsEnvPrecedence(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("GOMODBUS_WRITE_WINDOW", "22:00-06:00")
+	t.Setenv("GOMODBUS_LABEL", "pump=1")
+	t.Setenv("GOMODBUS_FROM", "setpoints.csv")
+	t.Setenv("GOMODBUS_REPEAT", "true")
+	m := &ModbusCLI{}
+
+	config, err := m.parseArgs([]string{"-a", "1,2", "--write-window", "Sat 08:00-12:00", "--label", "fan=2", "192.168.1.100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.WriteWindows) != 1 || config.WriteWindows[0].spec != "Sat 08:00-12:00" {
+		t.Errorf("write windows = %+v, want only the command line's", config.WriteWindows)
+	}
+	if want := map[int]string{2: "fan"}; !reflect.DeepEqual(config.Labels, want) {
+		t.Errorf("labels = %v, want %v", config.Labels, want)
+	}
+	if config.BatchFile != "" || config.Repeat {
+		t.Errorf("the environment started a write: --from %q, --repeat %v", config.BatchFile, config.Repeat)
+	}
+
+	// Without the option on the command line the environment's values apply
+	config, err = m.parseArgs([]string{"192.168.1.100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.WriteWindows) != 1 || config.WriteWindows[0].spec != "22:00-06:00" {
+		t.Errorf("write windows = %+v, want the environment's", config.WriteWindows)
+	}
+}
+
+func TestOptionsMatchParseArgs(t *testing.T) {
+	clearEnv(t)
+	m := &ModbusCLI{}
+	for option, kind := range options {
+		if kind != optionValue {
+			continue
+		}
+		_, err := m.parseArgs([]string{option})
+		if err == nil || !strings.Contains(err.Error(), "missing value for "+option) {
+			t.Errorf("parseArgs(%s) = %v, want a missing value error", option, err)
+		}
+	}
+	if _, err := m.parseArgs([]string{"--no-such-option", "192.168.1.100"}); err == nil {
+		t.Errorf("parseArgs accepted an unknown option")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Kinds of events published on the bus besides samples.
+const (
+	eventComm  = "comm"  // a unit stopped or started answering
+	eventAlarm = "alarm" // an --expect condition turned false or true again
+)
+
+// busEvent is what the event bus carries to its sinks: a sample of values
+// read, or a change of state. Sinks handle the kinds they know and ignore
+// the rest.
+type busEvent struct {
+	sample *pollSample
+	state  *stateEvent
+}
+
+// stateEvent is a change of the communication or alarm state of a unit.
+type stateEvent struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+	UnitID    int       `json:"unit_id"`
+	Label     string    `json:"label,omitempty"`
+	State     string    `json:"state"`            // "down" or "up"; "raised" or "cleared"
+	Detail    string    `json:"detail,omitempty"` // the error, or the condition
+}
+
+// unitState is the last communication and alarm state published for a unit,
+// so only changes go out.
+type unitState struct {
+	down  bool
+	alarm bool
+}
+
+// publishState puts a state change of the current unit on the bus.
+func (m *ModbusCLI) publishState(kind, state, detail string) {
+	if m.bus == nil {
+		return
+	}
+	m.bus.Publish(busEvent{state: &stateEvent{
+		Event:     kind,
+		Timestamp: time.Now(),
+		Source:    m.target(),
+		UnitID:    m.config.SlaveID,
+		Label:     m.tag,
+		State:     state,
+		Detail:    detail,
+	}})
+}
+
+// unitState returns the state tracked for the current unit.
+func (m *ModbusCLI) unitState() *unitState {
+	if m.states == nil {
+		m.states = make(map[int]*unitState)
+	}
+	s, ok := m.states[m.config.SlaveID]
+	if !ok {
+		s = &unitState{}
+		m.states[m.config.SlaveID] = s
+	}
+	return s
+}
+
+// noteComm publishes a comm event when a poll with outcome err changes
+// whether the unit answers. Exceptions and unstable reads are answers; a
+// unit counts as up until a poll fails.
+func (m *ModbusCLI) noteComm(err error) {
+	_, failed := classifyError(err)
+	down := failed && !isExceptionResponse(err) && !errors.Is(err, errUnstableRead)
+
+	s := m.unitState()
+	if down == s.down {
+		return
+	}
+	s.down = down
+	if down {
+		m.publishState(eventComm, "down", localizeError(err))
+	} else {
+		m.publishState(eventComm, "up", "")
+	}
+}
+
+// noteAlarm publishes an alarm event when the --expect condition src turns
+// false, and again when it holds once more.
+func (m *ModbusCLI) noteAlarm(src string, result bool) {
+	s := m.unitState()
+	if s.alarm == !result {
+		return
+	}
+	s.alarm = !result
+	if s.alarm {
+		m.publishState(eventAlarm, "raised", src)
+	} else {
+		m.publishState(eventAlarm, "cleared", src)
+	}
+}
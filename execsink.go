@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// execRestartDelay keeps a sink command that keeps failing from being
+// restarted for every sample.
+const execRestartDelay = time.Second
+
+// execSink streams every event as a line of JSON to the stdin of a command
+// started with --sink-exec, for integrations gomodbus has no sink for:
+// samples as recorded, state changes with an "event" field. The command's
+// own output goes to stderr, keeping stdout for the poll output. A command
+// that exits is restarted with a later event.
+type execSink struct {
+	args []string
+
+	mu      sync.Mutex // guards the process and writes to it
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	started time.Time
+}
+
+func newExecSink(command string) *execSink {
+	return &execSink{args: strings.Fields(command)}
+}
+
+// Name identifies the sink in warnings and drop reports.
+func (e *execSink) Name() string {
+	return e.args[0]
+}
+
+// Send writes ev to the command, starting it first if needed.
+func (e *execSink) Send(ev busEvent) error {
+	var line []byte
+	var err error
+	if ev.state != nil {
+		line, err = json.Marshal(ev.state)
+		line = append(line, '\n')
+	} else {
+		line, err = encodeNDJSON(ev.sample)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cmd == nil {
+		if err := e.startLocked(); err != nil {
+			return err
+		}
+	}
+	if _, err := e.stdin.Write(line); err != nil {
+		e.stopLocked()
+		return fmt.Errorf("sink command exited: %v", err)
+	}
+	return nil
+}
+
+func (e *execSink) startLocked() error {
+	if wait := execRestartDelay - time.Since(e.started); wait > 0 {
+		return fmt.Errorf("sink command restarting in %v", wait.Round(time.Millisecond))
+	}
+	e.started = time.Now()
+
+	cmd := exec.Command(e.args[0], e.args[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start sink command: %v", err)
+	}
+	e.cmd, e.stdin = cmd, stdin
+	return nil
+}
+
+// stopLocked closes the command's stdin, which tells it to finish, and
+// waits up to decoderTimeout for it to exit before killing it.
+func (e *execSink) stopLocked() {
+	e.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		e.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(decoderTimeout):
+		e.cmd.Process.Kill()
+		<-done
+	}
+	e.cmd, e.stdin = nil, nil
+}
+
+// Close ends the command, letting it process the samples it has read.
+func (e *execSink) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cmd != nil {
+		e.stopLocked()
+	}
+}
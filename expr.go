@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// boolExpr is a compiled --expect expression over coils or discrete inputs,
+// e.g. "(ESTOP && !RUNNING) || [12]". Operands are names given with
+// --coil-names or [REF] for a reference directly.
+type boolExpr struct {
+	src  string
+	root exprNode
+	refs []int // every reference the expression reads
+}
+
+type exprNode interface {
+	eval(bit func(ref int) bool) bool
+}
+
+type (
+	exprRef   int
+	exprConst bool
+	exprNot   struct{ x exprNode }
+	exprAnd   struct{ x, y exprNode }
+	exprOr    struct{ x, y exprNode }
+)
+
+func (e exprRef) eval(bit func(int) bool) bool   { return bit(int(e)) }
+func (e exprConst) eval(bit func(int) bool) bool { return bool(e) }
+func (e exprNot) eval(bit func(int) bool) bool   { return !e.x.eval(bit) }
+func (e exprAnd) eval(bit func(int) bool) bool   { return e.x.eval(bit) && e.y.eval(bit) }
+func (e exprOr) eval(bit func(int) bool) bool    { return e.x.eval(bit) || e.y.eval(bit) }
+
+// errExpectationFailed is returned when an --expect expression is false; the
+// process then exits with status 2 rather than 1.
+var errExpectationFailed = errors.New("expectation failed")
+
+// parseCoilNames parses a --coil-names list such as "ESTOP=1,RUNNING=2".
+func parseCoilNames(spec string) (map[string]int, error) {
+	names := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		name, refStr, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		ref, err := strconv.Atoi(strings.TrimSpace(refStr))
+		if !ok || err != nil || ref < 0 || ref > 65535 || !isExprName(name) {
+			return nil, fmt.Errorf("invalid coil name %q (expected NAME=REF)", part)
+		}
+		names[name] = ref
+	}
+	return names, nil
+}
+
+// isExprName reports whether name can be used as an operand.
+func isExprName(name string) bool {
+	if name == "" || name == "true" || name == "false" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameByte reports whether b may appear in a name: letters and _, and
+// digits after the first character.
+func isNameByte(b byte, first bool) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (!first && b >= '0' && b <= '9')
+}
+
+// parseBoolExpr compiles an --expect expression. ! binds tightest, then &&,
+// then ||.
+func parseBoolExpr(src string, names map[string]int) (*boolExpr, error) {
+	p := &exprParser{src: src, names: names}
+	root, err := p.parseOr()
+	if err == nil && p.skipSpace() < len(src) {
+		err = p.errorf("unexpected %q", src[p.pos:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", src, err)
+	}
+	return &boolExpr{src: src, root: root, refs: p.refs}, nil
+}
+
+// exprParser is a recursive descent parser for boolean expressions.
+type exprParser struct {
+	src   string
+	pos   int
+	names map[string]int
+	refs  []int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace advances past whitespace and returns the new position.
+func (p *exprParser) skipSpace() int {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.pos
+}
+
+// accept consumes token if it comes next.
+func (p *exprParser) accept(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var y exprNode
+		if y, err = p.parseAnd(); err == nil {
+			x = exprOr{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var y exprNode
+		if y, err = p.parseUnary(); err == nil {
+			x = exprAnd{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.accept("!") {
+		x, err := p.parseUnary()
+		return exprNot{x}, err
+	}
+	if p.accept("(") {
+		x, err := p.parseOr()
+		if err == nil && !p.accept(")") {
+			err = p.errorf("missing )")
+		}
+		return x, err
+	}
+	if p.accept("[") {
+		start := p.pos
+		end := strings.IndexByte(p.src[start:], ']')
+		if end < 0 {
+			return nil, p.errorf("missing ]")
+		}
+		ref, err := strconv.Atoi(strings.TrimSpace(p.src[start : start+end]))
+		if err != nil || ref < 0 || ref > 65535 {
+			return nil, p.errorf("invalid reference %q", p.src[start:start+end])
+		}
+		p.pos = start + end + 1
+		p.refs = append(p.refs, ref)
+		return exprRef(ref), nil
+	}
+
+	start := p.skipSpace()
+	for p.pos < len(p.src) && isNameByte(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	switch name {
+	case "":
+		if p.pos == len(p.src) {
+			return nil, p.errorf("unexpected end of expression")
+		}
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	case "true", "false":
+		return exprConst(name == "true"), nil
+	}
+	ref, ok := p.names[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown name %s (define it with --coil-names)", name)
+	}
+	p.refs = append(p.refs, ref)
+	return exprRef(ref), nil
+}
+
+// checkExpect evaluates the --expect expression against a block of coils or
+// discrete inputs read from startRef and prints the outcome. A false result
+// fails a single read with errExpectationFailed; while polling it is only
+// reported.
+func (m *ModbusCLI) checkExpect(startRef int, bits []bool) error {
+	expr := m.config.Expect
+	if expr == nil {
+		return nil
+	}
+	result := expr.root.eval(func(ref int) bool {
+		return bits[ref-startRef]
+	})
+
+	color := colorOK
+	if !result {
+		color = colorAlarm
+	}
+	fmt.Fprintf(m.out, "Expect %s: %s\n", expr.src, m.colored(m.out, color, strconv.FormatBool(result)))
+	m.report.assert(expr.src, result)
+	m.noteAlarm(expr.src, result)
+	if m.tests != nil {
+		outcome, message := testPass, ""
+		if !result {
+			outcome, message = testFail, expr.src+" is false"
+		}
+		m.tests.add(m.expectCaseName(), 0, outcome, message)
+	}
+	if !result && m.config.PollOnce {
+		return fmt.Errorf("%w: %s is false", errExpectationFailed, expr.src)
+	}
+	return nil
+}
+
+// expectCaseName names the test case of an --expect evaluation; while
+// polling, every read is a case of its own.
+func (m *ModbusCLI) expectCaseName() string {
+	if m.config.PollOnce {
+		return m.config.Expect.src
+	}
+	return fmt.Sprintf("%s (read %d)", m.config.Expect.src, len(m.tests.cases)+1)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCoilNames(t *testing.T) {
+	names, err := parseCoilNames("ESTOP=1, RUNNING = 2,door_2=65535")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"ESTOP": 1, "RUNNING": 2, "door_2": 65535}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+
+	for _, spec := range []string{"ESTOP", "ESTOP=x", "ESTOP=-1", "ESTOP=65536", "2ND=1", "true=1", "=1", "A-B=1"} {
+		if _, err := parseCoilNames(spec); err == nil {
+			t.Errorf("parseCoilNames(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestParseBoolExpr(t *testing.T) {
+	names := map[string]int{"ESTOP": 1, "RUNNING": 2, "DOOR": 3}
+	tests := []struct {
+		src  string
+		bits map[int]bool
+		want bool
+		refs []int
+	}{
+		{"ESTOP", map[int]bool{1: true}, true, []int{1}},
+		{"!ESTOP", map[int]bool{1: true}, false, []int{1}},
+		{"!!ESTOP", map[int]bool{1: true}, true, []int{1}},
+		{"[12]", map[int]bool{12: true}, true, []int{12}},
+		{"[ 12 ] && true", map[int]bool{12: true}, true, []int{12}},
+		{"false || ESTOP", nil, false, []int{1}},
+		// && binds tighter than ||
+		{"ESTOP || RUNNING && DOOR", map[int]bool{1: true}, true, []int{1, 2, 3}},
+		{"(ESTOP || RUNNING) && DOOR", map[int]bool{1: true}, false, []int{1, 2, 3}},
+		{"(ESTOP && !RUNNING) || [12]", map[int]bool{1: true, 2: true}, false, []int{1, 2, 12}},
+		{"!(ESTOP&&RUNNING)", map[int]bool{1: true}, true, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := parseBoolExpr(tt.src, names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.root.eval(func(ref int) bool { return tt.bits[ref] }); got != tt.want {
+				t.Errorf("eval = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(expr.refs, tt.refs) {
+				t.Errorf("refs = %v, want %v", expr.refs, tt.refs)
+			}
+		})
+	}
+}
+
+func TestParseBoolExprErrors(t *testing.T) {
+	names := map[string]int{"ESTOP": 1}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "at offset 0: unexpected end of expression"},
+		{"ESTOP &&", "at offset 8: unexpected end of expression"},
+		{"(ESTOP", "at offset 6: missing )"},
+		{"[12", "at offset 1: missing ]"},
+		{"[x]", `invalid reference "x"`},
+		{"[65536]", `invalid reference "65536"`},
+		{"ESTOP RUNNING", `at offset 6: unexpected "RUNNING"`},
+		{"ESTOP & RUNNING", `unexpected "& RUNNING"`},
+		{"RUNNING", "unknown name RUNNING (define it with --coil-names)"},
+		{"ESTOP || )", `unexpected ")"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := parseBoolExpr(tt.src, names)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpect(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	sim.coils[1] = true
+	port := startTestServer(t, sim)
+
+	out := mustRunTestClient(t, port, "-t", "0", "-r", "1", "-c", "2",
+		"--coil-names", "ESTOP=1,RUNNING=2", "--expect", "ESTOP && !RUNNING")
+	if !strings.Contains(out, "Expect ESTOP && !RUNNING: true") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	sim.coils[2] = true
+	if _, err := runTestClient(t, port, "-t", "0", "-r", "1", "-c", "2",
+		"--coil-names", "ESTOP=1,RUNNING=2", "--expect", "ESTOP && !RUNNING"); !errors.Is(err, errExpectationFailed) {
+		t.Errorf("a false expectation gave %v, want %v", err, errExpectationFailed)
+	}
+}
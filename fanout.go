@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// fanoutHost is a device a --fanout write goes to.
+type fanoutHost struct {
+	host string
+	port int
+}
+
+// fanoutHosts splits the comma separated HOST[:PORT] list a --fanout write
+// goes to; hosts without a port use -p. A serial line is a single device.
+func (c *Config) fanoutHosts() ([]fanoutHost, error) {
+	if c.Mode == "rtu" {
+		return []fanoutHost{{}}, nil
+	}
+	var hosts []fanoutHost
+	for _, entry := range strings.Split(c.Host, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return nil, fmt.Errorf("empty host in %q", c.Host)
+		}
+		host, portStr, err := net.SplitHostPort(entry)
+		if err != nil {
+			hosts = append(hosts, fanoutHost{strings.Trim(entry, "[]"), c.Port})
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", entry)
+		}
+		hosts = append(hosts, fanoutHost{host, port})
+	}
+	return hosts, nil
+}
+
+// fanoutResult is the outcome of a --fanout write to one unit of one device.
+type fanoutResult struct {
+	target string
+	err    error
+	done   bool // false for a target skipped after --fail-fast
+}
+
+// runFanout applies the write to every unit of the -a list on every device
+// of the host list, one after another, e.g. to set the same parameter on a
+// row of identical drives, and sums up which of them took it. A target that
+// fails doesn't stop the others unless --fail-fast is given.
+func (m *ModbusCLI) runFanout() error {
+	hosts, err := m.config.fanoutHosts()
+	if err != nil {
+		return err
+	}
+	units := m.config.units()
+	startRef := m.startRef()
+
+	if m.config.Verbose {
+		m.printConfig()
+	}
+
+	var results []fanoutResult
+	stopped := false
+	for _, h := range hosts {
+		if h.host != "" {
+			m.config.Host, m.config.Port = h.host, h.port
+		}
+		connected := false
+		if !stopped {
+			err = m.setupClient()
+			if err == nil {
+				err = m.connect()
+			}
+			connected = err == nil
+		}
+
+		for _, unit := range units {
+			result := fanoutResult{target: fmt.Sprintf("%s unit %d", m.target(), unit)}
+			if label, ok := m.config.Labels[unit]; ok {
+				result.target += " (" + label + ")"
+			}
+			if stopped {
+				results = append(results, result)
+				continue
+			}
+
+			result.done = true
+			result.err = err
+			if connected {
+				fmt.Fprintf(m.out, "%s:\n", result.target)
+				result.err = m.selectUnit(unit)
+				if result.err == nil {
+					result.err = m.withLock(func() error { return m.journaledWrite(startRef) })
+				}
+			}
+			if result.err != nil {
+				m.printPollError(fmt.Errorf("%s: %w", result.target, result.err))
+				stopped = m.config.FailFast
+			}
+			results = append(results, result)
+		}
+
+		if connected {
+			m.client.Close()
+		}
+	}
+
+	return m.printFanoutSummary(results)
+}
+
+// printFanoutSummary lists the outcome of every target and fails when any
+// of them didn't take the write.
+func (m *ModbusCLI) printFanoutSummary(results []fanoutResult) error {
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.target))
+	}
+
+	var failed []error
+	fmt.Fprintln(m.out, "Fanout summary:")
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case !r.done:
+			status = "skipped"
+		case r.err != nil:
+			status = "failed: " + localizeError(r.err)
+			failed = append(failed, fmt.Errorf("%s: %w", r.target, r.err))
+		}
+		fmt.Fprintf(m.out, "  %-*s  %s\n", width, r.target, status)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d targets failed, first: %w", len(failed), len(results), failed[0])
+	}
+	fmt.Fprintf(m.out, "Wrote all %d targets\n", len(results))
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxFixedExponent bounds the power of ten a fixed-point scale may be.
+const maxFixedExponent = 9
+
+// parseFixedType splits a fixed-point data type such as "4:fixed:0.01" or
+// "3:fixed32:10" into the plain data type ("4:fixed") and the decimal
+// exponent of its scale (-2). ok is false for other data types.
+func parseFixedType(dataType string) (base string, exponent int, ok bool, err error) {
+	table, rest, _ := strings.Cut(dataType, ":")
+	kind, scale, hasScale := strings.Cut(rest, ":")
+	if kind != "fixed" && kind != "fixed32" {
+		return dataType, 0, false, nil
+	}
+	base = table + ":" + kind
+	if !hasScale {
+		return "", 0, true, fmt.Errorf("data type %s needs a scale, e.g. %s:0.01", base, base)
+	}
+
+	exponent, err = parseFixedScale(scale)
+	if err != nil {
+		return "", 0, true, err
+	}
+	return base, exponent, true, nil
+}
+
+// parseFixedScale returns k for a scale of 10^k (0.001, 0.1, 1, 100, ...).
+// Only powers of ten keep values exact decimals.
+func parseFixedScale(scale string) (int, error) {
+	r, ok := new(big.Rat).SetString(scale)
+	if ok && r.Sign() > 0 {
+		for k := -maxFixedExponent; k <= maxFixedExponent; k++ {
+			if r.Cmp(new(big.Rat).SetFrac(pow10(k))) == 0 {
+				return k, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid fixed-point scale %q (expected a power of ten such as 0.01 or 10)", scale)
+}
+
+// pow10 returns the numerator and denominator of 10^k.
+func pow10(k int) (*big.Int, *big.Int) {
+	if k < 0 {
+		return big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-k)), nil)
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(k)), nil), big.NewInt(1)
+}
+
+// appendFixed appends raw × 10^exponent as an exact decimal.
+func appendFixed(dst []byte, raw int64, exponent int) []byte {
+	if exponent >= 0 {
+		dst = strconv.AppendInt(dst, raw, 10)
+		for i := 0; i < exponent; i++ {
+			dst = append(dst, '0')
+		}
+		return dst
+	}
+
+	magnitude := uint64(raw)
+	if raw < 0 {
+		dst = append(dst, '-')
+		magnitude = -magnitude
+	}
+	var buf [20]byte
+	digits := strconv.AppendUint(buf[:0], magnitude, 10)
+
+	decimals := -exponent
+	if len(digits) <= decimals {
+		dst = append(dst, '0', '.')
+		for i := len(digits); i < decimals; i++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, digits...)
+	}
+	dst = append(dst, digits[:len(digits)-decimals]...)
+	dst = append(dst, '.')
+	return append(dst, digits[len(digits)-decimals:]...)
+}
+
+// encodeFixed converts a write value to the raw integer of a fixed-point
+// register: value / 10^exponent. The value is parsed exactly, so
+// 0.1 with scale 0.01 is exactly 10. Values with more decimals than the
+// scale resolves are rejected unless truncate is set.
+func encodeFixed(value string, exponent int, bits int, truncate bool) (int64, error) {
+	r, err := parseWriteNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	num, den := pow10(exponent)
+	r.Mul(r, new(big.Rat).SetFrac(den, num))
+
+	raw := new(big.Int).Quo(r.Num(), r.Denom())
+	if !r.IsInt() && !truncate {
+		return 0, fmt.Errorf("value %s has more decimals than the fixed-point scale 1e%d resolves (use --truncate to drop them)", value, exponent)
+	}
+
+	limit := int64(math.MaxInt16)
+	if bits == 32 {
+		limit = math.MaxInt32
+	}
+	if !raw.IsInt64() || raw.Int64() > limit || raw.Int64() < -limit-1 {
+		return 0, fmt.Errorf("value %s overflows a %d-bit fixed-point value with scale 1e%d", value, bits, exponent)
+	}
+	return raw.Int64(), nil
+}
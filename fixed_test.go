@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFixedType(t *testing.T) {
+	tests := []struct {
+		dataType string
+		base     string
+		exponent int
+		ok       bool
+		wantErr  string
+	}{
+		{"4:fixed:0.01", "4:fixed", -2, true, ""},
+		{"3:fixed32:10", "3:fixed32", 1, true, ""},
+		{"4:fixed:1", "4:fixed", 0, true, ""},
+		{"4:fixed:1e-9", "4:fixed", -9, true, ""},
+		{"4:fixed:0.000000001", "4:fixed", -9, true, ""},
+		{"4:fixed32:1000000000", "4:fixed32", 9, true, ""},
+		{"4:float", "4:float", 0, false, ""},
+		{"4", "4", 0, false, ""},
+		{"4:fixed", "", 0, true, "data type 4:fixed needs a scale"},
+		{"4:fixed:1e-10", "", 0, true, `invalid fixed-point scale "1e-10"`},
+		{"4:fixed:1e10", "", 0, true, `invalid fixed-point scale "1e10"`},
+		{"4:fixed:0.5", "", 0, true, `invalid fixed-point scale "0.5"`},
+		{"4:fixed:0", "", 0, true, `invalid fixed-point scale "0"`},
+		{"4:fixed:-0.1", "", 0, true, `invalid fixed-point scale "-0.1"`},
+		{"4:fixed:x", "", 0, true, `invalid fixed-point scale "x"`},
+	}
+	for _, tt := range tests {
+		base, exponent, ok, err := parseFixedType(tt.dataType)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !ok {
+				t.Errorf("parseFixedType(%q) = %v, %v; want error %q", tt.dataType, ok, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || base != tt.base || exponent != tt.exponent || ok != tt.ok {
+			t.Errorf("parseFixedType(%q) = %q, %d, %v, %v", tt.dataType, base, exponent, ok, err)
+		}
+	}
+}
+
+func TestEncodeFixed(t *testing.T) {
+	tests := []struct {
+		value    string
+		exponent int
+		bits     int
+		truncate bool
+		want     int64
+		wantErr  string
+	}{
+		// Decimals the scale resolves are exact, not rounded through a float
+		{"0.1", -2, 16, false, 10, ""},
+		{"21.57", -2, 16, false, 2157, ""},
+		{"-21.57", -2, 16, false, -2157, ""},
+		{"1.5e1", -1, 16, false, 150, ""},
+		{"2k", 1, 16, false, 200, ""},
+		{"-0.001", -3, 32, false, -1, ""},
+		{"0", 3, 16, false, 0, ""},
+		// More decimals than the scale resolves
+		{"1.239", -2, 16, false, 0, "value 1.239 has more decimals than the fixed-point scale 1e-2 resolves"},
+		{"15", 1, 32, false, 0, "value 15 has more decimals than the fixed-point scale 1e1 resolves"},
+		{"1.239", -2, 16, true, 123, ""},
+		{"-1.239", -2, 16, true, -123, ""},
+		{"15", 1, 32, true, 1, ""},
+		// Limits of the two widths
+		{"327.67", -2, 16, false, 32767, ""},
+		{"-327.68", -2, 16, false, -32768, ""},
+		{"327.68", -2, 16, false, 0, "value 327.68 overflows a 16-bit fixed-point value with scale 1e-2"},
+		{"-327.69", -2, 16, false, 0, "value -327.69 overflows a 16-bit"},
+		{"327.68", -2, 32, false, 32768, ""},
+		{"21474836.47", -2, 32, false, 2147483647, ""},
+		{"-21474836.48", -2, 32, false, -2147483648, ""},
+		{"21474836.48", -2, 32, false, 0, "value 21474836.48 overflows a 32-bit fixed-point value with scale 1e-2"},
+		{"-21474836.49", -2, 32, false, 0, "value -21474836.49 overflows a 32-bit"},
+		{"1e30", -9, 32, false, 0, "overflows a 32-bit"},
+		{"327.679", -2, 16, true, 32767, ""},
+		{"abc", -2, 16, false, 0, "abc"},
+	}
+	for _, tt := range tests {
+		got, err := encodeFixed(tt.value, tt.exponent, tt.bits, tt.truncate)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("encodeFixed(%q, %d, %d, %v) error = %v, want %q", tt.value, tt.exponent, tt.bits, tt.truncate, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("encodeFixed(%q, %d, %d, %v) = %d, %v; want %d", tt.value, tt.exponent, tt.bits, tt.truncate, got, err, tt.want)
+		}
+	}
+}
+
+func TestAppendFixed(t *testing.T) {
+	tests := []struct {
+		raw      int64
+		exponent int
+		want     string
+	}{
+		{2157, -2, "21.57"},
+		{-2157, -2, "-21.57"},
+		{5, -3, "0.005"},
+		{-5, -3, "-0.005"},
+		{100, -2, "1.00"},
+		{0, -2, "0.00"},
+		{12, 0, "12"},
+		{-12, 2, "-1200"},
+		// The ends of the 16- and 32-bit ranges
+		{32767, -2, "327.67"},
+		{-32768, -2, "-327.68"},
+		{2147483647, -9, "2.147483647"},
+		{-2147483648, -9, "-2.147483648"},
+		{-2147483648, 9, "-2147483648000000000"},
+	}
+	for _, tt := range tests {
+		if got := string(appendFixed([]byte("x="), tt.raw, tt.exponent)); got != "x="+tt.want {
+			t.Errorf("appendFixed(%d, %d) = %q, want %q", tt.raw, tt.exponent, got, "x="+tt.want)
+		}
+	}
+
+	// Values read back as written
+	for _, value := range []string{"21.57", "-0.05", "327.67", "-327.68"} {
+		raw, err := encodeFixed(value, -2, 16, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(appendFixed(nil, raw, -2)); got != value {
+			t.Errorf("%s reads back as %s", value, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// healthState tracks whether the device is still answering polls, for the
+// /healthz endpoint and the systemd watchdog. It is shared with their
+// goroutines, so it keeps its own copy of the unit polled last rather than
+// reading the configuration the poll loop changes.
+type healthState struct {
+	staleAfter time.Duration
+
+	mu           sync.Mutex
+	target       string
+	unitID       int
+	lastResponse time.Time // last poll the device answered, even with an exception
+	lastError    error
+}
+
+// healthReport is the answer of /healthz.
+type healthReport struct {
+	Healthy bool   `json:"healthy"`
+	Target  string `json:"target"`
+	UnitID  int    `json:"unit_id"`
+	Reason  string `json:"reason"`
+}
+
+func newHealthState(staleAfter time.Duration, target string, unitID int) *healthState {
+	return &healthState{staleAfter: staleAfter, target: target, unitID: unitID}
+}
+
+// record notes the outcome of one poll of unitID at target.
+func (h *healthState) record(target string, unitID int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.target, h.unitID = target, unitID
+	h.lastError = err
+	if err == nil || isExceptionResponse(err) {
+		h.lastResponse = time.Now()
+	}
+}
+
+// healthy reports whether the device answered recently, with a reason when
+// it hasn't.
+func (h *healthState) healthy() (bool, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.check()
+}
+
+// report describes the health and the unit polled last.
+func (h *healthState) report() healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ok, reason := h.check()
+	return healthReport{Healthy: ok, Target: h.target, UnitID: h.unitID, Reason: reason}
+}
+
+// check is healthy with h.mu held.
+func (h *healthState) check() (bool, string) {
+	switch {
+	case h.lastResponse.IsZero():
+		return false, "no response from device yet"
+	case time.Since(h.lastResponse) > h.staleAfter:
+		reason := fmt.Sprintf("no response from device for %v", time.Since(h.lastResponse).Round(time.Second))
+		if h.lastError != nil {
+			reason += fmt.Sprintf(" (last error: %v)", h.lastError)
+		}
+		return false, reason
+	}
+	return true, ""
+}
+
+// serveHealth answers GET /healthz with 200 while the device is answering
+// and 503 once it has stopped.
+func (m *ModbusCLI) serveHealth(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := m.health.report()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+
+	if err := http.Serve(listener, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: health endpoint stopped: %v\n", err)
+	}
+}
+
+// sdNotify sends a state notification to systemd when running under a unit
+// with Type=notify or WatchdogSec; it does nothing otherwise.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// watchdogInterval returns how often systemd expects a watchdog ping (half
+// of WatchdogSec), or 0 when the watchdog isn't enabled for this process.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// runWatchdog pings the systemd watchdog while the device is answering, so
+// systemd restarts the poller once it has lost its target.
+func (m *ModbusCLI) runWatchdog(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if ok, _ := m.health.healthy(); ok {
+				sdNotify("WATCHDOG=1")
+			}
+		}
+	}
+}
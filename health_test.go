@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthReport(t *testing.T) {
+	h := newHealthState(time.Minute, "192.168.1.100:502", 1)
+	if r := h.report(); r.Healthy || r.Target != "192.168.1.100:502" || r.UnitID != 1 {
+		t.Errorf("before the first poll: %+v", r)
+	}
+
+	h.record("192.168.1.100:502", 2, nil)
+	if r := h.report(); !r.Healthy || r.UnitID != 2 || r.Reason != "" {
+		t.Errorf("after a good poll of unit 2: %+v", r)
+	}
+
+	// A failure doesn't make the device unhealthy until it is stale
+	h.record("192.168.1.100:502", 3, errors.New("timeout"))
+	if r := h.report(); !r.Healthy || r.UnitID != 3 {
+		t.Errorf("after one failed poll: %+v", r)
+	}
+	h.lastResponse = time.Now().Add(-2 * time.Minute)
+	if r := h.report(); r.Healthy || r.Reason == "" {
+		t.Errorf("once stale: %+v", r)
+	}
+}
+
+// TestHealthConcurrentUse is meant for go test -race: the poll loop records
+// while the HTTP handler and the watchdog read.
+func TestHealthConcurrentUse(t *testing.T) {
+	h := newHealthState(time.Minute, "192.168.1.100:502", 1)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			h.record("192.168.1.100:502", i%4+1, nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			h.report()
+			h.healthy()
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// catalog maps English messages to their translation. Keys are the English
+// text exactly as the code writes it, format verbs included, so a message
+// without a translation is simply shown in English.
+type catalog map[string]string
+
+// catalogs holds the message and help catalogs of every language besides
+// English.
+var catalogs = map[string]struct {
+	messages catalog
+	help     catalog
+}{
+	"de": {messagesDE, helpDE},
+	"zh": {messagesZH, helpZH},
+}
+
+// language is the language of help and error messages, chosen once at
+// startup with --lang or from the locale.
+var language = "en"
+
+// selectLanguage sets the language from the last --lang option in args, or
+// else from the locale environment. It runs before the options are parsed,
+// so -h prints the help in the requested language wherever --lang appears.
+func selectLanguage(args []string) error {
+	lang := localeLanguage()
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--lang" {
+			lang = args[i+1]
+			if _, ok := catalogs[lang]; !ok && lang != "en" {
+				return fmt.Errorf("unsupported language: %s (supported: en, de, zh)", lang)
+			}
+		}
+	}
+	language = lang
+	return nil
+}
+
+// localeLanguage returns the language of the POSIX locale variables when it
+// has a catalog, and English otherwise.
+func localeLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(name)
+		if locale == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(locale, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// tr returns the translation of msg in the selected language.
+func tr(msg string) string {
+	if translated, ok := catalogs[language].messages[msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// localizeError translates an error message part by part. Errors are built
+// as a chain of "context: cause" messages, most of them with variable
+// parts, so each part is looked up on its own, and a part with a
+// parenthesized detail is also looked up without it. Untranslated parts are
+// kept in English.
+func localizeError(err error) string {
+	msg := err.Error()
+	if language == "en" {
+		return msg
+	}
+
+	parts := strings.Split(msg, ": ")
+	for i, part := range parts {
+		if translated, ok := catalogs[language].messages[part]; ok {
+			parts[i] = translated
+		} else if head, detail, ok := strings.Cut(part, " ("); ok {
+			if translated, ok := catalogs[language].messages[head]; ok {
+				parts[i] = translated + " (" + detail
+			}
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+// localizeHelp replaces the blocks of the help text that have a translation.
+// Blocks are matched whole, so one whose English text has since changed
+// stays in English rather than showing an outdated translation.
+func localizeHelp(text string) string {
+	for english, translated := range catalogs[language].help {
+		text = strings.Replace(text, english, translated, 1)
+	}
+	return text
+}
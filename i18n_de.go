@@ -0,0 +1,184 @@
+package main
+
+// messagesDE translates error messages and configuration problems to German.
+var messagesDE = catalog{
+	// Requests
+	"failed to connect":                 "Verbindung fehlgeschlagen",
+	"failed to read coils":              "Lesen der Coils fehlgeschlagen",
+	"failed to read discrete inputs":    "Lesen der digitalen Eingänge fehlgeschlagen",
+	"failed to read input registers":    "Lesen der Eingangsregister fehlgeschlagen",
+	"failed to read holding registers":  "Lesen der Holding-Register fehlgeschlagen",
+	"failed to read current values":     "Lesen der aktuellen Werte fehlgeschlagen",
+	"failed to write coils":             "Schreiben der Coils fehlgeschlagen",
+	"failed to write holding registers": "Schreiben der Holding-Register fehlgeschlagen",
+	"refusing to write":                 "Schreiben abgelehnt",
+	"unknown option":                    "unbekannte Option",
+	"invalid slave address":             "ungültige Slave-Adresse",
+	"Error: %v\n":                       "Fehler: %v\n",
+	"Warning: %s\n":                     "Warnung: %s\n",
+
+	// Modbus exceptions and protocol errors
+	"request timed out":                       "Zeitüberschreitung der Anfrage (das Gerät antwortet nicht)",
+	"illegal function":                        "unzulässige Funktion (das Gerät unterstützt diesen Funktionscode nicht)",
+	"illegal data address":                    "unzulässige Datenadresse (das Register gibt es im Gerät nicht)",
+	"illegal data value":                      "unzulässiger Datenwert",
+	"server device failure":                   "Gerätefehler",
+	"request acknowledged":                    "Anfrage bestätigt, Verarbeitung läuft",
+	"server device busy":                      "Gerät ist beschäftigt",
+	"memory parity error":                     "Speicherparitätsfehler",
+	"gateway path unavailable":                "Gateway-Pfad nicht verfügbar",
+	"gateway target device failed to respond": "Zielgerät hinter dem Gateway antwortet nicht",
+	"bad crc":                     "CRC-Fehler (Störung auf der Leitung oder falsche Baudrate/Parität)",
+	"short frame":                 "Telegramm zu kurz",
+	"protocol error":              "Protokollfehler",
+	"bad unit id":                 "falsche Unit-ID in der Antwort",
+	"bad transaction id":          "falsche Transaktions-ID in der Antwort",
+	"unknown protocol identifier": "unbekannte Protokollkennung",
+	"unexpected parameters":       "unerwartete Parameter",
+	"configuration error":         "Konfigurationsfehler",
+
+	// Network and file errors
+	"connection refused":        "Verbindung abgelehnt (läuft auf dem Port ein Modbus-Server?)",
+	"i/o timeout":               "Zeitüberschreitung",
+	"no such host":              "Host nicht gefunden",
+	"no route to host":          "keine Route zum Host",
+	"network is unreachable":    "Netzwerk nicht erreichbar",
+	"connection reset by peer":  "Verbindung von der Gegenstelle zurückgesetzt",
+	"permission denied":         "Zugriff verweigert",
+	"no such file or directory": "Datei oder Verzeichnis nicht gefunden",
+
+	// Configuration problems
+	"%d configuration problems:":                                                   "%d Konfigurationsprobleme:",
+	"device or host parameter missing ! Try -h for help":                           "Gerät oder Host fehlt! Hilfe mit -h",
+	"port must be between 1 and 65535":                                             "Der Port muss zwischen 1 und 65535 liegen",
+	"baudrate must be between 1200 and 921600":                                     "Die Baudrate muss zwischen 1200 und 921600 liegen",
+	"databits must be 7 or 8":                                                      "Die Datenbits müssen 7 oder 8 sein",
+	"stopbits must be 1 or 2":                                                      "Die Stoppbits müssen 1 oder 2 sein",
+	"parity must be none, even, or odd":                                            "Die Parität muss none, even oder odd sein",
+	"slave address must be between 0 and 255":                                      "Die Slave-Adresse muss zwischen 0 und 255 liegen",
+	"unsupported data type: %s":                                                    "Nicht unterstützter Datentyp: %s",
+	"count must be at least 1":                                                     "Die Anzahl muss mindestens 1 sein",
+	"write operations not supported for data type: %s":                             "Schreiben wird für den Datentyp %s nicht unterstützt",
+	"unsupported mode: %s (supported: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp)": "Nicht unterstützter Modus: %s (unterstützt: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp)",
+	"count must be between 1 and %d for coils and discrete inputs":                 "Die Anzahl muss bei Coils und digitalen Eingängen zwischen 1 und %d liegen",
+
+	// Read-only tables
+	"discrete inputs (-t 1) are read-only: the Modbus data model has no function to write them; to write bits, use coils (-t 0)":                                                                                           "Digitale Eingänge (-t 1) sind nur lesbar: Das Modbus-Datenmodell kennt keine Funktion, sie zu schreiben; Bits schreibt man in Coils (-t 0)",
+	"input registers (-t %s) are read-only: the Modbus data model has no function to write them; write the holding registers with -t 4%s, or add --treat-as-holding if a gateway maps these values onto holding registers": "Input-Register (-t %s) sind nur lesbar: Das Modbus-Datenmodell kennt keine Funktion, sie zu schreiben; schreiben Sie die Holding-Register mit -t 4%s, oder geben Sie --treat-as-holding an, wenn ein Gateway diese Werte auf Holding-Register abbildet",
+	"--treat-as-holding applies to the input register types (-t 3...)": "--treat-as-holding gilt nur für die Input-Register-Typen (-t 3...)",
+}
+
+// helpDE translates blocks of the help text to German.
+var helpDE = catalog{
+	"gomodbus - Enhanced Modbus CLI tool": "gomodbus - Erweitertes Modbus-Kommandozeilenwerkzeug",
+
+	"\nUSAGE:\n":           "\nAUFRUF:\n",
+	"\nARGUMENTS:\n":       "\nARGUMENTE:\n",
+	"\nGENERAL OPTIONS:\n": "\nALLGEMEINE OPTIONEN:\n",
+	"\nTCP OPTIONS:\n":     "\nTCP-OPTIONEN:\n",
+	"\nEXPRESSION OPTIONS (coils and discrete inputs):\n": "\nAUSDRUCKSOPTIONEN (Coils und digitale Eingänge):\n",
+	"\nDIAGNOSTIC OPTIONS (Modbus TCP only):\n":           "\nDIAGNOSEOPTIONEN (nur Modbus TCP):\n",
+	"\nDISCOVERY OPTIONS:\n":                              "\nSUCHOPTIONEN:\n",
+	"\nPROXY OPTIONS:\n":                                  "\nPROXY-OPTIONEN:\n",
+	"\nOUTPUT OPTIONS:\n":                                 "\nAUSGABEOPTIONEN:\n",
+	"\nRTU OPTIONS:\n":                                    "\nRTU-OPTIONEN:\n",
+	"\nOTHER OPTIONS:\n":                                  "\nSONSTIGE OPTIONEN:\n",
+	"\nENVIRONMENT:\n":                                    "\nUMGEBUNG:\n",
+	"\nEXAMPLES:\n":                                       "\nBEISPIELE:\n",
+
+	`  DEVICE        Serial port when using Modbus RTU protocol
+                (e.g., /dev/ttyUSB0, COM1)
+  HOST          Host name or IP address when using Modbus TCP protocol
+  WRITE_VALUES  List of values to be written (if not specified, reads data)
+                Accepts scientific notation (1.5e3) and the suffixes
+                k, M and G (2k = 2000, 3.3M = 3300000). Values follow
+                DEVICE|HOST, --values, -- (needed for -1, which is
+                otherwise the --once option) or unit://UNIT, which also
+                selects the slave like -a (unit://3 120 writes 120 to
+                unit 3 of a multi-drop line)`: `  DEVICE        Serielle Schnittstelle beim Modbus-RTU-Protokoll
+                (z. B. /dev/ttyUSB0, COM1)
+  HOST          Hostname oder IP-Adresse beim Modbus-TCP-Protokoll
+  WRITE_VALUES  Zu schreibende Werte (ohne Angabe werden Daten gelesen)
+                Wissenschaftliche Schreibweise (1.5e3) und die Suffixe
+                k, M und G (2k = 2000, 3.3M = 3300000) sind erlaubt. Werte
+                folgen auf DEVICE|HOST, --values, -- (nötig für -1, sonst
+                die Option --once) oder unit://UNIT, das wie -a den Slave
+                wählt (unit://3 120 schreibt 120 an Gerät 3 einer
+                Multi-Drop-Leitung)`,
+
+	"  -m, --mode MODE         Mode: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp (default: tcp)": "  -m, --mode MODE         Modus: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp (Standard: tcp)",
+	`  -a, --address ADDR      Slave address (1-255, default: 1); a comma separated
+                          list polls each slave in turn`: `  -a, --address ADDR      Slave-Adresse (1-255, Standard: 1); eine durch Kommas
+                          getrennte Liste fragt jeden Slave reihum ab`,
+	"  -r, --reference REF     Start reference (default: 1)": "  -r, --reference REF     Startreferenz (Standard: 1)",
+	`  -c, --count COUNT       Number of values to read (1-2000 for coils and
+                          discrete inputs, default: 1), or "auto" to derive
+                          it from the write values; reads of more than 125
+                          registers are split into several requests`: `  -c, --count COUNT       Anzahl der zu lesenden Werte (1-2000 bei Coils und
+                          digitalen Eingängen, Standard: 1) oder "auto", um
+                          sie aus den Schreibwerten abzuleiten; mehr als 125
+                          Register werden in mehreren Anfragen gelesen`,
+	`  -t, --type TYPE         Data type:
+                            0 = Discrete output (coil)
+                            1 = Discrete input
+                            3 = 16-bit input register
+                            3:hex = 16-bit input register (hex display)
+                            3:int = 32-bit integer in input register
+                            3:float = 32-bit float in input register
+                            3:fixed:SCALE = 16-bit signed fixed-point in
+                              input register, SCALE a power of ten (0.01)
+                            3:fixed32:SCALE = 32-bit fixed-point
+                            4 = 16-bit output (holding) register (default)
+                            4:hex = 16-bit output register (hex display)
+                            4:int = 32-bit integer in output register
+                            4:float = 32-bit float in output register
+                            4:fixed:SCALE, 4:fixed32:SCALE = fixed-point in
+                              output register`: `  -t, --type TYPE         Datentyp:
+                            0 = Digitaler Ausgang (Coil)
+                            1 = Digitaler Eingang
+                            3 = 16-Bit-Eingangsregister
+                            3:hex = 16-Bit-Eingangsregister (Hex-Anzeige)
+                            3:int = 32-Bit-Ganzzahl im Eingangsregister
+                            3:float = 32-Bit-Gleitkommazahl im Eingangsregister
+                            3:fixed:SCALE = 16-Bit-Festkommazahl mit Vorzeichen
+                              im Eingangsregister, SCALE eine Zehnerpotenz (0.01)
+                            3:fixed32:SCALE = 32-Bit-Festkommazahl
+                            4 = 16-Bit-Ausgangsregister (Holding) (Standard)
+                            4:hex = 16-Bit-Ausgangsregister (Hex-Anzeige)
+                            4:int = 32-Bit-Ganzzahl im Ausgangsregister
+                            4:float = 32-Bit-Gleitkommazahl im Ausgangsregister
+                            4:fixed:SCALE, 4:fixed32:SCALE = Festkommazahl im
+                              Ausgangsregister`,
+	"  -0, --zero-based        First reference is 0 (PDU addressing)":           "  -0, --zero-based        Erste Referenz ist 0 (PDU-Adressierung)",
+	"  -B, --big-endian        Big endian word order for 32-bit data (default)": "  -B, --big-endian        Wortreihenfolge Big Endian für 32-Bit-Daten (Standard)",
+	"  -1, --once              Poll only once, otherwise poll continuously":     "  -1, --once              Nur einmal abfragen, sonst fortlaufend",
+	"  -l, --poll-rate MS      Poll rate in milliseconds (default: 1000)":       "  -l, --poll-rate MS      Abfrageintervall in Millisekunden (Standard: 1000)",
+	"  -o, --timeout SEC       Timeout in seconds (default: 1.0)":               "  -o, --timeout SEC       Zeitlimit in Sekunden (Standard: 1.0)",
+	"  --values V1 [V2...]     Write values (space or comma separated)":         "  --values V1 [V2...]     Schreibwerte (durch Leerzeichen oder Kommas getrennt)",
+	"  -p, --port PORT         TCP port number (default: 502)":                  "  -p, --port PORT         TCP-Portnummer (Standard: 502)",
+	"  -b, --baudrate RATE     Baudrate (1200-921600, default: 19200)":          "  -b, --baudrate RATE     Baudrate (1200-921600, Standard: 19200)",
+	"  -d, --databits BITS     Databits (7 or 8, default: 8)":                   "  -d, --databits BITS     Datenbits (7 oder 8, Standard: 8)",
+	"  -s, --stopbits BITS     Stopbits (1 or 2, default: 1)":                   "  -s, --stopbits BITS     Stoppbits (1 oder 2, Standard: 1)",
+	"  -P, --parity PARITY     Parity: none, even, odd (default: even)":         "  -P, --parity PARITY     Parität: none, even, odd (Standard: even)",
+	"  -v, --verbose           Verbose mode":                                    "  -v, --verbose           Ausführliche Ausgabe",
+	"  -h, --help              Show this help message":                          "  -h, --help              Diese Hilfe anzeigen",
+	"  -V, --version           Show version information":                        "  -V, --version           Versionsinformationen anzeigen",
+	`  --read-only             Refuse all write operations, including proxy
+                          client requests other than reads`: `  --read-only             Alle Schreibvorgänge ablehnen, auch alle
+                          Anfragen von Proxy-Clients außer Lesezugriffen`,
+	`  --lang LANG             Language of help and error messages: en, de or zh
+                          (default: from the locale, otherwise en)`: `  --lang LANG             Sprache von Hilfe und Fehlermeldungen: en, de oder
+                          zh (Standard: aus der Locale, sonst en)`,
+
+	"  # Read 2 holding registers starting at address 1 from TCP device": "  # 2 Holding-Register ab Adresse 1 von einem TCP-Gerät lesen",
+	"  # Read input registers as 32-bit floats from RTU device":          "  # Eingangsregister als 32-Bit-Gleitkommazahlen von einem RTU-Gerät lesen",
+	"  # Write values to holding registers":                              "  # Werte in Holding-Register schreiben",
+	"  # Write 32-bit integers to holding registers":                     "  # 32-Bit-Ganzzahlen in Holding-Register schreiben",
+	"  # Write 32-bit floats to holding registers":                       "  # 32-Bit-Gleitkommazahlen in Holding-Register schreiben",
+	"  # Write coils\n":                                            "  # Coils schreiben\n",
+	"  # Poll coils continuously":                                  "  # Coils fortlaufend abfragen",
+	"  # Use RTU over TCP (tunneled serial)":                       "  # RTU über TCP verwenden (getunnelte serielle Verbindung)",
+	"  # Use Modbus TCP over UDP":                                  "  # Modbus TCP über UDP verwenden",
+	"  # Use Modbus TCP over TLS":                                  "  # Modbus TCP über TLS verwenden",
+	"  # Share one PLC connection between many clients, read-only": "  # Eine SPS-Verbindung mit vielen Clients teilen, nur lesend",
+}
@@ -0,0 +1,182 @@
+package main
+
+// messagesZH translates error messages and configuration problems to
+// Simplified Chinese.
+var messagesZH = catalog{
+	// Requests
+	"failed to connect":                 "连接失败",
+	"failed to read coils":              "读取线圈失败",
+	"failed to read discrete inputs":    "读取离散输入失败",
+	"failed to read input registers":    "读取输入寄存器失败",
+	"failed to read holding registers":  "读取保持寄存器失败",
+	"failed to read current values":     "读取当前值失败",
+	"failed to write coils":             "写入线圈失败",
+	"failed to write holding registers": "写入保持寄存器失败",
+	"refusing to write":                 "拒绝写入",
+	"unknown option":                    "未知选项",
+	"invalid slave address":             "无效的从站地址",
+	"Error: %v\n":                       "错误: %v\n",
+	"Warning: %s\n":                     "警告: %s\n",
+
+	// Modbus exceptions and protocol errors
+	"request timed out":                       "请求超时(设备无应答)",
+	"illegal function":                        "非法功能(设备不支持该功能码)",
+	"illegal data address":                    "非法数据地址(设备中不存在该寄存器)",
+	"illegal data value":                      "非法数据值",
+	"server device failure":                   "从站设备故障",
+	"request acknowledged":                    "请求已确认,正在处理",
+	"server device busy":                      "从站设备忙",
+	"memory parity error":                     "存储器奇偶校验错误",
+	"gateway path unavailable":                "网关路径不可用",
+	"gateway target device failed to respond": "网关后的目标设备无应答",
+	"bad crc":                     "CRC 错误(线路干扰或波特率/校验设置错误)",
+	"short frame":                 "帧过短",
+	"protocol error":              "协议错误",
+	"bad unit id":                 "应答中的单元 ID 错误",
+	"bad transaction id":          "应答中的事务 ID 错误",
+	"unknown protocol identifier": "未知的协议标识符",
+	"unexpected parameters":       "意外的参数",
+	"configuration error":         "配置错误",
+
+	// Network and file errors
+	"connection refused":        "连接被拒绝(该端口上是否运行着 Modbus 服务器?)",
+	"i/o timeout":               "超时",
+	"no such host":              "找不到主机",
+	"no route to host":          "没有到主机的路由",
+	"network is unreachable":    "网络不可达",
+	"connection reset by peer":  "连接被对方重置",
+	"permission denied":         "权限不足",
+	"no such file or directory": "文件或目录不存在",
+
+	// Configuration problems
+	"%d configuration problems:":                                                   "%d 个配置问题:",
+	"device or host parameter missing ! Try -h for help":                           "缺少设备或主机参数!使用 -h 查看帮助",
+	"port must be between 1 and 65535":                                             "端口必须在 1 到 65535 之间",
+	"baudrate must be between 1200 and 921600":                                     "波特率必须在 1200 到 921600 之间",
+	"databits must be 7 or 8":                                                      "数据位必须为 7 或 8",
+	"stopbits must be 1 or 2":                                                      "停止位必须为 1 或 2",
+	"parity must be none, even, or odd":                                            "校验必须为 none、even 或 odd",
+	"slave address must be between 0 and 255":                                      "从站地址必须在 0 到 255 之间",
+	"unsupported data type: %s":                                                    "不支持的数据类型:%s",
+	"count must be at least 1":                                                     "数量必须至少为 1",
+	"write operations not supported for data type: %s":                             "数据类型 %s 不支持写操作",
+	"unsupported mode: %s (supported: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp)": "不支持的模式:%s(支持:tcp、tls、udp、rtu、rtuovertcp、rtuoverudp)",
+	"count must be between 1 and %d for coils and discrete inputs":                 "线圈和离散输入的数量必须在 1 到 %d 之间",
+
+	// Read-only tables
+	"discrete inputs (-t 1) are read-only: the Modbus data model has no function to write them; to write bits, use coils (-t 0)":                                                                                           "离散输入 (-t 1) 是只读的:Modbus 数据模型中没有写入它们的功能码;要写入位,请使用线圈 (-t 0)",
+	"input registers (-t %s) are read-only: the Modbus data model has no function to write them; write the holding registers with -t 4%s, or add --treat-as-holding if a gateway maps these values onto holding registers": "输入寄存器 (-t %s) 是只读的:Modbus 数据模型中没有写入它们的功能码;请用 -t 4%s 写入保持寄存器,如果网关把这些值映射到了保持寄存器上,请加上 --treat-as-holding",
+	"--treat-as-holding applies to the input register types (-t 3...)": "--treat-as-holding 仅适用于输入寄存器类型 (-t 3...)",
+}
+
+// helpZH translates blocks of the help text to Simplified Chinese.
+var helpZH = catalog{
+	"gomodbus - Enhanced Modbus CLI tool": "gomodbus - 增强型 Modbus 命令行工具",
+
+	"\nUSAGE:\n":           "\n用法:\n",
+	"\nARGUMENTS:\n":       "\n参数:\n",
+	"\nGENERAL OPTIONS:\n": "\n通用选项:\n",
+	"\nTCP OPTIONS:\n":     "\nTCP 选项:\n",
+	"\nEXPRESSION OPTIONS (coils and discrete inputs):\n": "\n表达式选项(线圈和离散输入):\n",
+	"\nDIAGNOSTIC OPTIONS (Modbus TCP only):\n":           "\n诊断选项(仅限 Modbus TCP):\n",
+	"\nDISCOVERY OPTIONS:\n":                              "\n设备发现选项:\n",
+	"\nPROXY OPTIONS:\n":                                  "\n代理选项:\n",
+	"\nOUTPUT OPTIONS:\n":                                 "\n输出选项:\n",
+	"\nRTU OPTIONS:\n":                                    "\nRTU 选项:\n",
+	"\nOTHER OPTIONS:\n":                                  "\n其他选项:\n",
+	"\nENVIRONMENT:\n":                                    "\n环境变量:\n",
+	"\nEXAMPLES:\n":                                       "\n示例:\n",
+
+	`  DEVICE        Serial port when using Modbus RTU protocol
+                (e.g., /dev/ttyUSB0, COM1)
+  HOST          Host name or IP address when using Modbus TCP protocol
+  WRITE_VALUES  List of values to be written (if not specified, reads data)
+                Accepts scientific notation (1.5e3) and the suffixes
+                k, M and G (2k = 2000, 3.3M = 3300000). Values follow
+                DEVICE|HOST, --values, -- (needed for -1, which is
+                otherwise the --once option) or unit://UNIT, which also
+                selects the slave like -a (unit://3 120 writes 120 to
+                unit 3 of a multi-drop line)`: `  DEVICE        使用 Modbus RTU 协议时的串口
+                (例如 /dev/ttyUSB0、COM1)
+  HOST          使用 Modbus TCP 协议时的主机名或 IP 地址
+  WRITE_VALUES  要写入的值(未指定时读取数据)
+                支持科学计数法 (1.5e3) 以及后缀 k、M 和 G
+                (2k = 2000, 3.3M = 3300000)。值跟在 DEVICE|HOST、
+                --values、--(写入 -1 时必须这样做,否则它会被当作
+                --once 选项)或 unit://UNIT 之后,unit://UNIT 与 -a
+                一样选择从站(unit://3 120 向多点总线上的 3 号设备写入 120)`,
+
+	"  -m, --mode MODE         Mode: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp (default: tcp)": "  -m, --mode MODE         模式:tcp、tls、udp、rtu、rtuovertcp、rtuoverudp(默认:tcp)",
+	`  -a, --address ADDR      Slave address (1-255, default: 1); a comma separated
+                          list polls each slave in turn`: `  -a, --address ADDR      从站地址(1-255,默认:1);用逗号分隔的列表
+                          会依次轮询每个从站`,
+	"  -r, --reference REF     Start reference (default: 1)": "  -r, --reference REF     起始地址(默认:1)",
+	`  -c, --count COUNT       Number of values to read (1-2000 for coils and
+                          discrete inputs, default: 1), or "auto" to derive
+                          it from the write values; reads of more than 125
+                          registers are split into several requests`: `  -c, --count COUNT       要读取的值的数量(线圈和离散输入为 1-2000,
+                          默认:1),或用 "auto" 根据写入值自动确定;
+                          超过 125 个寄存器时分多次请求读取`,
+	`  -t, --type TYPE         Data type:
+                            0 = Discrete output (coil)
+                            1 = Discrete input
+                            3 = 16-bit input register
+                            3:hex = 16-bit input register (hex display)
+                            3:int = 32-bit integer in input register
+                            3:float = 32-bit float in input register
+                            3:fixed:SCALE = 16-bit signed fixed-point in
+                              input register, SCALE a power of ten (0.01)
+                            3:fixed32:SCALE = 32-bit fixed-point
+                            4 = 16-bit output (holding) register (default)
+                            4:hex = 16-bit output register (hex display)
+                            4:int = 32-bit integer in output register
+                            4:float = 32-bit float in output register
+                            4:fixed:SCALE, 4:fixed32:SCALE = fixed-point in
+                              output register`: `  -t, --type TYPE         数据类型:
+                            0 = 离散输出(线圈)
+                            1 = 离散输入
+                            3 = 16 位输入寄存器
+                            3:hex = 16 位输入寄存器(十六进制显示)
+                            3:int = 输入寄存器中的 32 位整数
+                            3:float = 输入寄存器中的 32 位浮点数
+                            3:fixed:SCALE = 输入寄存器中的 16 位有符号定点数,
+                              SCALE 为 10 的幂 (0.01)
+                            3:fixed32:SCALE = 32 位定点数
+                            4 = 16 位输出(保持)寄存器(默认)
+                            4:hex = 16 位输出寄存器(十六进制显示)
+                            4:int = 输出寄存器中的 32 位整数
+                            4:float = 输出寄存器中的 32 位浮点数
+                            4:fixed:SCALE, 4:fixed32:SCALE = 输出寄存器中的
+                              定点数`,
+	"  -0, --zero-based        First reference is 0 (PDU addressing)":           "  -0, --zero-based        第一个地址为 0(PDU 寻址)",
+	"  -B, --big-endian        Big endian word order for 32-bit data (default)": "  -B, --big-endian        32 位数据使用大端字序(默认)",
+	"  -1, --once              Poll only once, otherwise poll continuously":     "  -1, --once              只轮询一次,否则持续轮询",
+	"  -l, --poll-rate MS      Poll rate in milliseconds (default: 1000)":       "  -l, --poll-rate MS      轮询间隔,单位毫秒(默认:1000)",
+	"  -o, --timeout SEC       Timeout in seconds (default: 1.0)":               "  -o, --timeout SEC       超时时间,单位秒(默认:1.0)",
+	"  --values V1 [V2...]     Write values (space or comma separated)":         "  --values V1 [V2...]     写入值(用空格或逗号分隔)",
+	"  -p, --port PORT         TCP port number (default: 502)":                  "  -p, --port PORT         TCP 端口号(默认:502)",
+	"  -b, --baudrate RATE     Baudrate (1200-921600, default: 19200)":          "  -b, --baudrate RATE     波特率(1200-921600,默认:19200)",
+	"  -d, --databits BITS     Databits (7 or 8, default: 8)":                   "  -d, --databits BITS     数据位(7 或 8,默认:8)",
+	"  -s, --stopbits BITS     Stopbits (1 or 2, default: 1)":                   "  -s, --stopbits BITS     停止位(1 或 2,默认:1)",
+	"  -P, --parity PARITY     Parity: none, even, odd (default: even)":         "  -P, --parity PARITY     校验:none、even、odd(默认:even)",
+	"  -v, --verbose           Verbose mode":                                    "  -v, --verbose           详细输出模式",
+	"  -h, --help              Show this help message":                          "  -h, --help              显示此帮助信息",
+	"  -V, --version           Show version information":                        "  -V, --version           显示版本信息",
+	`  --read-only             Refuse all write operations, including proxy
+                          client requests other than reads`: `  --read-only             拒绝所有写操作,包括代理客户端除读取以外的所有请求`,
+	`  --lang LANG             Language of help and error messages: en, de or zh
+                          (default: from the locale, otherwise en)`: `  --lang LANG             帮助和错误信息的语言:en、de 或 zh
+                          (默认:取自系统区域设置,否则为 en)`,
+
+	"  # Read 2 holding registers starting at address 1 from TCP device": "  # 从 TCP 设备读取从地址 1 开始的 2 个保持寄存器",
+	"  # Read input registers as 32-bit floats from RTU device":          "  # 从 RTU 设备以 32 位浮点数读取输入寄存器",
+	"  # Write values to holding registers":                              "  # 向保持寄存器写入值",
+	"  # Write 32-bit integers to holding registers":                     "  # 向保持寄存器写入 32 位整数",
+	"  # Write 32-bit floats to holding registers":                       "  # 向保持寄存器写入 32 位浮点数",
+	"  # Write coils\n":                                            "  # 写入线圈\n",
+	"  # Poll coils continuously":                                  "  # 持续轮询线圈",
+	"  # Use RTU over TCP (tunneled serial)":                       "  # 使用 RTU over TCP(隧道传输的串口)",
+	"  # Use Modbus TCP over UDP":                                  "  # 使用基于 UDP 的 Modbus TCP",
+	"  # Use Modbus TCP over TLS":                                  "  # 使用基于 TLS 的 Modbus TCP",
+	"  # Share one PLC connection between many clients, read-only": "  # 多个客户端共享一个 PLC 连接,只读",
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// journalOff disables the write journal when given to --journal.
+const journalOff = "off"
+
+// journalEntry records one write: the values the written range held before
+// it, so the write can be undone.
+type journalEntry struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Target   string    `json:"target"`
+	UnitID   int       `json:"unit_id"`
+	DataType string    `json:"data_type"` // "0" for coils, "4" for holding registers
+	Start    int       `json:"start"`
+	Before   []uint16  `json:"before"`           // coils as 0 or 1
+	Undoes   []string  `json:"undoes,omitempty"` // entries this write reverted
+}
+
+// journalPath returns the journal file, by default journal.jsonl in the
+// user's configuration directory.
+func (c *Config) journalPath() (string, error) {
+	if c.Journal != "" {
+		return c.Journal, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no default journal location (use --journal FILE): %v", err)
+	}
+	return filepath.Join(dir, "gomodbus", "journal.jsonl"), nil
+}
+
+// session returns the --session name, or one for this invocation.
+func (c *Config) session() string {
+	if c.Session == "" {
+		c.Session = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+	}
+	return c.Session
+}
+
+// journalTable returns the table a write of dataType goes to, as recorded in
+// the journal.
+func journalTable(dataType string) string {
+	if dataType == "0" {
+		return "0"
+	}
+	return "4"
+}
+
+// journaledWrite performs the configured write after reading the values it
+// overwrites, and records them in the journal for undo. A write whose
+// original values can't be read, such as a write-only register, is carried
+// out with a warning and without a journal entry. With --rollback the
+// original values also undo a chunked write that fails part-way; without
+// it the journal records the part that was written. With --atomic the
+// write is also read back, and undone unless every value took.
+func (m *ModbusCLI) journaledWrite(startRef int) error {
+	putBack, err := m.expandWriteArgs()
+	if err != nil {
+		return err
+	}
+	defer putBack()
+
+	journal := m.config.Journal != journalOff
+	restore := m.config.Rollback || m.config.Atomic
+	if !journal && !restore {
+		return m.performWriteOperation(startRef)
+	}
+	if err := m.config.checkWrite(time.Now()); err != nil {
+		return fmt.Errorf("refusing to write: %v", err)
+	}
+
+	table := journalTable(m.config.DataType)
+	before, err := m.readOriginal(table, startRef, m.config.writeRegisterCount())
+	if err != nil && restore {
+		return fmt.Errorf("failed to read original values for the rollback: %w", err)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: writing without a journal entry, the original values can't be read: %v\n", localizeError(err))
+		return m.performWriteOperation(startRef)
+	}
+
+	err = m.performWriteOperation(startRef)
+	var partial *partialWriteError
+	switch {
+	case errors.As(err, &partial) && restore:
+		return m.rollback(table, startRef, before[:partial.written], err)
+	case errors.As(err, &partial):
+		before = before[:partial.written]
+	case err != nil:
+		return err
+	}
+	if m.config.Atomic {
+		if verr := m.verifyWrite(table, startRef); verr != nil {
+			return m.rollback(table, startRef, before, verr)
+		}
+		fmt.Fprintf(m.out, "Verified %d value(s) written\n", len(m.config.WriteArgs))
+	}
+	if !journal {
+		return nil
+	}
+
+	entry := m.newJournalEntry(table, startRef, before)
+	if jerr := m.appendJournal(entry); jerr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: write not journaled: %v\n", jerr)
+		return err
+	}
+	fmt.Fprintf(m.out, "Original values journaled in session %s\n", entry.Session)
+	return err
+}
+
+func (m *ModbusCLI) newJournalEntry(table string, startRef int, before []uint16) journalEntry {
+	now := time.Now()
+	return journalEntry{
+		ID:       strconv.FormatInt(now.UnixNano(), 36),
+		Time:     now,
+		Session:  m.config.session(),
+		Target:   m.target(),
+		UnitID:   m.config.SlaveID,
+		DataType: table,
+		Start:    startRef,
+		Before:   before,
+	}
+}
+
+// appendJournal adds an entry to the journal file. It records device
+// addresses and process values, so only the user may read it.
+func (m *ModbusCLI) appendJournal(entry journalEntry) error {
+	path, err := m.config.journalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// readJournal loads every entry of the journal file.
+func (m *ModbusCLI) readJournal() ([]journalEntry, error) {
+	path, err := m.config.journalPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// runUndo implements "gomodbus undo --last|--session NAME [OPTIONS]
+// DEVICE|HOST": it writes the original values of journaled writes to the
+// device back, newest first. Undo writes are journaled too but never undone
+// themselves, so repeating --last steps further back through the writes.
+func (m *ModbusCLI) runUndo() error {
+	if m.config.Journal == journalOff {
+		return fmt.Errorf("undo needs the journal")
+	}
+	if m.config.UndoLast == (m.config.Session != "") {
+		return fmt.Errorf("undo requires either --last or --session NAME")
+	}
+	if err := m.config.checkWrite(time.Now()); err != nil {
+		return fmt.Errorf("refusing to write: %v", err)
+	}
+
+	entries, err := m.readJournal()
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+
+	// Pick the writes to revert: those to this device not reverted already
+	undone := make(map[string]bool)
+	for _, entry := range entries {
+		for _, id := range entry.Undoes {
+			undone[entry.ID] = true
+			undone[id] = true
+		}
+	}
+	var pending []journalEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if undone[entry.ID] || entry.Target != m.target() || entry.UnitID != m.config.SlaveID {
+			continue
+		}
+		if m.config.UndoLast {
+			pending = append(pending, entry)
+			break
+		}
+		if entry.Session == m.config.Session {
+			pending = append(pending, entry)
+		}
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("no journaled writes to %s (unit %d) left to undo", m.target(), m.config.SlaveID)
+	}
+
+	if err := m.setupClient(); err != nil {
+		return err
+	}
+	if err := m.connect(); err != nil {
+		return err
+	}
+	defer m.client.Close()
+
+	// Undo writes are journaled in a session of their own
+	m.out = os.Stdout
+	m.config.Session = ""
+	for _, entry := range pending {
+		if err := m.undoEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoEntry writes the original values of one journal entry back.
+func (m *ModbusCLI) undoEntry(entry journalEntry) error {
+	current, err := m.readOriginal(entry.DataType, entry.Start, len(entry.Before))
+	if err != nil {
+		return fmt.Errorf("failed to read current values: %w", err)
+	}
+
+	err = m.writeRaw(entry.DataType, entry.Start, entry.Before)
+	if err != nil {
+		return fmt.Errorf("failed to undo write of %s: %w", entry.Time.Format("2006-01-02 15:04:05"), err)
+	}
+
+	fmt.Printf("Undid write of %s (session %s):\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Session)
+	for i, value := range entry.Before {
+		m.printValue(entry.Start+i, strconv.Itoa(int(value)), "was "+strconv.Itoa(int(current[i])))
+	}
+
+	undo := m.newJournalEntry(entry.DataType, entry.Start, current)
+	undo.Undoes = []string{entry.ID}
+	if err := m.appendJournal(undo); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: undo not journaled: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriteLoopJournalsFirstWrite(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	sim.holding[1] = 42
+	port := startTestServer(t, sim)
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	m := &ModbusCLI{out: io.Discard}
+	config, err := m.parseArgs([]string{"-p", strconv.Itoa(port), "-t", "4", "-r", "1",
+		"--write-loop", "ramp:1:5", "-l", "10", "--journal", path, "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.config = config
+	if err := m.setupClient(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.client.Close()
+
+	stop := make(chan os.Signal, 1)
+	stats := newPollStats()
+	done := make(chan error)
+	go func() { done <- m.runWriteLoop(1, stop, stats) }()
+	time.Sleep(100 * time.Millisecond)
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if stats.requests < 3 {
+		t.Fatalf("only %d writes in the loop", stats.requests)
+	}
+
+	entries, err := m.readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Before[0] != 42 {
+		t.Errorf("journal after %d writes = %+v, want one entry holding 42", stats.requests, entries)
+	}
+	if m.config.Journal != path {
+		t.Errorf("journal left as %q after the loop", m.config.Journal)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 && os.PathSeparator == '/' {
+		t.Errorf("journal mode = %v, want 0600", perm)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// valueRecord is one value read or written, as --output json prints it in
+// place of the text listing.
+type valueRecord struct {
+	Timestamp time.Time   `json:"timestamp"`
+	UnitID    int         `json:"unit_id"`
+	Label     string      `json:"label,omitempty"`
+	Operation string      `json:"operation"` // "read" or "write"
+	Table     string      `json:"table"`
+	Address   int         `json:"address"`
+	Type      string      `json:"type"`
+	Field     string      `json:"field,omitempty"` // name of a --layout field
+	Raw       []uint16    `json:"raw,omitempty"`
+	Value     interface{} `json:"value"`
+	Quality   string      `json:"quality,omitempty"` // "bad" for a value that failed to read
+	Note      string      `json:"note,omitempty"`
+}
+
+// jsonOutput reports whether --output json replaces the text listing.
+func (c *Config) jsonOutput() bool {
+	return c.Output == "json"
+}
+
+// tableName names the table of a data type in JSON output.
+func tableName(dataType string) string {
+	switch dataType[:1] {
+	case "0":
+		return "coil"
+	case "1":
+		return "discrete_input"
+	case "3":
+		return "input_register"
+	}
+	return "holding_register"
+}
+
+func (m *ModbusCLI) newValueRecord(now time.Time, operation string, address int) valueRecord {
+	return valueRecord{
+		Timestamp: now,
+		UnitID:    m.config.SlaveID,
+		Label:     m.tag,
+		Operation: operation,
+		Table:     tableName(m.config.DataType),
+		Address:   address,
+		Type:      m.config.DataType,
+	}
+}
+
+// printValueRecord writes a record as a line of JSON on stdout. With
+// --mask-values only the address and type are kept.
+func (m *ModbusCLI) printValueRecord(rec valueRecord) {
+	if note := nonFinite(rec.Value); note != "" {
+		rec.Value, rec.Note = nil, note
+	}
+	if m.config.MaskValues {
+		rec.Raw, rec.Value = nil, maskPlaceholder
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to encode value: %v\n", err)
+		return
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// printBitsJSON prints coils or discrete inputs, one record per bit.
+func (m *ModbusCLI) printBitsJSON(operation string, startRef int, bits []bool) {
+	now := time.Now()
+	for i, bit := range bits {
+		rec := m.newValueRecord(now, operation, startRef+i)
+		rec.Raw = []uint16{uint16(boolToInt(bit))}
+		rec.Value = bit
+		m.printValueRecord(rec)
+	}
+}
+
+// printRegistersJSON prints a block of registers one record per value of
+// the data type, or per --layout field, with the registers it came from.
+func (m *ModbusCLI) printRegistersJSON(operation string, startRef int, registers []uint16) {
+	now := time.Now()
+
+	if layout := m.config.Layout; layout != nil {
+		for offset := 0; offset+layout.words <= len(registers); {
+			for _, field := range layout.fields {
+				raw := registers[offset : offset+field.words]
+				rec := m.newValueRecord(now, operation, startRef+offset)
+				rec.Type, rec.Field, rec.Raw = field.kind, field.name, raw
+				rec.Value = m.layoutValue(field, raw)
+				if m.isBad(offset, field.words) {
+					rec.Raw, rec.Value, rec.Quality = nil, nil, "bad"
+				}
+				m.printValueRecord(rec)
+				offset += field.words
+			}
+		}
+		return
+	}
+
+	m.decoded = decodeRegisters(m.decoded, registers, m.config.DataType, m.config.BigEndian)
+	words := m.config.wordsPerValue()
+	for _, value := range m.decoded {
+		rec := m.newValueRecord(now, operation, startRef+value.Offset)
+		rec.Raw = registers[value.Offset:min(value.Offset+words, len(registers))]
+		rec.Value = m.typedValue(value)
+		if value.Partial {
+			rec.Note = "incomplete 32-bit value"
+		}
+		if m.isBad(value.Offset, words) {
+			rec.Raw, rec.Value, rec.Quality = nil, nil, "bad"
+		}
+		m.printValueRecord(rec)
+	}
+}
+
+// typedValue returns a decoded value as a number for JSON and CSV, or nil
+// for an incomplete one. Fixed-point values stay exact decimals.
+func (m *ModbusCLI) typedValue(value typedValue) interface{} {
+	switch {
+	case value.Kind == kindInt32:
+		return value.Int32()
+	case value.Kind == kindFloat32:
+		return value.Float32()
+	case value.Kind == kindFixed16:
+		return json.Number(appendFixed(nil, int64(int16(value.Bits)), m.config.FixedExponent))
+	case value.Kind == kindFixed32:
+		return json.Number(appendFixed(nil, int64(value.Int32()), m.config.FixedExponent))
+	case value.Partial:
+		return nil
+	}
+	return value.Bits
+}
+
+// layoutValue returns the value of a --layout field for JSON and CSV. Floats
+// keep their full precision, unlike in the listing.
+func (m *ModbusCLI) layoutValue(field layoutField, raw []uint16) interface{} {
+	switch field.kind {
+	case "f32":
+		return math.Float32frombits(uint32(layoutFieldBits(raw, m.config.BigEndian)))
+	case "f64":
+		return math.Float64frombits(layoutFieldBits(raw, m.config.BigEndian))
+	case "str":
+		text, _ := strconv.Unquote(formatLayoutField(field, raw, m.config.BigEndian))
+		return text
+	}
+	return json.Number(formatLayoutField(field, raw, m.config.BigEndian))
+}
+
+// nonFinite describes a NaN or infinite float, which JSON has no number
+// for, or returns "" for any other value. The registers stay in "raw".
+func nonFinite(value interface{}) string {
+	var f float64
+	switch v := value.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return ""
+	}
+	switch {
+	case math.IsNaN(f):
+		return "float is NaN"
+	case math.IsInf(f, 1):
+		return "float is +Inf"
+	case math.IsInf(f, -1):
+		return "float is -Inf"
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestNonFinite(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{float32(1.5), ""},
+		{math.NaN(), "float is NaN"},
+		{float32(math.Inf(1)), "float is +Inf"},
+		{math.Inf(-1), "float is -Inf"},
+		{uint32(7), ""},
+		{nil, ""},
+		{json.Number("12.5"), ""},
+	}
+	for _, tt := range tests {
+		if got := nonFinite(tt.value); got != tt.want {
+			t.Errorf("nonFinite(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPrintRegistersJSONNonFinite(t *testing.T) {
+	m := &ModbusCLI{config: &Config{DataType: "4:float", BigEndian: true, Output: "json"}}
+	out := captureStdout(t, func() {
+		m.printRegistersJSON("read", 100, []uint16{0xffff, 0xffff, 0x7f80, 0x0000, 0x3fc0, 0x0000})
+	})
+
+	want := []struct {
+		address int
+		value   interface{}
+		note    string
+	}{
+		{100, nil, "float is NaN"},
+		{102, nil, "float is +Inf"},
+		{104, 1.5, ""},
+	}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for i, w := range want {
+		if !scanner.Scan() {
+			t.Fatalf("got %d records, want %d:\n%s", i, len(want), out)
+		}
+		var rec struct {
+			Address int         `json:"address"`
+			Raw     []uint16    `json:"raw"`
+			Value   interface{} `json:"value"`
+			Note    string      `json:"note"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("record %d: %v: %s", i, err, scanner.Text())
+		}
+		if rec.Address != w.address || rec.Value != w.value || rec.Note != w.note || len(rec.Raw) != 2 {
+			t.Errorf("record %d = %+v, want address %d, value %v, note %q and two raw registers",
+				i, rec, w.address, w.value, w.note)
+		}
+	}
+	if scanner.Scan() {
+		t.Errorf("unexpected record %s", scanner.Text())
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Latency buckets are log-linear in the HDR histogram style: every power of
+// two microseconds from 64µs is split into latencySubBuckets linear steps,
+// which keeps the relative error under 25% from 64µs up to ~17s.
+const (
+	latencyMinExp     = 6 // 64µs
+	latencyMaxExp     = 24
+	latencySubBuckets = 4
+	latencyBuckets    = (latencyMaxExp-latencyMinExp)*latencySubBuckets + 1
+)
+
+// latencyHistogram counts successful request latencies in log-linear buckets.
+type latencyHistogram struct {
+	counts   [latencyBuckets + 1]uint64 // last bucket is +Inf
+	errors   uint64
+	count    uint64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+// bucketBound returns the upper bound of bucket i in microseconds.
+func bucketBound(i int) int64 {
+	if i == 0 {
+		return 1 << latencyMinExp
+	}
+	exp := latencyMinExp + (i-1)/latencySubBuckets
+	step := int64(1) << exp / latencySubBuckets
+	return int64(1)<<exp + step*int64((i-1)%latencySubBuckets+1)
+}
+
+// bucketFor returns the index of the first bucket whose bound is >= us.
+func bucketFor(us int64) int {
+	if us <= 1<<latencyMinExp {
+		return 0
+	}
+	exp := bits.Len64(uint64(us-1)) - 1
+	if exp >= latencyMaxExp {
+		return latencyBuckets
+	}
+	step := int64(1) << exp / latencySubBuckets
+	sub := (us - 1 - int64(1)<<exp) / step
+	return 1 + (exp-latencyMinExp)*latencySubBuckets + int(sub)
+}
+
+func (h *latencyHistogram) add(latency time.Duration, err error) {
+	if err != nil {
+		h.errors++
+		return
+	}
+
+	h.counts[bucketFor(latency.Microseconds())]++
+	if h.count == 0 || latency < h.min {
+		h.min = latency
+	}
+	if latency > h.max {
+		h.max = latency
+	}
+	h.count++
+	h.sum += latency
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// percentile, capped at the largest latency seen.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(p/100*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		if i < latencyBuckets {
+			if bound := time.Duration(bucketBound(i)) * time.Microsecond; bound < h.max {
+				return bound
+			}
+		}
+		break
+	}
+	return h.max
+}
+
+// latencyReport is the JSON form of a target's latency histogram.
+type latencyReport struct {
+	Target  string          `json:"target"`
+	UnitID  int             `json:"unit_id"`
+	Count   uint64          `json:"count"`
+	Errors  uint64          `json:"errors"`
+	MinMs   float64         `json:"min_ms"`
+	MeanMs  float64         `json:"mean_ms"`
+	P50Ms   float64         `json:"p50_ms"`
+	P90Ms   float64         `json:"p90_ms"`
+	P99Ms   float64         `json:"p99_ms"`
+	P999Ms  float64         `json:"p999_ms"`
+	MaxMs   float64         `json:"max_ms"`
+	Buckets []latencyBucket `json:"buckets"`
+}
+
+// latencyBucket is a non-empty bucket; LeUs is 0 for the overflow bucket.
+type latencyBucket struct {
+	LeUs  int64  `json:"le_us"`
+	Count uint64 `json:"count"`
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (m *ModbusCLI) latencyReport() latencyReport {
+	h := m.latency
+	report := latencyReport{
+		Target:  m.target(),
+		UnitID:  m.config.SlaveID,
+		Count:   h.count,
+		Errors:  h.errors,
+		MinMs:   millis(h.min),
+		P50Ms:   millis(h.percentile(50)),
+		P90Ms:   millis(h.percentile(90)),
+		P99Ms:   millis(h.percentile(99)),
+		P999Ms:  millis(h.percentile(99.9)),
+		MaxMs:   millis(h.max),
+		Buckets: []latencyBucket{},
+	}
+	if h.count > 0 {
+		report.MeanMs = millis(h.sum / time.Duration(h.count))
+	}
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		bucket := latencyBucket{Count: n}
+		if i < latencyBuckets {
+			bucket.LeUs = bucketBound(i)
+		}
+		report.Buckets = append(report.Buckets, bucket)
+	}
+	return report
+}
+
+// exportLatency writes the session's latency histogram as JSON and pushes it
+// to a Prometheus Pushgateway, as configured. Failures only warn, as the
+// session itself has already finished.
+func (m *ModbusCLI) exportLatency() {
+	if m.config.LatencyJSON != "" {
+		if err := m.writeLatencyJSON(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write latency histogram: %v\n", err)
+		}
+	}
+	if m.config.PushGateway != "" {
+		if err := m.pushLatency(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to push latency histogram: %v\n", err)
+		}
+	}
+}
+
+func (m *ModbusCLI) writeLatencyJSON() error {
+	data, err := json.MarshalIndent(struct {
+		Build   buildInfo       `json:"build"`
+		Targets []latencyReport `json:"targets"`
+	}{currentBuildInfo(), []latencyReport{m.latencyReport()}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if m.config.LatencyJSON == "-" {
+		_, err = m.out.Write(data)
+		return err
+	}
+	return os.WriteFile(m.config.LatencyJSON, data, 0644)
+}
+
+// pushLatency replaces the gomodbus job's metrics for this target on a
+// Prometheus Pushgateway with the histogram in text exposition format.
+func (m *ModbusCLI) pushLatency() error {
+	h := m.latency
+	labels := fmt.Sprintf(`target=%q,unit_id="%d"`, m.target(), m.config.SlaveID)
+
+	var body bytes.Buffer
+	body.WriteString("# TYPE gomodbus_request_duration_seconds histogram\n")
+	var cumulative uint64
+	for i := 0; i < latencyBuckets; i++ {
+		cumulative += h.counts[i]
+		fmt.Fprintf(&body, "gomodbus_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n",
+			labels, float64(bucketBound(i))/1e6, cumulative)
+	}
+	fmt.Fprintf(&body, "gomodbus_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+	fmt.Fprintf(&body, "gomodbus_request_duration_seconds_sum{%s} %g\n", labels, h.sum.Seconds())
+	fmt.Fprintf(&body, "gomodbus_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	body.WriteString("# TYPE gomodbus_request_errors_total counter\n")
+	fmt.Fprintf(&body, "gomodbus_request_errors_total{%s} %d\n", labels, h.errors)
+
+	endpoint := strings.TrimSuffix(m.config.PushGateway, "/") + "/metrics/job/gomodbus/instance/" +
+		url.PathEscape(m.target())
+	req, err := http.NewRequest(http.MethodPut, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway returned %s", res.Status)
+	}
+	return nil
+}
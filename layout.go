@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// layoutWords is the number of registers each --layout type takes; str[N]
+// takes N characters, two per register.
+var layoutWords = map[string]int{
+	"u16": 1,
+	"i16": 1,
+	"u32": 2,
+	"i32": 2,
+	"f32": 2,
+	"u64": 4,
+	"i64": 4,
+	"f64": 4,
+}
+
+// layoutField is one field of a --layout.
+type layoutField struct {
+	name  string // optional, from NAME=TYPE
+	kind  string // a layoutWords key, or "str"
+	words int
+	chars int // length of a str field
+}
+
+// registerLayout describes a block of registers holding mixed types, such as
+// "u16,u16,f32,i32,str[8]", so a device that packs different data into one
+// contiguous block can be read with a single request.
+type registerLayout struct {
+	fields []layoutField
+	words  int // registers per repetition of the layout
+}
+
+// parseLayout parses a --layout spec: comma separated types, each optionally
+// named with NAME=TYPE.
+func parseLayout(spec string) (*registerLayout, error) {
+	layout := &registerLayout{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		var field layoutField
+		if name, kind, ok := strings.Cut(part, "="); ok {
+			field.name = strings.TrimSpace(name)
+			part = strings.TrimSpace(kind)
+		}
+
+		if size, ok := strings.CutPrefix(part, "str["); ok && strings.HasSuffix(size, "]") {
+			chars, err := strconv.Atoi(strings.TrimSuffix(size, "]"))
+			if err != nil || chars < 1 || chars > 2*maxReadRegisters {
+				return nil, fmt.Errorf("invalid layout field %q (string length must be 1-%d)", part, 2*maxReadRegisters)
+			}
+			field.kind, field.words, field.chars = "str", (chars+1)/2, chars
+		} else if words, ok := layoutWords[part]; ok {
+			field.kind, field.words = part, words
+		} else {
+			return nil, fmt.Errorf("invalid layout field %q (expected u16, i16, u32, i32, u64, i64, f32, f64 or str[N])", part)
+		}
+
+		layout.fields = append(layout.fields, field)
+		layout.words += field.words
+	}
+	return layout, nil
+}
+
+// printLayout prints a block of registers decoded field by field, repeating
+// the layout across the block.
+func (m *ModbusCLI) printLayout(startRef int, registers []uint16) {
+	layout := m.config.Layout
+	offset := 0
+	for offset+layout.words <= len(registers) {
+		for _, field := range layout.fields {
+			value := formatLayoutField(field, registers[offset:offset+field.words], m.config.BigEndian)
+			note := field.kind
+			if field.kind == "str" {
+				note = fmt.Sprintf("str[%d]", field.chars)
+			}
+			if field.name != "" {
+				note = field.name + " " + note
+			}
+			if m.isBad(offset, field.words) {
+				value, note = badValue, note+", bad quality"
+			}
+			m.printValue(startRef+offset, value, note)
+			offset += field.words
+		}
+	}
+}
+
+// formatLayoutField formats the registers of one field. Multi-register
+// numbers follow the configured word order; strings hold two characters per
+// register, high byte first, with trailing NULs and spaces dropped.
+func formatLayoutField(field layoutField, words []uint16, bigEndian bool) string {
+	bits := layoutFieldBits(words, bigEndian)
+
+	switch field.kind {
+	case "u16", "u32", "u64":
+		return strconv.FormatUint(bits, 10)
+	case "i16":
+		return strconv.Itoa(int(int16(bits)))
+	case "i32":
+		return strconv.Itoa(int(int32(bits)))
+	case "i64":
+		return strconv.FormatInt(int64(bits), 10)
+	case "f32":
+		return fmt.Sprintf("%.2f", math.Float32frombits(uint32(bits)))
+	case "f64":
+		return fmt.Sprintf("%.2f", math.Float64frombits(bits))
+	}
+
+	text := make([]byte, 0, 2*len(words))
+	for _, word := range words {
+		text = append(text, byte(word>>8), byte(word))
+	}
+	return strconv.Quote(strings.TrimRight(string(text[:field.chars]), "\x00 "))
+}
+
+// layoutFieldBits joins the registers of a numeric field in word order.
+func layoutFieldBits(words []uint16, bigEndian bool) uint64 {
+	var bits uint64
+	for i := range words {
+		word := words[i]
+		if !bigEndian {
+			word = words[len(words)-1-i]
+		}
+		bits = bits<<16 | uint64(word)
+	}
+	return bits
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	layout, err := parseLayout("u16, temp = f32,i64,str[5],serial=str[250]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []layoutField{
+		{kind: "u16", words: 1},
+		{name: "temp", kind: "f32", words: 2},
+		{kind: "i64", words: 4},
+		{kind: "str", words: 3, chars: 5},
+		{name: "serial", kind: "str", words: 125, chars: 250},
+	}
+	if !reflect.DeepEqual(layout.fields, want) {
+		t.Errorf("fields = %+v, want %+v", layout.fields, want)
+	}
+	if layout.words != 135 {
+		t.Errorf("layout takes %d registers, want 135", layout.words)
+	}
+
+	// Fields follow one another, so no two share a register
+	for _, spec := range []string{"u16,u32,u16", "str[3],i16", "f64,str[1],u64"} {
+		layout, err := parseLayout(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := 0
+		for _, field := range layout.fields {
+			offset += field.words
+		}
+		if offset != layout.words {
+			t.Errorf("%s: fields take %d registers, the layout %d", spec, offset, layout.words)
+		}
+	}
+}
+
+func TestParseLayoutErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", `invalid layout field ""`},
+		{"u16,,u16", `invalid layout field ""`},
+		{"u8", `invalid layout field "u8" (expected u16`},
+		{"float", `invalid layout field "float"`},
+		{"U16", `invalid layout field "U16"`},
+		{"name=", `invalid layout field ""`},
+		{"a=b=u16", `invalid layout field "b=u16"`},
+		{"str", `invalid layout field "str"`},
+		{"str[", `invalid layout field "str["`},
+		{"str[4", `invalid layout field "str[4"`},
+		{"str[0]", `invalid layout field "str[0]" (string length must be 1-250)`},
+		{"str[251]", `invalid layout field "str[251]" (string length must be 1-250)`},
+		{"str[-1]", `invalid layout field "str[-1]"`},
+		{"str[x]", `invalid layout field "str[x]"`},
+		{"u16,str[300]", `invalid layout field "str[300]"`},
+	}
+	for _, tt := range tests {
+		if _, err := parseLayout(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseLayout(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestFormatLayoutField(t *testing.T) {
+	f32 := math.Float32bits(21.5)
+	f64 := math.Float64bits(-1234.567)
+	tests := []struct {
+		kind      string
+		chars     int
+		words     []uint16
+		bigEndian bool
+		want      string
+	}{
+		{"u16", 0, []uint16{0xffff}, true, "65535"},
+		{"i16", 0, []uint16{0xffff}, true, "-1"},
+		{"i16", 0, []uint16{0x8000}, false, "-32768"},
+		{"u32", 0, []uint16{0x0001, 0x0002}, true, "65538"},
+		{"u32", 0, []uint16{0x0002, 0x0001}, false, "65538"},
+		{"i32", 0, []uint16{0xffff, 0xfffe}, true, "-2"},
+		{"i32", 0, []uint16{0xfffe, 0xffff}, false, "-2"},
+		{"u64", 0, []uint16{0xffff, 0xffff, 0xffff, 0xffff}, true, "18446744073709551615"},
+		{"i64", 0, []uint16{0x8000, 0, 0, 0}, true, "-9223372036854775808"},
+		{"i64", 0, []uint16{0, 0, 0, 0x8000}, false, "-9223372036854775808"},
+		{"f32", 0, []uint16{uint16(f32 >> 16), uint16(f32)}, true, "21.50"},
+		{"f32", 0, []uint16{uint16(f32), uint16(f32 >> 16)}, false, "21.50"},
+		{"f64", 0, []uint16{uint16(f64 >> 48), uint16(f64 >> 32), uint16(f64 >> 16), uint16(f64)}, true, "-1234.57"},
+		{"f64", 0, []uint16{uint16(f64), uint16(f64 >> 16), uint16(f64 >> 32), uint16(f64 >> 48)}, false, "-1234.57"},
+		// Strings are high byte first whatever the word order
+		{"str", 4, []uint16{0x4142, 0x4344}, true, `"ABCD"`},
+		{"str", 4, []uint16{0x4142, 0x4344}, false, `"ABCD"`},
+		{"str", 3, []uint16{0x4142, 0x4344}, true, `"ABC"`},
+		{"str", 6, []uint16{0x4142, 0x2000, 0x0000}, true, `"AB"`},
+		{"str", 4, []uint16{0x0041, 0x0a42}, true, `"\x00A\nB"`},
+	}
+	for _, tt := range tests {
+		field := layoutField{kind: tt.kind, words: len(tt.words), chars: tt.chars}
+		if got := formatLayoutField(field, tt.words, tt.bigEndian); got != tt.want {
+			t.Errorf("%s %#x (big endian %v) = %s, want %s", tt.kind, tt.words, tt.bigEndian, got, tt.want)
+		}
+	}
+
+	// Every layout type is formatted
+	for kind := range layoutWords {
+		field := layoutField{kind: kind, words: layoutWords[kind]}
+		if got := formatLayoutField(field, make([]uint16, field.words), true); got != "0" && got != "0.00" {
+			t.Errorf("%s of zeros = %s", kind, got)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/simonvetter/modbus"
@@ -23,49 +29,365 @@ type Config struct {
 	Parity   string
 	Timeout  time.Duration
 
+	// Timeout for opening a connection, 0 to use Timeout
+	ConnectTimeout time.Duration
+
+	// How long to keep re-issuing requests the device reports busy
+	BusyPatience time.Duration
+
+	// Semaphore register guarding the data block, LockRef -1 for none
+	LockRef     int
+	LockConfirm int // register the PLC confirms ownership in
+	LockToken   int // -1 until --lock-token is given
+	LockFree    uint16
+	LockWait    time.Duration
+
+	// Scan-complete register operations wait on to change, SyncRef -1 for none
+	SyncRef  int
+	SyncWait time.Duration
+
+	// Timeout auto-tuning
+	AutoTimeout bool
+	Warmup      int // successful requests measured before tuning
+
 	// Modbus settings
 	SlaveID   int
+	SlaveIDs  []int          // units polled in turn when -a lists several
+	Labels    map[int]string // --label names of units, tagging their output
 	StartRef  int
 	Count     int
+	CountAuto bool   // derive Count from the data type and write values
+	CountUnit string // "values" or "registers"
 	DataType  string
 	ZeroBased bool
-	BigEndian bool
-	PollOnce  bool
-	PollRate  time.Duration
-	Verbose   bool
+	// Input register types read and written as holding registers
+	TreatAsHolding bool
+	// Decimal exponent of the fixed-point data types: value = raw x 10^exp
+	FixedExponent int
+	BigEndian     bool
+	PollOnce      bool
+	PollRate      time.Duration
+	Verbose       bool
+	AutoBaud      bool
+	Votes         int  // number of agreeing reads required before reporting
+	CoilSwap      bool // coil bytes arrive with each byte pair swapped
+	FailFast      bool // give up a block or --once cycle at the first failure
+
+	// External decoder command for register blocks
+	Decoder string
+
+	// Mixed-type layout of register blocks
+	Layout *registerLayout
+
+	// Read Exception Status (FC07), Diagnostics (FC08), the comm event
+	// counter (FC11) or log (FC12) or Report Server ID (FC17) instead of data
+	ExceptionStatus bool
+	Diagnostic      bool
+	CommEvents      bool
+	CommLog         bool
+	ServerID        bool
+	DiagSub         uint16
+	DiagData        uint16
+	BitNames        map[int]string // names for status and diagnostic register bits
+
+	// Unit id scan behind a gateway
+	Scan      bool
+	ScanFirst int
+	ScanLast  int
+	ScanProbe string // read, fc17 or fc43
+
+	// Boolean expression checked against the coils or discrete inputs read
+	ExpectSrc string
+	CoilNames map[string]int
+	Expect    *boolExpr
 
 	// Write values
-	WriteValues []interface{}
+	WriteArgs  []string // write values as given, parsed per data type
+	Truncate   bool
+	MaskValues bool         // hide values in human-readable output
+	WriteLoop  writePattern // endless pattern of values to write
+	Repeat     bool         // rewrite the write values every poll interval
+	Rollback   bool         // undo a chunked write that fails part-way
+	Atomic     bool         // verify a write and undo it unless it all took
+	Single     bool         // write registers one at a time with FC06
+	Fanout     bool         // write to every listed host and unit
 
 	// RTU specific
 	RTSMode int
 	RTSPin  int
+
+	// Proxy mode
+	ProxyListen string
+	Upstream    string
+
+	// Discovery
+	TargetsFile string
+	Sweeps      []string
+	SweepRate   int // probes per second
+
+	// Refuse all write operations, or those outside the write windows
+	ReadOnly     bool
+	WriteWindows []writeWindow
+	WriteAt      time.Time // hold the write until this time
+
+	// Recorded write sequence to play back
+	Replay      []replayStep
+	ReplaySpeed float64 // pace multiplier
+
+	// Rows of a CSV file or Excel sheet to write one after another
+	BatchFile  string
+	BatchSheet string
+	Batch      []batchRow
+
+	// Write journal for undo
+	Journal  string // journal file, or "off"
+	Session  string // session name journaled writes are grouped under
+	UndoLast bool
+
+	// Test reports of verify-device, selftest and --expect runs
+	JUnit string // JUnit XML file
+	TAP   string // TAP file
+
+	// NATS publishing
+	NATSURL     string
+	NATSSubject string
+
+	// Health reporting while polling
+	HealthListen string // address for the /healthz endpoint
+	PprofListen  string // address for the net/http/pprof endpoints
+
+	// Commissioning report
+	Report string // .html or .md file
+
+	// Remote control of a running poll
+	Control string // Unix socket path
+
+	// Latency histogram export
+	LatencyJSON string // file, or "-" for stdout
+	PushGateway string // Prometheus Pushgateway base URL
+
+	// Output pipeline
+	NoColor     bool   // never color terminal output
+	CDC         bool   // only publish samples whose values changed
+	SinkExec    string // command samples are streamed to as JSON lines
+	Format      string // "text" or a record format
+	Output      string // "text", "json" or "csv" listing of values read and written
+	OutFile     string
+	RotateSize  int64         // rotate --out before it grows past this
+	RotateEvery time.Duration // rotate --out after this long
+	Keep        int           // rotated --out files kept
+	QueueSize   int
+	QueuePolicy string
 }
 
+// lockedReadOnly can be set at build time (-ldflags "-X main.lockedReadOnly=true")
+// to produce a binary that refuses all writes regardless of its arguments.
+var lockedReadOnly = "false"
+
 type ModbusCLI struct {
-	client *modbus.ModbusClient
-	config *Config
+	client  *modbus.ModbusClient
+	config  *Config
+	bus     *eventBus
+	states  map[int]*unitState // comm and alarm state per unit, for the bus
+	tuner   *timeoutTuner
+	latency *latencyHistogram
+	health  *healthState
+	conns   connCounts
+	report  *runReport
+	out     io.Writer // human-readable status output
+	tag     string    // label of the unit being polled
+	bad     []bool    // registers of the block being printed that failed to read
+
+	formatter valueFormatter // lists the values read, as --output selects
+
+	// Terminal colors, and the values last printed per output line so
+	// changes can be highlighted
+	colorOut bool
+	colorErr bool
+	previous map[string]string
+	painted  []byte
+
+	// Hash of the sample last published per unit, for --cdc
+	hashes map[int]string
+
+	// Outcomes of --expect evaluations for --junit and --tap
+	tests *testSuite
+
+	// Commands from the --control socket, and whether they paused polling
+	control     chan controlRequest
+	controlDone chan struct{} // closed once polling stops
+	paused      bool
+
+	// Buffers reused across poll cycles so long-running polling doesn't
+	// allocate per value
+	line    []byte
+	decoded []typedValue
+}
+
+// pollSample is one block of values read from the device, in the form
+// published to external sinks.
+type pollSample struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Source    string      `json:"source"`
+	UnitID    int         `json:"unit_id"`
+	Label     string      `json:"label,omitempty"`
+	DataType  string      `json:"data_type"`
+	Start     int         `json:"start"`
+	Values    interface{} `json:"values"`
+	Hash      string      `json:"hash"` // of everything but the timestamp
 }
 
 func main() {
 	cli := &ModbusCLI{}
 	if err := cli.run(); err != nil {
-		fmt.Fprintf(os.Stderr, "gomodbus: %v\n", err)
+		fmt.Fprintf(os.Stderr, "gomodbus: %s\n", localizeError(err))
+		if errors.Is(err, errExpectationFailed) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
 
-func (m *ModbusCLI) run() error {
-	config, err := m.parseArgs()
+func (m *ModbusCLI) run() (err error) {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "decode" {
+		return runDecode(args[1:])
+	}
+	if len(args) > 0 && args[0] == "serve" {
+		return runServe(args[1:])
+	}
+	if len(args) > 0 && args[0] == "encode" {
+		return runEncode(args[1:])
+	}
+	if len(args) > 0 && args[0] == "capabilities" {
+		return runCapabilities(args[1:])
+	}
+	if len(args) > 0 && args[0] == "ctl" {
+		return runCtl(args[1:])
+	}
+
+	selftest := len(args) > 0 && args[0] == "selftest"
+	undo := len(args) > 0 && args[0] == "undo"
+	verify := len(args) > 0 && args[0] == "verify-device"
+	if selftest || undo || verify {
+		args = args[1:]
+	}
+	var spec string
+	if verify {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return fmt.Errorf("usage: gomodbus verify-device SPEC [OPTIONS] DEVICE|HOST")
+		}
+		spec, args = args[0], args[1:]
+	}
+
+	config, err := m.parseArgs(args)
 	if err != nil {
 		return err
 	}
 	m.config = config
 
+	if selftest {
+		return m.runSelftest()
+	}
+	if undo {
+		return m.runUndo()
+	}
+	if verify {
+		return m.runVerify(spec)
+	}
+
+	// Otherwise only --expect has outcomes for test reports
+	if m.config.testReports() {
+		if m.config.Expect == nil {
+			return fmt.Errorf("--junit and --tap require verify-device, selftest or --expect")
+		}
+		m.tests = newTestSuite("expect")
+		defer func() { m.finishTests(err) }()
+	}
+
+	if m.config.ProxyListen != "" {
+		return m.runProxy()
+	}
+
+	if m.config.AutoBaud {
+		return m.runAutoBaud()
+	}
+
+	if m.config.TargetsFile != "" || len(m.config.Sweeps) > 0 {
+		return m.runSweep()
+	}
+
+	if m.config.Scan {
+		return m.runScan()
+	}
+
+	if m.config.ExceptionStatus || m.config.Diagnostic || m.config.CommEvents || m.config.CommLog || m.config.ServerID {
+		return m.runDiagnostics()
+	}
+
+	// Keep stdout clean for binary records
+	m.out = os.Stdout
+	if m.recordsOnStdout() || m.config.Output != "text" {
+		m.out = os.Stderr
+	}
+	m.formatter = m.newValueFormatter()
+	m.colorOut = colorsFor(os.Stdout, m.config.NoColor)
+	m.colorErr = colorsFor(os.Stderr, m.config.NoColor)
+
+	if m.config.Fanout {
+		return m.runFanout()
+	}
+
 	if err := m.setupClient(); err != nil {
 		return err
 	}
 
+	var sinks []sink
+	if m.config.Format != "text" {
+		records, err := newRecordSink(m.config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, records)
+	}
+	if m.config.NATSURL != "" {
+		sinks = append(sinks, newNATSPublisher(m.config.NATSURL, m.config.NATSSubject, m.config.Timeout))
+	}
+	if m.config.SinkExec != "" {
+		sinks = append(sinks, newExecSink(m.config.SinkExec))
+	}
+	if len(sinks) > 0 {
+		m.bus = newEventBus(sinks, m.config.QueueSize, m.config.QueuePolicy)
+		defer m.bus.Close(m.config.Timeout)
+	}
+
+	// Report health while polling continuously; the device counts as lost
+	// after three poll intervals without a response
+	if !m.config.PollOnce {
+		m.health = newHealthState(3*(m.config.PollRate+m.config.Timeout), m.target(), m.config.SlaveID)
+		if m.config.HealthListen != "" {
+			listener, err := net.Listen("tcp", m.config.HealthListen)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s: %v", m.config.HealthListen, err)
+			}
+			defer listener.Close()
+			go m.serveHealth(listener)
+		}
+		if m.config.PprofListen != "" {
+			listener, err := net.Listen("tcp", m.config.PprofListen)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s: %v", m.config.PprofListen, err)
+			}
+			defer listener.Close()
+			go m.servePprof(listener)
+		}
+		if interval := watchdogInterval(); interval > 0 {
+			done := make(chan struct{})
+			defer close(done)
+			go m.runWatchdog(interval, done)
+		}
+	}
+
 	// Print configuration before attempting connection
 	if m.config.Verbose {
 		m.printConfig()
@@ -78,6 +400,9 @@ func (m *ModbusCLI) run() error {
 			if m.config.PollOnce {
 				return err
 			}
+			if m.health != nil {
+				m.health.record(m.target(), m.config.SlaveID, err)
+			}
 
 			// Check if it's a connection error that should be retried
 			if m.isConnectionError(err) {
@@ -97,62 +422,107 @@ func (m *ModbusCLI) run() error {
 
 	defer m.client.Close()
 
+	sdNotify("READY=1")
+	defer sdNotify("STOPPING=1")
+
 	return m.execute()
 }
 
-func (m *ModbusCLI) parseArgs() (*Config, error) {
-	config := &Config{
-		Mode:      "tcp",
-		Port:      502,
-		SlaveID:   1,
-		StartRef:  1,
-		Count:     1,
-		DataType:  "4", // Default to holding register
-		Baudrate:  19200,
-		Databits:  8,
-		Stopbits:  1,
-		Parity:    "even",
-		Timeout:   time.Second,
-		PollRate:  time.Second,
-		BigEndian: true,
+// defaultConfig returns the configuration used when no options are given.
+func defaultConfig() *Config {
+	return &Config{
+		Mode:         "tcp",
+		Port:         502,
+		SlaveID:      1,
+		StartRef:     1,
+		Count:        1,
+		DataType:     "4", // Default to holding register
+		Baudrate:     19200,
+		Databits:     8,
+		Stopbits:     1,
+		Parity:       "even",
+		Timeout:      time.Second,
+		PollRate:     time.Second,
+		BigEndian:    true,
+		Votes:        1,
+		ReadOnly:     lockedReadOnly == "true",
+		NATSSubject:  "gomodbus",
+		Format:       "text",
+		Output:       "text",
+		Keep:         10,
+		QueueSize:    100,
+		QueuePolicy:  policyDropOldest,
+		SweepRate:    20,
+		Warmup:       20,
+		BusyPatience: 2 * time.Second,
+		LockRef:      -1,
+		LockToken:    -1,
+		LockWait:     5 * time.Second,
+		SyncRef:      -1,
+		SyncWait:     5 * time.Second,
+		ReplaySpeed:  1,
+		ScanFirst:    1,
+		ScanLast:     247,
+		ScanProbe:    "read",
+		CountUnit:    "values",
+	}
+}
+
+func (m *ModbusCLI) parseArgs(args []string) (*Config, error) {
+	config := defaultConfig()
+
+	env, err := envArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	args = append(env, args...)
+
+	if err := selectLanguage(args); err != nil {
+		return nil, err
 	}
 
-	args := os.Args[1:]
 	i := 0
 
+	// Positional arguments are resolved once all options are known, as the
+	// mode decides whether the target is a host or a serial device
+	var positionals, valueArgs []string
+
 	for i < len(args) {
 		arg := args[i]
+		kind, known := options[arg]
+		if !known && arg != "--" && strings.HasPrefix(arg, "-") && !isWriteValue(arg) {
+			return nil, fmt.Errorf("unknown option: %s", arg)
+		}
+		if kind == optionValue && i+1 >= len(args) {
+			return nil, fmt.Errorf("missing value for %s", arg)
+		}
+
 		switch arg {
 		case "-m", "--mode":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			config.Mode = args[i+1]
 			i += 2
 
 		case "-a", "--address":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
+			ids, err := parseSlaveList(args[i+1])
+			if err != nil {
+				return nil, err
 			}
-			addrStr := args[i+1]
-			if strings.Contains(addrStr, ",") || strings.Contains(addrStr, ":") {
-				// Multiple addresses - for now, take the first one
-				parts := strings.Split(addrStr, ",")
-				if len(parts) > 0 {
-					addrStr = parts[0]
-				}
+			config.SlaveID = ids[0]
+			config.SlaveIDs = nil
+			if len(ids) > 1 {
+				config.SlaveIDs = ids
 			}
-			addr, err := strconv.Atoi(addrStr)
+			i += 2
+
+		case "--label":
+			labels, err := parseLabels(args[i+1], config.Labels)
 			if err != nil {
-				return nil, fmt.Errorf("invalid slave address: %v", err)
+				return nil, err
 			}
-			config.SlaveID = addr
+			config.Labels = labels
 			i += 2
 
 		case "-r", "--reference":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			ref, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid reference: %v", err)
@@ -161,21 +531,32 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-c", "--count":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
+			if args[i+1] == "auto" {
+				config.CountAuto = true
+				i += 2
+				break
 			}
 			count, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid count: %v", err)
 			}
 			config.Count = count
+			config.CountAuto = false
+			i += 2
+
+		case "--count-unit":
+			config.CountUnit = args[i+1]
 			i += 2
 
 		case "-t", "--type":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
+			dataType, exponent, fixed, err := parseFixedType(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.DataType = dataType
+			if fixed {
+				config.FixedExponent = exponent
 			}
-			config.DataType = args[i+1]
 			i += 2
 
 		case "-0", "--zero-based":
@@ -191,9 +572,6 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i++
 
 		case "-l", "--poll-rate":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			rate, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid poll rate: %v", err)
@@ -202,9 +580,6 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-o", "--timeout":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			timeout, err := strconv.ParseFloat(args[i+1], 64)
 			if err != nil {
 				return nil, fmt.Errorf("invalid timeout: %v", err)
@@ -212,10 +587,67 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			config.Timeout = time.Duration(timeout * float64(time.Second))
 			i += 2
 
-		case "-p", "--port":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
+		case "--connect-timeout":
+			timeout, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid connect timeout: %v", err)
+			}
+			config.ConnectTimeout = time.Duration(timeout * float64(time.Second))
+			i += 2
+
+		case "--busy-patience":
+			patience, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid busy patience: %v", err)
+			}
+			config.BusyPatience = time.Duration(patience * float64(time.Second))
+			i += 2
+
+		case "--lock":
+			ref, confirm, err := parseLockRefs(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.LockRef, config.LockConfirm = ref, confirm
+			i += 2
+
+		case "--lock-token", "--lock-free":
+			value, err := strconv.ParseUint(args[i+1], 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semaphore value: %s", args[i+1])
+			}
+			if arg == "--lock-token" {
+				config.LockToken = int(value)
+			} else {
+				config.LockFree = uint16(value)
+			}
+			i += 2
+
+		case "--lock-wait":
+			wait, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid lock wait: %v", err)
 			}
+			config.LockWait = time.Duration(wait * float64(time.Second))
+			i += 2
+
+		case "--sync-register":
+			ref, err := strconv.Atoi(args[i+1])
+			if err != nil || ref < 0 || ref > 65535 {
+				return nil, fmt.Errorf("invalid scan-complete register: %s", args[i+1])
+			}
+			config.SyncRef = ref
+			i += 2
+
+		case "--sync-wait":
+			wait, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sync wait: %v", err)
+			}
+			config.SyncWait = time.Duration(wait * float64(time.Second))
+			i += 2
+
+		case "-p", "--port":
 			port, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid port: %v", err)
@@ -224,9 +656,6 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-b", "--baudrate":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			baudrate, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid baudrate: %v", err)
@@ -235,9 +664,6 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-d", "--databits":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			databits, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid databits: %v", err)
@@ -246,9 +672,6 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-s", "--stopbits":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			stopbits, err := strconv.Atoi(args[i+1])
 			if err != nil {
 				return nil, fmt.Errorf("invalid stopbits: %v", err)
@@ -257,117 +680,488 @@ func (m *ModbusCLI) parseArgs() (*Config, error) {
 			i += 2
 
 		case "-P", "--parity":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
 			config.Parity = args[i+1]
 			i += 2
 
+		case "--coil-byte-swap":
+			config.CoilSwap = true
+			i++
+
+		case "--read-twice":
+			config.Votes = 2
+			i++
+
+		case "--fail-fast":
+			config.FailFast = true
+			i++
+
+		case "--vote":
+			votes, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid vote count: %v", err)
+			}
+			config.Votes = votes
+			i += 2
+
+		case "--proxy":
+			config.ProxyListen = args[i+1]
+			i += 2
+
+		case "--upstream":
+			config.Upstream = args[i+1]
+			i += 2
+
+		case "--read-only":
+			config.ReadOnly = true
+			i++
+
+		case "--no-color":
+			config.NoColor = true
+			i++
+
+		case "--treat-as-holding":
+			config.TreatAsHolding = true
+			i++
+
+		case "--nats":
+			config.NATSURL = args[i+1]
+			i += 2
+
+		case "--subject":
+			config.NATSSubject = args[i+1]
+			i += 2
+
+		case "--sink-exec":
+			config.SinkExec = args[i+1]
+			i += 2
+
+		case "--format":
+			config.Format = args[i+1]
+			i += 2
+
+		case "--output":
+			config.Output = args[i+1]
+			i += 2
+
+		case "--out":
+			config.OutFile = args[i+1]
+			i += 2
+
+		case "--rotate":
+			size, every, err := parseRotation(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.RotateSize, config.RotateEvery = size, every
+			i += 2
+
+		case "--keep":
+			keep, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid keep count: %v", err)
+			}
+			config.Keep = keep
+			i += 2
+
+		case "--queue-size":
+			size, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid queue size: %v", err)
+			}
+			config.QueueSize = size
+			i += 2
+
+		case "--queue-policy":
+			config.QueuePolicy = args[i+1]
+			i += 2
+
+		case "--cdc":
+			config.CDC = true
+			i++
+
+		case "--decoder":
+			config.Decoder = args[i+1]
+			i += 2
+
+		case "--health-listen":
+			config.HealthListen = args[i+1]
+			i += 2
+
+		case "--pprof":
+			config.PprofListen = args[i+1]
+			i += 2
+
+		case "--exception-status":
+			config.ExceptionStatus = true
+			i++
+
+		case "--diagnostic":
+			sub, data, err := parseDiagnostic(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.Diagnostic = true
+			config.DiagSub, config.DiagData = sub, data
+			i += 2
+
+		case "--comm-events":
+			config.CommEvents = true
+			i++
+
+		case "--comm-log":
+			config.CommLog = true
+			i++
+
+		case "--server-id":
+			config.ServerID = true
+			i++
+
+		case "--scan":
+			config.Scan = true
+			i++
+
+		case "--scan-units":
+			first, last, err := parseUnitRange(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.ScanFirst, config.ScanLast = first, last
+			i += 2
+
+		case "--scan-probe":
+			config.ScanProbe = args[i+1]
+			i += 2
+
+		case "--bit-names":
+			names, err := parseBitNames(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.BitNames = names
+			i += 2
+
+		case "--expect":
+			config.ExpectSrc = args[i+1]
+			i += 2
+
+		case "--coil-names":
+			names, err := parseCoilNames(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.CoilNames = names
+			i += 2
+
+		case "--layout":
+			layout, err := parseLayout(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.Layout = layout
+			i += 2
+
+		case "--report":
+			config.Report = args[i+1]
+			i += 2
+
+		case "--control":
+			config.Control = args[i+1]
+			i += 2
+
+		case "--latency-json":
+			config.LatencyJSON = args[i+1]
+			i += 2
+
+		case "--push-gateway":
+			config.PushGateway = args[i+1]
+			i += 2
+
+		case "--write-loop":
+			pattern, err := parseWritePattern(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.WriteLoop = pattern
+			i += 2
+
+		case "--repeat":
+			config.Repeat = true
+			i++
+
+		case "--rollback":
+			config.Rollback = true
+			i++
+
+		case "--atomic":
+			config.Atomic = true
+			i++
+
+		case "--single":
+			config.Single = true
+			i++
+
+		case "--fanout":
+			config.Fanout = true
+			i++
+
+		case "--at":
+			at, err := parseWriteAt(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.WriteAt = at
+			i += 2
+
+		case "--in":
+			delay, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid delay: %v", err)
+			}
+			config.WriteAt = time.Now().Add(delay)
+			i += 2
+
+		case "--replay":
+			steps, err := loadReplay(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.Replay = steps
+			i += 2
+
+		case "--from":
+			config.BatchFile = args[i+1]
+			i += 2
+
+		case "--sheet":
+			config.BatchSheet = args[i+1]
+			i += 2
+
+		case "--replay-speed":
+			speed, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid replay speed: %v", err)
+			}
+			config.ReplaySpeed = speed
+			i += 2
+
+		case "--journal":
+			config.Journal = args[i+1]
+			i += 2
+
+		case "--session":
+			config.Session = args[i+1]
+			i += 2
+
+		case "--last":
+			config.UndoLast = true
+			i++
+
+		case "--junit":
+			config.JUnit = args[i+1]
+			i += 2
+
+		case "--tap":
+			config.TAP = args[i+1]
+			i += 2
+
+		case "--write-window":
+			window, err := parseWriteWindow(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			config.WriteWindows = append(config.WriteWindows, window)
+			i += 2
+
+		case "--targets-file":
+			config.TargetsFile = args[i+1]
+			i += 2
+
+		case "--sweep":
+			config.Sweeps = append(config.Sweeps, args[i+1])
+			i += 2
+
+		case "--sweep-rate":
+			rate, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid sweep rate: %v", err)
+			}
+			config.SweepRate = rate
+			i += 2
+
+		case "--auto-timeout":
+			config.AutoTimeout = true
+			i++
+
+		case "--warmup":
+			warmup, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid warmup count: %v", err)
+			}
+			config.Warmup = warmup
+			i += 2
+
+		case "--mask-values":
+			config.MaskValues = true
+			i++
+
+		case "--truncate":
+			config.Truncate = true
+			i++
+
+		case "--auto-baud":
+			config.AutoBaud = true
+			i++
+
 		case "-v", "--verbose":
 			config.Verbose = true
 			i++
 
+		case "--lang":
+			// Already applied by selectLanguage
+			i += 2
+
 		case "-h", "--help":
 			m.printHelp()
 			os.Exit(0)
 
 		case "-V", "--version":
-			fmt.Println("gomodbus v1.0.0")
+			printBuildInfo(os.Stdout)
 			os.Exit(0)
 
+		case "--values":
+			// Consume every following write value, so negative values
+			// like -1 aren't mistaken for options
+			j := i + 1
+			for ; j < len(args) && isWriteValueList(args[j]); j++ {
+				valueArgs = append(valueArgs, strings.Split(args[j], ",")...)
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			i = j
+
 		default:
-			if arg == "--" {
-				// Write values start here
-				config.WriteValues = make([]interface{}, 0)
-				for j := i + 1; j < len(args); j++ {
-					val, err := strconv.ParseFloat(args[j], 64)
-					if err != nil {
-						return nil, fmt.Errorf("invalid write value: %s", args[j])
-					}
-					config.WriteValues = append(config.WriteValues, val)
+			switch {
+			case arg == "--":
+				// Everything after -- is a write value
+				valueArgs = append(valueArgs, args[i+1:]...)
+				i = len(args)
+			case strings.HasPrefix(arg, unitScheme):
+				// unit://UNIT addresses a unit on a multi-drop line, the
+				// same as -a, and is followed by the values to write to it
+				ids, err := parseSlaveList(strings.TrimPrefix(arg, unitScheme))
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s: %v", arg, err)
+				}
+				config.SlaveID = ids[0]
+				config.SlaveIDs = nil
+				if len(ids) > 1 {
+					config.SlaveIDs = ids
+				}
+				j := i + 1
+				for ; j < len(args) && isWriteValueList(args[j]); j++ {
+					valueArgs = append(valueArgs, strings.Split(args[j], ",")...)
 				}
-				break
-			} else if strings.HasPrefix(arg, "-") {
-				return nil, fmt.Errorf("unknown option: %s", arg)
-			} else {
-				// Positional arguments
-				if config.Host == "" && config.Device == "" {
-					if config.Mode == "tcp" {
-						config.Host = arg
-					} else {
-						config.Device = arg
-					}
-				} else if len(config.WriteValues) == 0 {
-					// Write values
-					for ; i < len(args); i++ {
-						val, err := strconv.ParseFloat(args[i], 64)
-						if err != nil {
-							return nil, fmt.Errorf("invalid write value: %s", args[i])
-						}
-						config.WriteValues = append(config.WriteValues, val)
-					}
+				if j == i+1 {
+					return nil, fmt.Errorf("missing write values after %s", arg)
 				}
+				i = j
+			case known:
+				// Listed in options but not handled above
+				return nil, fmt.Errorf("unsupported option: %s", arg)
+			default:
+				positionals = append(positionals, arg)
 				i++
 			}
 		}
 	}
 
-	if config.Host == "" && config.Device == "" {
-		return nil, fmt.Errorf("device or host parameter missing ! Try -h for help")
+	// The environment supplies the target only when the command line doesn't
+	if target := os.Getenv(envTarget); target != "" && (len(positionals) == 0 || isWriteValue(positionals[0])) {
+		positionals = append([]string{target}, positionals...)
 	}
 
-	// Validation
-	if err := m.validateConfig(config); err != nil {
+	if err := assignPositionals(config, positionals, valueArgs); err != nil {
 		return nil, err
 	}
-
-	return config, nil
-}
-
-func (m *ModbusCLI) validateConfig(config *Config) error {
-	// Validate count range
-	if config.Count < 1 || config.Count > 125 {
-		return fmt.Errorf("count must be between 1 and 125")
+	// Some gateways expose input register data in holding registers
+	if config.TreatAsHolding && strings.HasPrefix(config.DataType, "3") {
+		config.DataType = "4" + config.DataType[1:]
 	}
-
-	// Validate slave address range
-	if config.SlaveID < 0 || config.SlaveID > 255 {
-		return fmt.Errorf("slave address must be between 0 and 255")
+	if config.CountAuto {
+		config.resolveAutoCount()
 	}
 
-	// Validate baudrate range
-	if config.Baudrate < 1200 || config.Baudrate > 921600 {
-		return fmt.Errorf("baudrate must be between 1200 and 921600")
+	// The sheet may be named after the file
+	if config.BatchFile != "" {
+		rows, err := loadBatch(config.BatchFile, config.BatchSheet)
+		if err != nil {
+			return nil, err
+		}
+		config.Batch = rows
 	}
 
-	// Validate databits
-	if config.Databits != 7 && config.Databits != 8 {
-		return fmt.Errorf("databits must be 7 or 8")
+	// Names may be defined after the expression that uses them
+	if config.ExpectSrc != "" {
+		expr, err := parseBoolExpr(config.ExpectSrc, config.CoilNames)
+		if err != nil {
+			return nil, err
+		}
+		config.Expect = expr
 	}
 
-	// Validate stopbits
-	if config.Stopbits != 1 && config.Stopbits != 2 {
-		return fmt.Errorf("stopbits must be 1 or 2")
+	// Default the upstream port to --port when none is given
+	if config.Upstream != "" {
+		if _, _, err := net.SplitHostPort(config.Upstream); err != nil {
+			config.Upstream = net.JoinHostPort(config.Upstream, strconv.Itoa(config.Port))
+		}
 	}
 
-	// Validate parity
-	validParities := map[string]bool{
-		"none": true,
-		"even": true,
-		"odd":  true,
-	}
-	if !validParities[config.Parity] {
-		return fmt.Errorf("parity must be none, even, or odd")
+	// Validation
+	if err := m.validateConfig(config); err != nil {
+		return nil, err
 	}
 
-	// Validate poll rate
-	if config.PollRate < 10*time.Millisecond {
-		return fmt.Errorf("poll rate must be at least 10ms")
+	return config, nil
+}
+
+// unitScheme prefixes a unit address on the command line, as in
+// unit://3 120 for writing 120 to unit 3 of a multi-drop line.
+const unitScheme = "unit://"
+
+// assignPositionals splits the positional arguments into the device or host
+// and the write values. The first positional is the target and the rest are
+// write values, so a number before the target is an error rather than being
+// taken for one; values given with --values, -- or unit://UNIT can't be
+// mixed with positional ones.
+func assignPositionals(config *Config, positionals, valueArgs []string) error {
+	if len(positionals) > 0 {
+		if isWriteValue(positionals[0]) {
+			return fmt.Errorf("unexpected argument %s: write values follow DEVICE|HOST, --values, -- or %sUNIT", positionals[0], unitScheme)
+		}
+		if config.Mode == "rtu" {
+			config.Device = positionals[0]
+		} else {
+			config.Host = positionals[0]
+		}
+
+		rest := positionals[1:]
+		for _, p := range rest {
+			if !isWriteValue(p) {
+				return fmt.Errorf("unexpected argument %s", p)
+			}
+		}
+		if len(rest) > 0 && len(valueArgs) > 0 {
+			return fmt.Errorf("write values must be given either after DEVICE|HOST or after --values, -- or %sUNIT, not both", unitScheme)
+		}
+		valueArgs = append(valueArgs, rest...)
 	}
 
-	// Validate timeout
-	if config.Timeout < 10*time.Millisecond || config.Timeout > 10*time.Second {
-		return fmt.Errorf("timeout must be between 0.01 and 10.00 seconds")
+	for _, arg := range valueArgs {
+		if !isWriteValue(arg) {
+			return fmt.Errorf("invalid write value: %s", arg)
+		}
+		config.WriteArgs = append(config.WriteArgs, arg)
 	}
 
 	return nil
@@ -403,16 +1197,9 @@ func (m *ModbusCLI) setupClient() error {
 			Speed:    uint(m.config.Baudrate),
 			DataBits: uint(m.config.Databits),
 			StopBits: uint(m.config.Stopbits),
+			Parity:   m.getParity(),
 			Timeout:  m.config.Timeout,
 		})
-		if m.config.Parity != "none" {
-			switch m.config.Parity {
-			case "even":
-				// Default for RTU
-			case "odd":
-				// Set odd parity
-			}
-		}
 	case "rtuovertcp":
 		url = fmt.Sprintf("rtuovertcp://%s:%d", m.config.Host, m.config.Port)
 		m.client, err = modbus.NewClient(&modbus.ClientConfiguration{
@@ -434,11 +1221,34 @@ func (m *ModbusCLI) setupClient() error {
 	return err
 }
 
+// dialTimeout returns the timeout for opening a connection: the one given
+// with --connect-timeout, or else the request timeout.
+func (c *Config) dialTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return c.Timeout
+}
+
 func (m *ModbusCLI) connect() error {
+	// The client dials with a fixed timeout of its own (5 seconds, 15 for
+	// TLS), so check the port with the connect timeout first
+	if m.config.ConnectTimeout > 0 && (m.config.Mode == "tcp" || m.config.Mode == "tls" || m.config.Mode == "rtuovertcp") {
+		address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+		conn, err := net.DialTimeout("tcp", address, m.config.ConnectTimeout)
+		if err != nil {
+			m.conns.failed.Add(1)
+			return fmt.Errorf("failed to connect: %v", err)
+		}
+		conn.Close()
+	}
+
 	err := m.client.Open()
 	if err != nil {
+		m.conns.failed.Add(1)
 		return fmt.Errorf("failed to connect: %v", err)
 	}
+	m.conns.opened.Add(1)
 
 	m.client.SetUnitId(uint8(m.config.SlaveID))
 
@@ -453,45 +1263,154 @@ func (m *ModbusCLI) connect() error {
 }
 
 func (m *ModbusCLI) execute() error {
-	startRef := m.config.StartRef
-	if m.config.ZeroBased {
-		startRef = 0
-	}
+	startRef := m.startRef()
 
 	if m.config.Verbose {
 		m.printConfig()
 	}
 
+	if m.config.Report != "" {
+		m.report = newRunReport(m.config.Report, m.reportConfig())
+		defer m.finishReport()
+	}
+
+	if m.config.Replay != nil {
+		return m.runReplay()
+	}
+
+	if m.config.Batch != nil {
+		return m.runBatch()
+	}
+
 	// If write values are provided, perform write operation
-	if len(m.config.WriteValues) > 0 {
-		return m.performWriteOperation(startRef)
+	if len(m.config.WriteArgs) > 0 {
+		if !m.config.WriteAt.IsZero() {
+			if due, err := m.waitForWriteTime(startRef); !due || err != nil {
+				return err
+			}
+		}
+		if !m.config.Repeat {
+			started := time.Now()
+			m.report.setValues(m.config.WriteArgs, m.config.MaskValues)
+			err := m.withLock(func() error { return m.journaledWrite(startRef) })
+			m.report.request("write", startRef, len(m.config.WriteArgs), started, err)
+			return err
+		}
 	}
 
-	// Otherwise, perform read operation
-	for {
-		if err := m.performOperation(startRef); err != nil {
+	// Stop polling cleanly on Ctrl-C so output sinks get flushed
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	stats := newPollStats()
+	if !m.config.PollOnce {
+		defer stats.print(m.out)
+	}
+
+	if m.config.LatencyJSON != "" || m.config.PushGateway != "" {
+		m.latency = &latencyHistogram{}
+		defer m.exportLatency()
+	}
+
+	if m.config.WriteLoop != nil || m.config.Repeat {
+		return m.runWriteLoop(startRef, stop, stats)
+	}
+
+	if m.config.AutoTimeout && !m.config.PollOnce {
+		m.tuner = newTimeoutTuner(m.config.Warmup)
+	}
+
+	if m.config.Control != "" {
+		closeControl, err := m.listenControl(m.config.Control)
+		if err != nil {
 			return err
 		}
+		defer closeControl()
+	}
+
+	wait := newPollTimer()
+	defer wait.Stop()
+
+	// Otherwise, perform read operation, unit by unit when -a lists several
+	units := m.config.units()
+	for {
+		var failed []error // units that failed in a --once cycle
+		for _, unit := range units {
+			if err := m.selectUnit(unit); err != nil {
+				return err
+			}
+			started := time.Now()
+			err := m.withLock(func() error { return m.performOperation(startRef) })
+			m.report.request("read", startRef, m.config.registerCount(), started, err)
+			stats.record(err)
+			if m.health != nil {
+				m.health.record(m.target(), m.config.SlaveID, err)
+			}
+			m.noteComm(err)
+			if m.tests != nil && err != nil && !errors.Is(err, errExpectationFailed) {
+				m.tests.add(m.expectCaseName(), time.Since(started), testError, localizeError(err))
+			}
+			if err != nil {
+				if m.tag != "" {
+					err = fmt.Errorf("%s: %w", m.tag, err)
+				}
+				// Device and link errors are tallied and polling carries on,
+				// with the other units of a --once cycle too
+				if _, ok := classifyError(err); !ok || m.config.PollOnce && (m.config.FailFast || len(units) == 1) {
+					return err
+				}
+				m.printPollError(err)
+				failed = append(failed, err)
+			}
+		}
+		if m.config.PollOnce && len(failed) > 0 {
+			return fmt.Errorf("%d of %d units failed, first: %w", len(failed), len(units), failed[0])
+		}
+
+		if m.tuner != nil && m.tuner.ready() {
+			if err := m.applyTunedTimeout(); err != nil {
+				return err
+			}
+		}
 
 		if m.config.PollOnce {
 			break
 		}
 
-		time.Sleep(m.config.PollRate)
+		if m.waitForNextPoll(wait, stop, stats) {
+			return nil
+		}
 	}
 
 	return nil
 }
 
+// newPollTimer returns a stopped timer for waiting out the poll interval.
+// Resetting one timer each cycle avoids the timer time.After allocates.
+func newPollTimer() *time.Timer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return timer
+}
+
+// startRef returns the first reference to read or write.
+func (m *ModbusCLI) startRef() int {
+	if m.config.ZeroBased {
+		return 0
+	}
+	return m.config.StartRef
+}
+
 func (m *ModbusCLI) performOperation(startRef int) error {
 	switch m.config.DataType {
 	case "0":
 		return m.readCoils(startRef)
 	case "1":
 		return m.readDiscreteInputs(startRef)
-	case "3", "3:hex", "3:int", "3:float":
+	case "3", "3:hex", "3:int", "3:float", "3:fixed", "3:fixed32":
 		return m.readInputRegisters(startRef)
-	case "4", "4:hex", "4:int", "4:float":
+	case "4", "4:hex", "4:int", "4:float", "4:fixed", "4:fixed32":
 		return m.readHoldingRegisters(startRef)
 	default:
 		return fmt.Errorf("unsupported data type: %s", m.config.DataType)
@@ -513,14 +1432,23 @@ func (m *ModbusCLI) isConnectionError(err error) bool {
 }
 
 func (m *ModbusCLI) performWriteOperation(startRef int) error {
-	if len(m.config.WriteValues) == 0 {
+	if len(m.config.WriteArgs) == 0 {
 		return fmt.Errorf("no write values provided")
 	}
+	restore, err := m.expandWriteArgs()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if err := m.config.checkWrite(time.Now()); err != nil {
+		return fmt.Errorf("refusing to write: %v", err)
+	}
 
 	switch m.config.DataType {
 	case "0":
 		return m.writeCoils(startRef)
-	case "4", "4:hex", "4:int", "4:float":
+	case "4", "4:hex", "4:int", "4:float", "4:fixed", "4:fixed32":
 		return m.writeHoldingRegisters(startRef)
 	default:
 		return fmt.Errorf("write operations not supported for data type: %s", m.config.DataType)
@@ -528,227 +1456,431 @@ func (m *ModbusCLI) performWriteOperation(startRef int) error {
 }
 
 func (m *ModbusCLI) readCoils(startRef int) error {
-	coils, err := m.client.ReadCoils(uint16(startRef), uint16(m.config.Count))
+	coils, err := readVoted(m.config.Votes, func() (values []bool, err error) {
+		err = m.retryBusy(false, func() error {
+			start := time.Now()
+			values, err = m.client.ReadCoils(uint16(startRef), uint16(m.coilReadCount()))
+			m.observe(start, err)
+			return err
+		})
+		return m.unswapCoils(values), err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read coils: %v", err)
+		return fmt.Errorf("failed to read coils: %w", err)
 	}
 
-	fmt.Printf("Coils (%d-%d):\n", startRef, startRef+m.config.Count-1)
-	for i, coil := range coils {
-		fmt.Printf("[%d]: %d\n", startRef+i, boolToInt(coil))
+	m.publish(startRef, coils)
+	if m.recordsOnStdout() {
+		return m.checkExpect(startRef, coils)
 	}
 
-	return nil
+	m.formatter.formatBits(startRef, coils, "Coils")
+	return m.checkExpect(startRef, coils)
 }
 
 func (m *ModbusCLI) readDiscreteInputs(startRef int) error {
-	inputs, err := m.client.ReadDiscreteInputs(uint16(startRef), uint16(m.config.Count))
+	inputs, err := readVoted(m.config.Votes, func() (values []bool, err error) {
+		err = m.retryBusy(false, func() error {
+			start := time.Now()
+			values, err = m.client.ReadDiscreteInputs(uint16(startRef), uint16(m.coilReadCount()))
+			m.observe(start, err)
+			return err
+		})
+		return m.unswapCoils(values), err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read discrete inputs: %v", err)
+		return fmt.Errorf("failed to read discrete inputs: %w", err)
 	}
 
-	fmt.Printf("Discrete Inputs (%d-%d):\n", startRef, startRef+m.config.Count-1)
-	for i, input := range inputs {
-		fmt.Printf("[%d]: %d\n", startRef+i, boolToInt(input))
+	m.publish(startRef, inputs)
+	if m.recordsOnStdout() {
+		return m.checkExpect(startRef, inputs)
 	}
 
-	return nil
+	m.formatter.formatBits(startRef, inputs, "Discrete Inputs")
+	return m.checkExpect(startRef, inputs)
 }
 
 func (m *ModbusCLI) readInputRegisters(startRef int) error {
-	registers, err := m.client.ReadRegisters(uint16(startRef), uint16(m.config.Count), modbus.INPUT_REGISTER)
+	registers, err := readVoted(m.config.Votes, func() ([]uint16, error) {
+		return m.readRegisterChunks(startRef, modbus.INPUT_REGISTER)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read input registers: %v", err)
+		return m.printPartial(startRef, registers, "Input Registers", fmt.Errorf("failed to read input registers: %w", err))
+	}
+
+	m.publish(startRef, registers)
+	if m.recordsOnStdout() {
+		return nil
 	}
 
-	return m.printRegisters(startRef, registers, "Input Registers")
+	return m.formatter.formatRegisters(startRef, registers, "Input Registers")
 }
 
 func (m *ModbusCLI) readHoldingRegisters(startRef int) error {
-	registers, err := m.client.ReadRegisters(uint16(startRef), uint16(m.config.Count), modbus.HOLDING_REGISTER)
+	registers, err := readVoted(m.config.Votes, func() ([]uint16, error) {
+		return m.readRegisterChunks(startRef, modbus.HOLDING_REGISTER)
+	})
+	if err != nil {
+		return m.printPartial(startRef, registers, "Holding Registers", fmt.Errorf("failed to read holding registers: %w", err))
+	}
+
+	m.publish(startRef, registers)
+	if m.recordsOnStdout() {
+		return nil
+	}
+
+	return m.formatter.formatRegisters(startRef, registers, "Holding Registers")
+}
+
+// printPartial lists the registers a read that failed with err got before
+// returning err, marking those of the failed requests as bad. Only complete
+// blocks go to records, sinks and --decoder.
+func (m *ModbusCLI) printPartial(startRef int, registers []uint16, regType string, err error) error {
+	var partial *partialReadError
+	if registers == nil || !errors.As(err, &partial) || m.recordsOnStdout() || m.config.Decoder != "" {
+		return err
+	}
+
+	m.bad = partial.bad
+	defer func() { m.bad = nil }()
+	if ferr := m.formatter.formatRegisters(startRef, registers, regType); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// publish puts the values of a successful read on the event bus.
+func (m *ModbusCLI) publish(startRef int, values interface{}) {
+	m.report.setValues(values, m.config.MaskValues)
+	if m.bus == nil {
+		return
+	}
+
+	sample := &pollSample{
+		Timestamp: time.Now(),
+		Source:    m.target(),
+		UnitID:    m.config.SlaveID,
+		Label:     m.tag,
+		DataType:  m.config.DataType,
+		Start:     startRef,
+		Values:    values,
+	}
+	sample.Hash = sampleHash(sample)
+
+	// With --cdc, only samples that differ from the last one go out
+	if m.config.CDC && !m.sampleChanged(sample) {
+		return
+	}
+	m.bus.Publish(busEvent{sample: sample})
+}
+
+// recordsOnStdout reports whether stdout carries output records instead of
+// the human-readable listing.
+func (m *ModbusCLI) recordsOnStdout() bool {
+	return m.config.Format != "text" && m.config.OutFile == ""
+}
+
+// target describes the device being talked to, as host:port or serial device.
+func (m *ModbusCLI) target() string {
+	if m.config.Device != "" {
+		return m.config.Device
+	}
+	return net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+}
+
+// errUnstableRead is returned when repeated reads of the same block disagree.
+var errUnstableRead = errors.New("unstable read")
+
+// readVoted performs the read the given number of times and only returns the
+// values if every read agrees with the first, guarding against noisy links
+// where garbage can still pass the CRC check.
+func readVoted[T comparable](votes int, read func() ([]T, error)) ([]T, error) {
+	// A partial read is passed on, but never voted on
+	first, err := read()
 	if err != nil {
-		return fmt.Errorf("failed to read holding registers: %v", err)
+		return first, err
 	}
 
-	return m.printRegisters(startRef, registers, "Holding Registers")
+	for n := 2; n <= votes; n++ {
+		next, err := read()
+		if err != nil {
+			return nil, err
+		}
+		if len(next) != len(first) {
+			return nil, fmt.Errorf("%w: read %d returned %d values, read 1 returned %d",
+				errUnstableRead, n, len(next), len(first))
+		}
+		for i := range first {
+			if next[i] != first[i] {
+				return nil, fmt.Errorf("%w: read %d disagrees with read 1 at offset %d (%v != %v)",
+					errUnstableRead, n, i, next[i], first[i])
+			}
+		}
+	}
+
+	return first, nil
 }
 
 func (m *ModbusCLI) writeCoils(startRef int) error {
-	if len(m.config.WriteValues) == 0 {
+	if len(m.config.WriteArgs) == 0 {
 		return fmt.Errorf("no values to write")
 	}
 
-	coils := make([]bool, len(m.config.WriteValues))
-	for i, val := range m.config.WriteValues {
-		coils[i] = val.(float64) != 0
+	coils, err := m.encodeWriteCoils()
+	if err != nil {
+		return err
 	}
 
-	err := m.client.WriteCoils(uint16(startRef), coils)
+	// More coils than one FC15 request carries go out in several
+	err = m.writeChunked(len(coils), m.config.writeChunkSize(), func(offset, count int) error {
+		return m.client.WriteCoils(uint16(startRef+offset), coils[offset:offset+count])
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write coils: %v", err)
+		return fmt.Errorf("failed to write coils: %w", err)
+	}
+
+	if m.config.jsonOutput() {
+		m.printBitsJSON("write", startRef, coils)
+		return nil
 	}
 
 	fmt.Printf("Successfully wrote %d coil(s) starting at address %d\n", len(coils), startRef)
 	for i, coil := range coils {
-		fmt.Printf("[%d]: %d\n", startRef+i, boolToInt(coil))
+		m.printValue(startRef+i, strconv.Itoa(boolToInt(coil)), "")
 	}
 
 	return nil
 }
 
 func (m *ModbusCLI) writeHoldingRegisters(startRef int) error {
-	if len(m.config.WriteValues) == 0 {
+	if len(m.config.WriteArgs) == 0 {
 		return fmt.Errorf("no values to write")
 	}
 
-	// Convert values based on data type
-	switch m.config.DataType {
-	case "4":
-		// 16-bit registers
-		registers := make([]uint16, len(m.config.WriteValues))
-		for i, val := range m.config.WriteValues {
-			registers[i] = uint16(val.(float64))
-		}
-		err := m.client.WriteRegisters(uint16(startRef), registers)
-		if err != nil {
-			return fmt.Errorf("failed to write holding registers: %v", err)
-		}
-		fmt.Printf("Successfully wrote %d 16-bit register(s) starting at address %d\n", len(registers), startRef)
-		for i, reg := range registers {
-			fmt.Printf("[%d]: %d\n", startRef+i, reg)
-		}
+	// Every data type has its own parser and range
+	registers, err := m.encodeWriteRegisters()
+	if err != nil {
+		return err
+	}
 
-	case "4:int":
-		// 32-bit integers
-		if len(m.config.WriteValues)%2 != 0 {
-			return fmt.Errorf("32-bit integers require even number of values")
-		}
-		values := make([]uint32, len(m.config.WriteValues)/2)
-		for i := 0; i < len(m.config.WriteValues); i += 2 {
-			high := uint32(m.config.WriteValues[i].(float64))
-			low := uint32(m.config.WriteValues[i+1].(float64))
-			if m.config.BigEndian {
-				values[i/2] = high<<16 | low
-			} else {
-				values[i/2] = low<<16 | high
-			}
-		}
-		err := m.client.WriteUint32s(uint16(startRef), values)
-		if err != nil {
-			return fmt.Errorf("failed to write 32-bit integers: %v", err)
-		}
-		fmt.Printf("Successfully wrote %d 32-bit integer(s) starting at address %d\n", len(values), startRef)
-		for i, val := range values {
-			fmt.Printf("[%d]: %d\n", startRef+i*2, val)
-		}
+	err = m.writeRegisters(startRef, registers, m.config.writeChunkSize())
+	if err != nil {
+		return fmt.Errorf("failed to write holding registers: %w", err)
+	}
 
-	case "4:float":
-		// 32-bit floats
-		if len(m.config.WriteValues)%2 != 0 {
-			return fmt.Errorf("32-bit floats require even number of values")
-		}
-		values := make([]float32, len(m.config.WriteValues)/2)
-		for i := 0; i < len(m.config.WriteValues); i += 2 {
-			high := uint32(m.config.WriteValues[i].(float64))
-			low := uint32(m.config.WriteValues[i+1].(float64))
-			var bits uint32
-			if m.config.BigEndian {
-				bits = high<<16 | low
-			} else {
-				bits = low<<16 | high
-			}
-			values[i/2] = math.Float32frombits(bits)
-		}
-		err := m.client.WriteFloat32s(uint16(startRef), values)
-		if err != nil {
-			return fmt.Errorf("failed to write 32-bit floats: %v", err)
-		}
-		fmt.Printf("Successfully wrote %d 32-bit float(s) starting at address %d\n", len(values), startRef)
-		for i, val := range values {
-			fmt.Printf("[%d]: %.2f\n", startRef+i*2, val)
-		}
+	if m.config.jsonOutput() {
+		m.printRegistersJSON("write", startRef, registers)
+		return nil
 	}
 
+	fmt.Printf("Successfully wrote %d %s(s) starting at address %d with %s\n",
+		len(m.config.WriteArgs), writtenValueName(m.config.DataType), startRef, m.config.registerWriteFunction())
+	m.printDecoded(startRef, registers)
+
 	return nil
 }
 
+// writtenValueName names the values of a data type in write confirmations.
+func writtenValueName(dataType string) string {
+	switch dataType {
+	case "4:int":
+		return "32-bit integer"
+	case "4:float":
+		return "32-bit float"
+	case "4:fixed":
+		return "16-bit fixed-point value"
+	case "4:fixed32":
+		return "32-bit fixed-point value"
+	default:
+		return "16-bit register"
+	}
+}
+
+// maskPlaceholder replaces values in output with --mask-values.
+const maskPlaceholder = "***"
+
+// numberPattern matches the numbers masked in free-form output lines.
+var numberPattern = regexp.MustCompile(`0[xX][0-9A-Fa-f]+|-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?`)
+
+// printValue prints an "[addr]: value" output line, followed by note in
+// parentheses when given. With --mask-values the value is replaced by a
+// placeholder so output can be shared without revealing process data.
+func (m *ModbusCLI) printValue(addr int, value, note string) {
+	m.endValue(append(m.beginValue(addr), value...), note)
+}
+
+// beginValue starts an "[addr]: " output line in the reusable line buffer;
+// the caller appends the value and passes the line to endValue. Polling
+// prints every value this way so a cycle doesn't allocate strings.
+func (m *ModbusCLI) beginValue(addr int) []byte {
+	line := m.appendTag(m.line[:0])
+	line = append(line, '[')
+	line = strconv.AppendInt(line, int64(addr), 10)
+	return append(line, "]: "...)
+}
+
+// endValue appends note in parentheses, when given, and prints a line
+// started by beginValue, masking the value with --mask-values.
+func (m *ModbusCLI) endValue(line []byte, note string) {
+	start := bytes.IndexByte(line, ']') + 3
+	if m.config.MaskValues {
+		line = append(line[:start], maskPlaceholder...)
+	}
+	end := len(line)
+	if note != "" {
+		line = append(line, " ("...)
+		line = append(line, note...)
+		line = append(line, ')')
+	}
+	line = append(line, '\n')
+	m.line = line
+	if m.colorOut && !m.config.MaskValues && m.valueChanged(line[:start], line[start:end]) {
+		line = m.paint(line, start, end, colorChanged)
+	}
+	os.Stdout.Write(line)
+}
+
+// printHeader prints the "Type (first-last):" line above a block of values.
+func (m *ModbusCLI) printHeader(regType string, startRef int) {
+	line := append(m.appendTag(m.line[:0]), regType...)
+	line = append(line, " ("...)
+	line = strconv.AppendInt(line, int64(startRef), 10)
+	line = append(line, '-')
+	line = strconv.AppendInt(line, int64(startRef+m.config.registerCount()-1), 10)
+	line = append(line, "):\n"...)
+	m.line = line
+	os.Stdout.Write(line)
+}
+
+// appendTag prefixes an output line with the tag of the polled unit, if any.
+func (m *ModbusCLI) appendTag(line []byte) []byte {
+	if m.tag == "" {
+		return line
+	}
+	return append(append(line, m.tag...), ' ')
+}
+
+// appendHex4 appends v as four uppercase hex digits.
+func appendHex4(line []byte, v uint16) []byte {
+	const digits = "0123456789ABCDEF"
+	return append(line, digits[v>>12], digits[v>>8&0xf], digits[v>>4&0xf], digits[v&0xf])
+}
+
+// maskNumbers replaces every number in line with the mask placeholder.
+func maskNumbers(line string) string {
+	return numberPattern.ReplaceAllString(line, maskPlaceholder)
+}
+
+// printBitBlock lists a block of coils or discrete inputs as 0 and 1.
+func (m *ModbusCLI) printBitBlock(startRef int, bits []bool, regType string) {
+	m.printHeader(regType, startRef)
+	for i, bit := range bits {
+		m.endValue(strconv.AppendInt(m.beginValue(startRef+i), int64(boolToInt(bit)), 10), "")
+	}
+}
+
 func (m *ModbusCLI) printRegisters(startRef int, registers []uint16, regType string) error {
-	fmt.Printf("%s (%d-%d):\n", regType, startRef, startRef+m.config.Count-1)
-
-	for i, reg := range registers {
-		addr := startRef + i
-		fmt.Printf("[%d]: %d", addr, reg)
-
-		// Handle different data type formats
-		switch m.config.DataType {
-		case "3:hex", "4:hex":
-			fmt.Printf(" (0x%04X)", reg)
-		case "3:int", "4:int":
-			if i%2 == 0 && i+1 < len(registers) {
-				// 32-bit integer
-				var val int32
-				if m.config.BigEndian {
-					val = int32(registers[i])<<16 | int32(registers[i+1])
-				} else {
-					val = int32(registers[i+1])<<16 | int32(registers[i])
-				}
-				fmt.Printf(" (%d as 32-bit int)", val)
-				i++ // Skip next register as it's part of this value
-			}
-		case "3:float", "4:float":
-			if i%2 == 0 && i+1 < len(registers) {
-				// 32-bit float
-				var val float32
-				if m.config.BigEndian {
-					val = math.Float32frombits(uint32(registers[i])<<16 | uint32(registers[i+1]))
-				} else {
-					val = math.Float32frombits(uint32(registers[i+1])<<16 | uint32(registers[i]))
-				}
-				fmt.Printf(" (%.2f as 32-bit float)", val)
-				i++ // Skip next register as it's part of this value
+	m.printHeader(regType, startRef)
+
+	if m.config.Decoder != "" {
+		lines, err := m.runDecoder(startRef, registers)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if m.config.MaskValues {
+				line = maskNumbers(line)
+			}
+			if m.tag != "" {
+				line = m.tag + " " + line
 			}
+			fmt.Println(line)
 		}
+		return nil
+	}
 
-		fmt.Println()
+	if m.config.Layout != nil {
+		m.printLayout(startRef, registers)
+		return nil
 	}
 
+	m.printDecoded(startRef, registers)
 	return nil
 }
 
-func (m *ModbusCLI) printConfig() {
-	fmt.Println("gomodbus 1.0.0 - Go Modbus Master CLI Tool")
-	fmt.Printf("                  Protocol configuration: Modbus %s\n", strings.ToUpper(m.config.Mode))
-
-	// Determine start reference for display
-	startRef := m.config.StartRef
-	if m.config.ZeroBased {
-		startRef = 0
+// printDecoded prints a block of registers one value of the data type per
+// line.
+func (m *ModbusCLI) printDecoded(startRef int, registers []uint16) {
+	m.decoded = decodeRegisters(m.decoded, registers, m.config.DataType, m.config.BigEndian)
+	hex := strings.HasSuffix(m.config.DataType, ":hex")
+	for _, value := range m.decoded {
+		line := m.beginValue(startRef + value.Offset)
+		switch {
+		case m.isBad(value.Offset, m.config.wordsPerValue()):
+			m.endValue(append(line, badValue...), "bad quality")
+		case value.Kind == kindInt32:
+			m.endValue(strconv.AppendInt(line, int64(value.Int32()), 10), "")
+		case value.Kind == kindFloat32:
+			m.endValue(strconv.AppendFloat(line, float64(value.Float32()), 'f', 2, 32), "")
+		case value.Kind == kindFixed16:
+			m.endValue(appendFixed(line, int64(int16(value.Bits)), m.config.FixedExponent), "")
+		case value.Kind == kindFixed32:
+			m.endValue(appendFixed(line, int64(value.Int32()), m.config.FixedExponent), "")
+		case value.Partial:
+			m.endValue(strconv.AppendUint(line, uint64(value.Bits), 10), "incomplete 32-bit value")
+		case hex:
+			line = strconv.AppendUint(line, uint64(value.Bits), 10)
+			line = append(line, " (0x"...)
+			m.endValue(append(appendHex4(line, uint16(value.Bits)), ')'), "")
+		default:
+			m.endValue(strconv.AppendUint(line, uint64(value.Bits), 10), "")
+		}
 	}
+}
 
-	// Determine data type description
-	var dataTypeDesc string
-	switch m.config.DataType {
+// dataTypeDescription describes a data type for configuration listings.
+func dataTypeDescription(dataType string) string {
+	switch dataType {
 	case "0":
-		dataTypeDesc = "discrete output (coil)"
+		return "discrete output (coil)"
 	case "1":
-		dataTypeDesc = "discrete input"
+		return "discrete input"
 	case "3", "3:hex":
-		dataTypeDesc = "16-bit input register"
+		return "16-bit input register"
 	case "3:int":
-		dataTypeDesc = "32-bit integer in input register"
+		return "32-bit integer in input register"
 	case "3:float":
-		dataTypeDesc = "32-bit float in input register"
+		return "32-bit float in input register"
+	case "3:fixed":
+		return "16-bit fixed-point in input register"
+	case "3:fixed32":
+		return "32-bit fixed-point in input register"
 	case "4", "4:hex":
-		dataTypeDesc = "16-bit output (holding) register"
+		return "16-bit output (holding) register"
 	case "4:int":
-		dataTypeDesc = "32-bit integer in output register"
+		return "32-bit integer in output register"
 	case "4:float":
-		dataTypeDesc = "32-bit float in output register"
+		return "32-bit float in output register"
+	case "4:fixed":
+		return "16-bit fixed-point in output register"
+	case "4:fixed32":
+		return "32-bit fixed-point in output register"
 	default:
-		dataTypeDesc = m.config.DataType
+		return dataType
 	}
+}
+
+func (m *ModbusCLI) printConfig() {
+	fmt.Printf("gomodbus %s - Go Modbus Master CLI Tool\n", version)
+	fmt.Printf("                  Protocol configuration: Modbus %s\n", strings.ToUpper(m.config.Mode))
+
+	// Determine start reference for display
+	startRef := m.startRef()
 
-	fmt.Printf("                  Slave configuration...: address = [%d]\n", m.config.SlaveID)
+	dataTypeDesc := dataTypeDescription(m.config.DataType)
+
+	fmt.Printf("                  Slave configuration...: address = %v\n", m.config.units())
 	fmt.Printf("                                          start reference = %d, count = %d\n", startRef, m.config.Count)
 
 	// Communication settings based on mode
@@ -762,12 +1894,15 @@ func (m *ModbusCLI) printConfig() {
 		fmt.Printf("                  Communication.........: %s:%d\n", m.config.Host, m.config.Port)
 		fmt.Printf("                                          t/o %.2f s, poll rate %d ms\n",
 			m.config.Timeout.Seconds(), int(m.config.PollRate.Milliseconds()))
+		if m.config.ConnectTimeout > 0 {
+			fmt.Printf("                                          connect t/o %.2f s\n", m.config.ConnectTimeout.Seconds())
+		}
 	}
 
 	fmt.Printf("                  Data type.............: %s", dataTypeDesc)
 
 	// Show if it's a write operation
-	if len(m.config.WriteValues) > 0 {
+	if len(m.config.WriteArgs) > 0 {
 		fmt.Printf(" (write operation)")
 	} else {
 		fmt.Printf(" (read operation)")
@@ -775,8 +1910,7 @@ func (m *ModbusCLI) printConfig() {
 	fmt.Println()
 
 	// Show endianness if relevant
-	if m.config.DataType == "3:int" || m.config.DataType == "3:float" ||
-		m.config.DataType == "4:int" || m.config.DataType == "4:float" {
+	if m.config.wordsPerValue() == 2 && m.config.Layout == nil {
 		if m.config.BigEndian {
 			fmt.Printf("                  Endianness............: Big endian\n")
 		} else {
@@ -785,6 +1919,17 @@ func (m *ModbusCLI) printConfig() {
 	}
 }
 
+func (m *ModbusCLI) getParity() uint {
+	switch m.config.Parity {
+	case "none":
+		return modbus.PARITY_NONE
+	case "odd":
+		return modbus.PARITY_ODD
+	default:
+		return modbus.PARITY_EVEN
+	}
+}
+
 func (m *ModbusCLI) getParityChar() byte {
 	switch m.config.Parity {
 	case "none":
@@ -799,22 +1944,46 @@ func (m *ModbusCLI) getParityChar() byte {
 }
 
 func (m *ModbusCLI) printHelp() {
-	fmt.Println(`gomodbus - Enhanced Modbus CLI tool
+	fmt.Println(localizeHelp(`gomodbus - Enhanced Modbus CLI tool
 
 USAGE:
   gomodbus [OPTIONS] DEVICE|HOST [WRITE_VALUES...] [OPTIONS]
+  gomodbus decode [--format cbor|msgpack|ndjson] [--text] [-t TYPE]
+                  [--layout SPEC] [--decoder CMD] [[--input] FILE]
+  gomodbus encode [-t TYPE] [-r REF] [--truncate] VALUES...
+  gomodbus serve [--map FILE] [--sunspec FILE...] [--stats FILE] [[HOST]:PORT]
+  gomodbus selftest [--junit FILE] [--tap FILE] [OPTIONS] DEVICE|HOST
+  gomodbus undo --last|--session NAME [OPTIONS] DEVICE|HOST
+  gomodbus verify-device SPEC [--junit FILE] [--tap FILE] [OPTIONS] DEVICE|HOST
+  gomodbus capabilities [--format text|json]
+  gomodbus ctl SOCKET pause|resume|rate MS|write [@UNIT] REF VALUES|status
 
 ARGUMENTS:
   DEVICE        Serial port when using Modbus RTU protocol
                 (e.g., /dev/ttyUSB0, COM1)
   HOST          Host name or IP address when using Modbus TCP protocol
   WRITE_VALUES  List of values to be written (if not specified, reads data)
+                Accepts scientific notation (1.5e3) and the suffixes
+                k, M and G (2k = 2000, 3.3M = 3300000). Values follow
+                DEVICE|HOST, --values, -- (needed for -1, which is
+                otherwise the --once option) or unit://UNIT, which also
+                selects the slave like -a (unit://3 120 writes 120 to
+                unit 3 of a multi-drop line)
 
 GENERAL OPTIONS:
   -m, --mode MODE         Mode: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp (default: tcp)
-  -a, --address ADDR      Slave address (1-255, default: 1)
+  -a, --address ADDR      Slave address (1-255, default: 1); a comma separated
+                          list polls each slave in turn
+  --label NAME=UNIT       Tag the output of slave UNIT with NAME in every
+                          output format, e.g. "boiler1=1,boiler2=2"
   -r, --reference REF     Start reference (default: 1)
-  -c, --count COUNT       Number of values to read (1-125, default: 1)
+  -c, --count COUNT       Number of values to read (1-2000 for coils and
+                          discrete inputs, default: 1), or "auto" to derive
+                          it from the write values; reads of more than 125
+                          registers are split into several requests
+  --count-unit UNIT       What COUNT counts for 32-bit data types: values
+                          (default, -t 4:float -c 4 reads 8 registers) or
+                          registers
   -t, --type TYPE         Data type:
                             0 = Discrete output (coil)
                             1 = Discrete input
@@ -822,30 +1991,231 @@ GENERAL OPTIONS:
                             3:hex = 16-bit input register (hex display)
                             3:int = 32-bit integer in input register
                             3:float = 32-bit float in input register
+                            3:fixed:SCALE = 16-bit signed fixed-point in
+                              input register, SCALE a power of ten (0.01)
+                            3:fixed32:SCALE = 32-bit fixed-point
                             4 = 16-bit output (holding) register (default)
                             4:hex = 16-bit output register (hex display)
                             4:int = 32-bit integer in output register
                             4:float = 32-bit float in output register
+                            4:fixed:SCALE, 4:fixed32:SCALE = fixed-point in
+                              output register
+  --treat-as-holding      Read and write the input register types (3...) as
+                          holding registers, for gateways that remap them
   -0, --zero-based        First reference is 0 (PDU addressing)
   -B, --big-endian        Big endian word order for 32-bit data (default)
   -1, --once              Poll only once, otherwise poll continuously
   -l, --poll-rate MS      Poll rate in milliseconds (default: 1000)
   -o, --timeout SEC       Timeout in seconds (default: 1.0)
+  --connect-timeout SEC   Timeout for opening a TCP connection, e.g. through
+                          a slow VPN (default: the -o timeout)
+  --values V1 [V2...]     Write values (space or comma separated)
+                          Values may be templates evaluated at send time,
+                          e.g. '{{ now.Unix }}' or '{{ env "SETPOINT" }}'
+  --busy-patience SEC     Keep re-issuing requests the device answers with
+                          Server Device Busy or Acknowledge for up to SEC
+                          seconds (0-60, default: 2.0, 0 = never retry)
+  --lock REF[:CONFIRM]    Hold the semaphore holding register REF around
+                          every read and write: write the token, read it
+                          back (from CONFIRM if the PLC confirms there),
+                          and write the free value back when done
+  --lock-token N          Value claiming the semaphore, unique to this
+                          client (required with --lock)
+  --lock-free N           Value of a free semaphore (default: 0)
+  --lock-wait SEC         How long to wait for a semaphore held by another
+                          client (0-600, default: 5.0)
+  --sync-register REF     Before every read and write, wait for the PLC's
+                          scan-complete holding register REF to change, so
+                          data blocks are accessed between scan cycles
+  --sync-wait SEC         How long to wait for the register to change
+                          (0-600, default: 5.0)
+  --auto-timeout          While polling, measure response latency over a
+                          warm-up phase and then set the timeout to 2 x p99
+  --warmup N              Successful requests measured before auto-tuning
+                          (5-1000, default: 20)
+  --layout SPEC           Decode register blocks (-t 3 or 4) as a sequence of
+                          mixed types, e.g. "u16,u16,temp=f32,i32,str[8]";
+                          types are u16, i16, u32, i32, u64, i64, f32, f64 and
+                          str[N] (N characters), -c repeats the layout
+  --decoder CMD           Decode register blocks with an external command
+                          (raw registers are passed as JSON on stdin, its
+                          output lines are printed instead of the values)
+  --coil-byte-swap        Swap the bytes of each 16-bit pair of coils or
+                          discrete inputs, for gateways that pack them in
+                          mid-endian order (reads whole byte pairs)
+  --read-twice            Read each block twice and only report agreeing values
+  --vote N                Read each block N times (1-10) and only report values
+                          when all reads agree; disagreements are flagged
+  --fail-fast             Give up a block read in several requests at the
+                          first failed one, and a --once poll of several
+                          units at the first failed unit, instead of listing
+                          what could be read with the rest marked bad
+  --read-only             Refuse all write operations, including proxy
+                          client requests other than reads
+  --write-window SPEC     Only permit writes during SPEC, e.g.
+                          "Mon-Fri 22:00-06:00" (local time, repeatable)
+  --at TIME               Hold the write until TIME (e.g. 2024-07-01T06:00:00,
+                          local time unless a zone is given), reading the
+                          start reference every poll interval meanwhile to
+                          keep the connection open
+  --in DURATION           Hold the write for DURATION (e.g. 10m, 1h30m)
+  --replay FILE           Write the rows of a CSV file of TIME,REF,VALUE...
+                          at their recorded pace (TIME a date and time or
+                          seconds), stopping at the first failed write
+  --replay-speed X        Replay X times faster (0.01-1000, default: 1)
+  --from FILE             Write the REF,VALUE... rows of a CSV file or Excel
+                          workbook (.xlsx) one after another, stopping at the
+                          first failed write
+  --sheet NAME            The sheet of the --from workbook (default: the first)
+  --journal FILE          Journal the original values of every write for
+                          undo (default: gomodbus/journal.jsonl in the user
+                          config directory, off to disable)
+  --session NAME          Group journaled writes under NAME (default: one
+                          session per run); with undo, revert the session
+  --last                  With undo, revert the latest write not yet undone
+  --junit FILE            With verify-device, selftest or --expect, also
+                          write the results as JUnit XML to FILE for CI
+  --tap FILE              Likewise, in the Test Anything Protocol (TAP 13)
+  --write-loop PATTERN    Write a pattern of values to the start reference
+                          every poll interval until interrupted:
+                          ramp:MIN:MAX[:STEP], square:LOW:HIGH[:HOLD],
+                          random:MIN:MAX or csv:FILE (replays the last
+                          column, looping)
+  --repeat                Write the values again every poll interval until
+                          interrupted, for devices whose watchdog resets
+                          outputs unless commands are refreshed
+  --rollback              Make writes longer than one request (1968 coils or
+                          123 registers) all-or-nothing: read the original
+                          values first and write them back if a later
+                          request fails
+  --atomic                Emulate a transactional write: read the original
+                          values, write, read the values back to verify them,
+                          and write the originals back if any request fails
+                          or any value didn't take
+  --single                Write holding registers with Write Single Register
+                          (FC06), one request per register, for devices that
+                          don't accept Write Multiple Registers (FC16)
+  --fanout                Apply the write to every slave of the -a list on
+                          every host of a comma separated HOST[:PORT] list,
+                          then list which targets took it; with --fail-fast
+                          the rest are skipped after the first failure
+  --truncate              Silently truncate out-of-range or fractional write
+                          values instead of rejecting them
 
 TCP OPTIONS:
   -p, --port PORT         TCP port number (default: 502)
 
+EXPRESSION OPTIONS (coils and discrete inputs):
+  --expect EXPR           Evaluate EXPR over the values read, e.g.
+                          "(ESTOP && !RUNNING) || [12]" with && || ! and
+                          parentheses; [REF] reads a reference directly. With
+                          -1 a false result exits with status 2
+  --coil-names LIST       Name references for --expect, e.g.
+                          "ESTOP=1,RUNNING=2"
+
+DIAGNOSTIC OPTIONS (Modbus TCP only):
+  --exception-status      Read the exception status byte (FC07) and list
+                          its set bits
+  --diagnostic SUB[:DATA] Send diagnostics sub-function SUB (FC08) with
+                          optional DATA and decode the answer, e.g. 0x0B for
+                          the bus message count or 2 for the diagnostic
+                          register; sub-functions that restart or clear the
+                          device are refused with --read-only
+  --comm-events           Get the comm event counter (FC11): the requests the
+                          device completed successfully
+  --comm-log              Get the comm event log (FC12): counters and the
+                          last events, to tell requests the device rejected
+                          from ones that never reached it
+  --server-id             Send Report Server ID (FC17) and decode the server
+                          id and run indicator
+  --bit-names LIST        Name the device-specific status bits, e.g.
+                          "0=Overtemp,3=Low battery"
+  --scan                  Probe every unit id behind a gateway and list the
+                          ones that answer
+  --scan-units FIRST-LAST Unit ids to probe (default: 1-247)
+  --scan-probe PROBE      Request to probe with: read (holding register 0),
+                          fc17 (Report Server ID) or fc43 (Read Device
+                          Identification) (default: read)
+
+DISCOVERY OPTIONS:
+  --targets-file FILE     Probe every HOST[:PORT] or CIDR[:PORT] listed in FILE
+  --sweep CIDR[:PORT]     Probe every address in CIDR (e.g. 192.168.10.0/24:502)
+  --sweep-rate N          Maximum probes per second (default: 20)
+                          The probe reads one value of the -t type at the -r
+                          reference from the -a slave address
+
+PROXY OPTIONS:
+  --proxy ADDR            Run as a Modbus TCP proxy listening on ADDR (e.g. :1502)
+  --upstream HOST[:PORT]  Upstream device shared by all proxy clients
+
+OUTPUT OPTIONS:
+  --no-color              Don't color terminal output (changed values,
+                          --expect results and errors); NO_COLOR does the same
+  --format FORMAT         Emit read samples as text (default), as compact
+                          cbor or msgpack records, or as ndjson (one JSON
+                          object per line, for jq and log shippers)
+  --output text|json|csv  List the values read and written as text (default)
+                          or as one JSON object per value, with its address,
+                          raw registers, decoded value, timestamp and unit ID;
+                          csv writes one row per read, under a header row of
+                          addresses, for spreadsheets and pandas
+  --out FILE              Append records to FILE instead of stdout
+  --rotate SIZE|INTERVAL  Rotate the --out file once it reaches SIZE (e.g.
+                          100MB) or every INTERVAL (e.g. 1h, 1d), renaming
+                          it to FILE.1, FILE.2, ...
+  --keep N                Rotated files to keep (default: 10)
+  --nats URL              Publish every read as JSON to a NATS server
+                          (e.g. nats://host:4222)
+  --subject SUBJECT       NATS subject to publish on (default: gomodbus)
+  --sink-exec CMD         Start CMD and stream every read to its stdin as a
+                          line of JSON, for custom integrations
+  --queue-size N          Events buffered per output sink (default: 100)
+  --queue-policy POLICY   What to do when a sink falls behind: drop-oldest
+                          (default), drop-newest, or block polling
+  --cdc                   Only emit records and NATS messages whose values
+                          differ from the previous read of the unit, for
+                          change-data-capture pipelines
+  --health-listen ADDR    While polling, serve GET /healthz on ADDR (e.g.
+                          :8080): 200 while the device answers, 503 once it
+                          has not for three poll intervals
+  --pprof ADDR            While polling, serve the Go runtime profiles on
+                          ADDR under /debug/pprof/ to check memory stays flat,
+                          and goroutine, memory and connection counts as JSON
+                          under /debug/runtime
+  --report FILE           At the end of the run, write a commissioning report
+                          (configuration, requests with values and timing,
+                          --expect results) as HTML (.html) or Markdown (.md)
+  --control SOCKET        While polling, accept commands from "gomodbus ctl"
+                          on the Unix socket SOCKET: pause and resume polling,
+                          change the poll rate, write values, show the status
+  --latency-json FILE     At the end of the session, write a histogram of
+                          read latencies as JSON to FILE (- for stdout)
+  --push-gateway URL      At the end of the session, push the latency
+                          histogram to a Prometheus Pushgateway
+
 RTU OPTIONS:
   -b, --baudrate RATE     Baudrate (1200-921600, default: 19200)
   -d, --databits BITS     Databits (7 or 8, default: 8)
   -s, --stopbits BITS     Stopbits (1 or 2, default: 1)
   -P, --parity PARITY     Parity: none, even, odd (default: even)
+  --auto-baud             Try common baudrate/parity combinations against the
+                          slave address and report the first that answers
 
 OTHER OPTIONS:
   -v, --verbose           Verbose mode
+  --mask-values           Replace values with *** in printed output and
+                          logs, e.g. for screenshots shared with vendors
+  --lang LANG             Language of help and error messages: en, de or zh
+                          (default: from the locale, otherwise en)
   -h, --help              Show this help message
   -V, --version           Show version information
 
+ENVIRONMENT:
+  Every long option can also be set with a GOMODBUS_ variable: --poll-rate 500
+  is GOMODBUS_POLL_RATE=500, and switches take true or false. GOMODBUS_TARGET
+  stands in for DEVICE|HOST; write values only come from the command line.
+  The command line takes precedence.
+
 EXAMPLES:
   # Read 2 holding registers starting at address 1 from TCP device
   gomodbus -t 4 -r 1 -c 2 192.168.1.100
@@ -875,7 +2245,92 @@ EXAMPLES:
   gomodbus -m udp -t 4 -r 1 -c 2 192.168.1.100
 
   # Use Modbus TCP over TLS
-  gomodbus -m tls -t 4 -r 1 -c 2 192.168.1.100`)
+  gomodbus -m tls -t 4 -r 1 -c 2 192.168.1.100
+
+  # Share one PLC connection between many clients, read-only
+  gomodbus --proxy :1502 --upstream plc:502 --read-only`))
+}
+
+// wordsPerValue returns the number of registers that hold one value of the
+// configured data type.
+func (c *Config) wordsPerValue() int {
+	if c.Layout != nil {
+		return c.Layout.words
+	}
+	if strings.HasSuffix(c.DataType, ":int") || strings.HasSuffix(c.DataType, ":float") ||
+		strings.HasSuffix(c.DataType, ":fixed32") {
+		return 2
+	}
+	return 1
+}
+
+// writeRegisterCount returns the number of registers (or coils) the write
+// values take.
+func (c *Config) writeRegisterCount() int {
+	return len(c.WriteArgs) * c.wordsPerValue()
+}
+
+// registerCount returns the number of registers (or bits) to read. Count is
+// in typed values unless --count-unit registers is given, so -t 4:float -c 4
+// reads 8 registers.
+func (c *Config) registerCount() int {
+	if c.CountUnit == "values" {
+		return c.Count * c.wordsPerValue()
+	}
+	return c.Count
+}
+
+// resolveAutoCount sets Count for --count auto: the number of values being
+// written, or a single value when reading.
+func (c *Config) resolveAutoCount() {
+	registers := c.wordsPerValue()
+	if len(c.WriteArgs) > 0 {
+		registers = c.writeRegisterCount()
+	}
+
+	if c.CountUnit == "values" {
+		c.Count = registers / c.wordsPerValue()
+	} else {
+		c.Count = registers
+	}
+	if c.Count < 1 {
+		c.Count = 1
+	}
+}
+
+// isWriteValueList reports whether s is a comma separated list of write values.
+func isWriteValueList(s string) bool {
+	for _, part := range strings.Split(s, ",") {
+		if !isWriteValue(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// coilReadCount returns the number of bits to request for a coil read. With
+// --coil-byte-swap the read is padded to whole byte pairs so every requested
+// bit can be swapped into place.
+func (m *ModbusCLI) coilReadCount() int {
+	if !m.config.CoilSwap {
+		return m.config.Count
+	}
+	return (m.config.Count + 15) / 16 * 16
+}
+
+// unswapCoils undoes the byte pair swap of gateways that deliver coil bytes in
+// mid-endian order, returning the requested number of bits.
+func (m *ModbusCLI) unswapCoils(bits []bool) []bool {
+	if !m.config.CoilSwap || len(bits) < m.coilReadCount() {
+		return bits
+	}
+
+	swapped := make([]bool, m.config.Count)
+	for i := range swapped {
+		// Bit i lives in the other byte of its 16-bit pair
+		swapped[i] = bits[(i/8^1)*8+i%8]
+	}
+	return swapped
 }
 
 func boolToInt(b bool) int {
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgsWriteValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		host    string
+		unit    int
+		units   []int
+		values  []string
+		wantErr string
+	}{
+		{
+			name: "read",
+			args: []string{"-t", "4", "-r", "1", "192.168.1.100"},
+			host: "192.168.1.100",
+			unit: 1,
+		},
+		{
+			name:   "values after --values",
+			args:   []string{"-t", "4", "192.168.1.100", "--values", "10,-20", "30", "-1"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"10", "-20", "30", "-1"},
+		},
+		{
+			name:   "--values ends at the next option",
+			args:   []string{"--values", "10", "-t", "4", "192.168.1.100"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"10"},
+		},
+		{
+			name:   "values after --",
+			args:   []string{"-t", "4", "192.168.1.100", "--", "-1", "-2"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"-1", "-2"},
+		},
+		{
+			name:   "unit:// selects the slave",
+			args:   []string{"-m", "rtuovertcp", "-t", "4", "192.168.1.100", "unit://3", "120", "-c", "1"},
+			host:   "192.168.1.100",
+			unit:   3,
+			values: []string{"120"},
+		},
+		{
+			name:   "unit:// overrides -a",
+			args:   []string{"-a", "5", "-t", "4:float", "192.168.1.100", "unit://7", "1.5"},
+			host:   "192.168.1.100",
+			unit:   7,
+			values: []string{"1.5"},
+		},
+		{
+			name:   "values after the host",
+			args:   []string{"-t", "4", "-r", "1", "192.168.1.100", "123", "456"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"123", "456"},
+		},
+		{
+			name:   "options after the values",
+			args:   []string{"-t", "4:int", "192.168.1.100", "123456", "-789012", "-r", "1"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"123456", "-789012"},
+		},
+		{
+			name:   "negative value after the host",
+			args:   []string{"-t", "4", "192.168.1.100", "-5"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"-5"},
+		},
+		{
+			name:   "booleans after the host",
+			args:   []string{"-t", "0", "192.168.1.100", "on", "0"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"on", "0"},
+		},
+		{
+			name:   "template after the host",
+			args:   []string{"-t", "4", "192.168.1.100", "{{ now.Unix }}"},
+			host:   "192.168.1.100",
+			unit:   1,
+			values: []string{"{{ now.Unix }}"},
+		},
+		{
+			name:    "number before the host",
+			args:    []string{"-t", "4", "450", "192.168.1.100"},
+			wantErr: "unexpected argument 450",
+		},
+		{
+			name:    "negative number before the host",
+			args:    []string{"-t", "4", "-5", "192.168.1.100"},
+			wantErr: "unexpected argument -5",
+		},
+		{
+			name:    "values after the host and --values",
+			args:    []string{"-t", "4", "192.168.1.100", "1", "--values", "2"},
+			wantErr: "not both",
+		},
+		{
+			name:    "values after the host and unit://",
+			args:    []string{"-t", "4", "192.168.1.100", "1", "unit://2", "3"},
+			wantErr: "not both",
+		},
+		{
+			name:    "two hosts",
+			args:    []string{"192.168.1.100", "192.168.1.101"},
+			wantErr: "unexpected argument 192.168.1.101",
+		},
+		{
+			name:    "unit:// without values",
+			args:    []string{"192.168.1.100", "unit://3"},
+			wantErr: "missing write values after unit://3",
+		},
+		{
+			name:    "invalid unit",
+			args:    []string{"192.168.1.100", "unit://x", "1"},
+			wantErr: "invalid unit://x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			m := &ModbusCLI{}
+			config, err := m.parseArgs(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseArgs(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != tt.host || config.SlaveID != tt.unit || !reflect.DeepEqual(config.SlaveIDs, tt.units) {
+				t.Errorf("host %q, unit %d %v; want %q, unit %d %v",
+					config.Host, config.SlaveID, config.SlaveIDs, tt.host, tt.unit, tt.units)
+			}
+			if !reflect.DeepEqual(config.WriteArgs, tt.values) {
+				t.Errorf("write values %q, want %q", config.WriteArgs, tt.values)
+			}
+		})
+	}
+}
+
+func TestParseArgsEnvTarget(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(envTarget, "192.168.1.100")
+	m := &ModbusCLI{}
+	config, err := m.parseArgs([]string{"-t", "4", "--values", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "192.168.1.100" || !reflect.DeepEqual(config.WriteArgs, []string{"7"}) {
+		t.Errorf("host %q, values %q", config.Host, config.WriteArgs)
+	}
+
+	// Bare values don't name a target, so the environment still supplies it
+	config, err = m.parseArgs([]string{"-t", "4", "8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "192.168.1.100" || !reflect.DeepEqual(config.WriteArgs, []string{"8"}) {
+		t.Errorf("host %q, values %q", config.Host, config.WriteArgs)
+	}
+
+	config, err = m.parseArgs([]string{"-t", "4", "192.168.1.200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "192.168.1.200" {
+		t.Errorf("the command line target lost to %s: %q", envTarget, config.Host)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// mbapHeaderLen is the length of the MBAP header, unit id included.
+const mbapHeaderLen = 7
+
+// Modbus exception codes used when answering requests ourselves.
+const (
+	exIllegalFunction         uint8 = 0x01
+	exGWTargetFailedToRespond uint8 = 0x0b
+)
+
+// mbapFrame is a single Modbus TCP application data unit.
+type mbapFrame struct {
+	TxnID  uint16
+	UnitID uint8
+	PDU    []byte // function code followed by the request/response data
+}
+
+// readMBAPFrame reads one complete MBAP frame from r.
+func readMBAPFrame(r io.Reader) (*mbapFrame, error) {
+	var header [mbapHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	if proto := binary.BigEndian.Uint16(header[2:4]); proto != 0 {
+		return nil, fmt.Errorf("unknown protocol identifier %d", proto)
+	}
+
+	// The length field covers the unit id and the PDU
+	length := binary.BigEndian.Uint16(header[4:6])
+	if length < 2 || length > 254 {
+		return nil, fmt.Errorf("invalid MBAP length %d", length)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(r, pdu); err != nil {
+		return nil, err
+	}
+
+	return &mbapFrame{
+		TxnID:  binary.BigEndian.Uint16(header[0:2]),
+		UnitID: header[6],
+		PDU:    pdu,
+	}, nil
+}
+
+// Bytes encodes the frame for the wire.
+func (f *mbapFrame) Bytes() []byte {
+	buf := make([]byte, mbapHeaderLen+len(f.PDU))
+	binary.BigEndian.PutUint16(buf[0:2], f.TxnID)
+	binary.BigEndian.PutUint16(buf[4:6], uint16(len(f.PDU)+1))
+	buf[6] = f.UnitID
+	copy(buf[mbapHeaderLen:], f.PDU)
+	return buf
+}
+
+// FunctionCode returns the function code of the frame, or 0 for an empty PDU.
+func (f *mbapFrame) FunctionCode() uint8 {
+	if len(f.PDU) == 0 {
+		return 0
+	}
+	return f.PDU[0]
+}
+
+// exceptionResponse builds the exception response to req with the given code.
+func exceptionResponse(req *mbapFrame, code uint8) *mbapFrame {
+	return &mbapFrame{
+		TxnID:  req.TxnID,
+		UnitID: req.UnitID,
+		PDU:    []byte{req.FunctionCode() | 0x80, code},
+	}
+}
+
+// functionCodeNames describes the function codes gomodbus knows about.
+var functionCodeNames = map[uint8]string{
+	0x01: "read coils",
+	0x02: "read discrete inputs",
+	0x03: "read holding registers",
+	0x04: "read input registers",
+	0x05: "write single coil",
+	0x06: "write single register",
+	0x07: "read exception status",
+	0x08: "diagnostics",
+	0x0b: "get comm event counter",
+	0x0c: "get comm event log",
+	0x0f: "write multiple coils",
+	0x10: "write multiple registers",
+	0x11: "report server id",
+	0x14: "read file record",
+	0x15: "write file record",
+	0x16: "mask write register",
+	0x17: "read/write multiple registers",
+	0x18: "read fifo queue",
+	0x2b: "encapsulated interface transport",
+}
+
+// isReadRequest reports whether a request PDU only reads from the device:
+// the read functions, Read Device Identification and the diagnostics
+// sub-functions that return data without restarting or clearing anything.
+// Anything else, vendor and unknown function codes included, may change the
+// device and is treated as a write.
+func isReadRequest(pdu []byte) bool {
+	if len(pdu) == 0 {
+		return false
+	}
+	switch pdu[0] {
+	case 0x01, 0x02, 0x03, 0x04, 0x07, 0x0b, 0x0c, 0x11, 0x14, 0x18:
+		return true
+	case 0x08:
+		if len(pdu) < 3 {
+			return false
+		}
+		sub := binary.BigEndian.Uint16(pdu[1:3])
+		_, known := diagSubFunctions[sub]
+		return known && !diagChangesState(sub)
+	case 0x2b:
+		return len(pdu) >= 2 && pdu[1] == meiReadDeviceID
+	}
+	return false
+}
+
+// describePDU renders a request PDU as a short human readable summary. With
+// mask, written values are replaced by the mask placeholder.
+func describePDU(pdu []byte, mask bool) string {
+	if len(pdu) == 0 {
+		return "empty request"
+	}
+
+	fc := pdu[0]
+	name, ok := functionCodeNames[fc&0x7f]
+	if !ok {
+		name = "unknown function"
+	}
+	desc := fmt.Sprintf("fc=0x%02X (%s)", fc, name)
+
+	if len(pdu) >= 5 {
+		addr := binary.BigEndian.Uint16(pdu[1:3])
+		arg := binary.BigEndian.Uint16(pdu[3:5])
+		switch fc {
+		case 0x01, 0x02, 0x03, 0x04, 0x0f, 0x10:
+			desc += fmt.Sprintf(" addr=%d qty=%d", addr, arg)
+		case 0x05, 0x06:
+			if mask {
+				desc += fmt.Sprintf(" addr=%d value=%s", addr, maskPlaceholder)
+			} else {
+				desc += fmt.Sprintf(" addr=%d value=0x%04X", addr, arg)
+			}
+		}
+	}
+
+	return desc
+}
+
+// exceptionErrors maps exception codes to the errors the modbus library
+// returns for them, so raw requests are classified like library ones.
+var exceptionErrors = map[uint8]modbus.Error{
+	0x01: modbus.ErrIllegalFunction,
+	0x02: modbus.ErrIllegalDataAddress,
+	0x03: modbus.ErrIllegalDataValue,
+	0x04: modbus.ErrServerDeviceFailure,
+	0x05: modbus.ErrAcknowledge,
+	0x06: modbus.ErrServerDeviceBusy,
+	0x08: modbus.ErrMemoryParityError,
+	0x0a: modbus.ErrGWPathUnavailable,
+	0x0b: modbus.ErrGWTargetFailedToRespond,
+}
+
+// rawRequest sends a request PDU to the configured Modbus TCP target over a
+// fresh connection and returns the response PDU. The modbus library only
+// implements the data access functions, so this carries everything else.
+func (m *ModbusCLI) rawRequest(pdu []byte) ([]byte, error) {
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	conn, err := net.DialTimeout("tcp", address, m.config.dialTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	return rawExchange(conn, 1, uint8(m.config.SlaveID), pdu, m.config.Timeout)
+}
+
+// rawExchange sends one request PDU to unitID over conn and returns the
+// response PDU, translating exception responses into the library's errors.
+func rawExchange(conn net.Conn, txnID uint16, unitID uint8, pdu []byte, timeout time.Duration) ([]byte, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := &mbapFrame{TxnID: txnID, UnitID: unitID, PDU: pdu}
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	res, err := readMBAPFrame(conn)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, modbus.ErrRequestTimedOut
+		}
+		return nil, err
+	}
+	switch {
+	case res.TxnID != req.TxnID:
+		return nil, modbus.ErrBadTransactionId
+	case res.UnitID != req.UnitID:
+		return nil, modbus.ErrBadUnitId
+	case res.FunctionCode() == pdu[0]|0x80 && len(res.PDU) == 2:
+		if mbErr, ok := exceptionErrors[res.PDU[1]]; ok {
+			return nil, mbErr
+		}
+		return nil, fmt.Errorf("unknown exception code (%d)", res.PDU[1])
+	case res.FunctionCode() != pdu[0]:
+		return nil, modbus.ErrProtocolError
+	}
+
+	return res.PDU, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestMBAPFrame(t *testing.T) {
+	frame := &mbapFrame{TxnID: 0x1234, UnitID: 7, PDU: []byte{0x03, 0x00, 0x64, 0x00, 0x02}}
+	wire := []byte{0x12, 0x34, 0, 0, 0, 6, 7, 0x03, 0x00, 0x64, 0x00, 0x02}
+	if got := frame.Bytes(); !bytes.Equal(got, wire) {
+		t.Errorf("Bytes() = % x, want % x", got, wire)
+	}
+
+	// Two frames back to back are read one at a time
+	r := bytes.NewReader(append(wire, wire...))
+	for i := 0; i < 2; i++ {
+		got, err := readMBAPFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, frame) {
+			t.Errorf("frame %d = %+v, want %+v", i, got, frame)
+		}
+	}
+	if _, err := readMBAPFrame(r); err != io.EOF {
+		t.Errorf("read past the last frame: %v", err)
+	}
+
+	if fc := (&mbapFrame{}).FunctionCode(); fc != 0 {
+		t.Errorf("function code of an empty PDU = %d", fc)
+	}
+	ex := exceptionResponse(frame, exIllegalFunction)
+	if want := (&mbapFrame{TxnID: 0x1234, UnitID: 7, PDU: []byte{0x83, 0x01}}); !reflect.DeepEqual(ex, want) {
+		t.Errorf("exception response = %+v, want %+v", ex, want)
+	}
+}
+
+func TestReadMBAPFrameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		wire []byte
+		want string
+	}{
+		{"short header", []byte{0, 1, 0, 0, 0}, "unexpected EOF"},
+		{"protocol", []byte{0, 1, 0, 1, 0, 2, 1, 3}, "unknown protocol identifier 1"},
+		{"length too small", []byte{0, 1, 0, 0, 0, 1, 1}, "invalid MBAP length 1"},
+		{"length too large", []byte{0, 1, 0, 0, 0x01, 0x00, 1}, "invalid MBAP length 256"},
+		{"short PDU", []byte{0, 1, 0, 0, 0, 6, 1, 3, 0}, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMBAPFrame(bytes.NewReader(tt.wire))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribePDU(t *testing.T) {
+	tests := []struct {
+		pdu  []byte
+		mask bool
+		want string
+	}{
+		{nil, false, "empty request"},
+		{[]byte{0x03, 0x00, 0x64, 0x00, 0x0a}, false, "fc=0x03 (read holding registers) addr=100 qty=10"},
+		{[]byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0, 1, 0, 2}, true, "fc=0x10 (write multiple registers) addr=1 qty=2"},
+		{[]byte{0x06, 0x00, 0x05, 0x12, 0x34}, false, "fc=0x06 (write single register) addr=5 value=0x1234"},
+		{[]byte{0x05, 0x00, 0x05, 0xff, 0x00}, true, "fc=0x05 (write single coil) addr=5 value=" + maskPlaceholder},
+		{[]byte{0x83, 0x02}, false, "fc=0x83 (read holding registers)"},
+		{[]byte{0x07}, false, "fc=0x07 (read exception status)"},
+		{[]byte{0x64, 0, 0, 0, 0}, false, "fc=0x64 (unknown function)"},
+	}
+	for _, tt := range tests {
+		if got := describePDU(tt.pdu, tt.mask); got != tt.want {
+			t.Errorf("describePDU(% x, %v) = %q, want %q", tt.pdu, tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestIsReadRequest(t *testing.T) {
+	tests := []struct {
+		pdu  []byte
+		want bool
+	}{
+		{nil, false},
+		{[]byte{0x01, 0, 0, 0, 8}, true},
+		{[]byte{0x02, 0, 0, 0, 8}, true},
+		{[]byte{0x03, 0, 0, 0, 1}, true},
+		{[]byte{0x04, 0, 0, 0, 1}, true},
+		{[]byte{0x07}, true},
+		{[]byte{0x0b}, true},
+		{[]byte{0x0c}, true},
+		{[]byte{0x11}, true},
+		{[]byte{0x14, 7}, true},
+		{[]byte{0x18, 0, 1}, true},
+		{[]byte{0x2b, 0x0e, 1, 0}, true},
+		{[]byte{0x2b, 0x0d}, false},
+		{[]byte{0x2b}, false},
+		{[]byte{0x05, 0, 1, 0xff, 0}, false},
+		{[]byte{0x06, 0, 1, 0, 1}, false},
+		{[]byte{0x0f, 0, 1, 0, 1, 1, 1}, false},
+		{[]byte{0x10, 0, 1, 0, 1, 2, 0, 1}, false},
+		{[]byte{0x15}, false},
+		{[]byte{0x16, 0, 1, 0, 0, 0, 0}, false},
+		{[]byte{0x17}, false},
+		// Diagnostics: only the sub-functions that return data
+		{[]byte{0x08, 0, 0x00, 0x12, 0x34}, true},
+		{[]byte{0x08, 0, 0x02, 0, 0}, true},
+		{[]byte{0x08, 0, 0x0b, 0, 0}, true},
+		{[]byte{0x08, 0, 0x01, 0, 0}, false},
+		{[]byte{0x08, 0, 0x04, 0, 0}, false},
+		{[]byte{0x08, 0, 0x0a, 0, 0}, false},
+		{[]byte{0x08, 0, 0x14, 0, 0}, false},
+		{[]byte{0x08, 0, 0x42, 0, 0}, false},
+		{[]byte{0x08, 0}, false},
+		// Vendor and unknown function codes
+		{[]byte{0x41, 0, 0}, false},
+		{[]byte{0x64}, false},
+	}
+	for _, tt := range tests {
+		if got := isReadRequest(tt.pdu); got != tt.want {
+			t.Errorf("isReadRequest(% x) = %v, want %v", tt.pdu, got, tt.want)
+		}
+	}
+}
+
+func TestRawExchange(t *testing.T) {
+	request := []byte{0x07}
+	tests := []struct {
+		name    string
+		reply   func(req *mbapFrame) []byte
+		want    []byte
+		wantErr error
+	}{
+		{"response", func(req *mbapFrame) []byte {
+			return (&mbapFrame{TxnID: req.TxnID, UnitID: req.UnitID, PDU: []byte{0x07, 0x5a}}).Bytes()
+		}, []byte{0x07, 0x5a}, nil},
+		{"exception", func(req *mbapFrame) []byte {
+			return exceptionResponse(req, 0x02).Bytes()
+		}, nil, modbus.ErrIllegalDataAddress},
+		{"transaction id", func(req *mbapFrame) []byte {
+			return (&mbapFrame{TxnID: req.TxnID + 1, UnitID: req.UnitID, PDU: []byte{0x07, 0}}).Bytes()
+		}, nil, modbus.ErrBadTransactionId},
+		{"unit id", func(req *mbapFrame) []byte {
+			return (&mbapFrame{TxnID: req.TxnID, UnitID: req.UnitID + 1, PDU: []byte{0x07, 0}}).Bytes()
+		}, nil, modbus.ErrBadUnitId},
+		{"function code", func(req *mbapFrame) []byte {
+			return (&mbapFrame{TxnID: req.TxnID, UnitID: req.UnitID, PDU: []byte{0x08, 0}}).Bytes()
+		}, nil, modbus.ErrProtocolError},
+		{"timeout", func(req *mbapFrame) []byte { return nil }, nil, modbus.ErrRequestTimedOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				req, err := readMBAPFrame(server)
+				if err != nil {
+					return
+				}
+				if reply := tt.reply(req); reply != nil {
+					server.Write(reply)
+				} else {
+					io.Copy(io.Discard, server)
+				}
+			}()
+
+			got, err := rawExchange(client, 9, 3, request, 100*time.Millisecond)
+			if err != tt.wantErr || !bytes.Equal(got, tt.want) {
+				t.Errorf("rawExchange = % x, %v; want % x, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// Unknown exception codes are reported as such
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		if req, err := readMBAPFrame(server); err == nil {
+			server.Write(exceptionResponse(req, 0x42).Bytes())
+		}
+	}()
+	if _, err := rawExchange(client, 1, 1, request, time.Second); err == nil || err.Error() != "unknown exception code (66)" {
+		t.Errorf("unknown exception: %v", err)
+	}
+}
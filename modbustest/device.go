@@ -0,0 +1,127 @@
+package modbustest
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Device is a Modbus device held in memory, for use as the handler of
+// Start and Connect. It answers every unit ID, and requests beyond the end
+// of a table fail with an illegal data address exception. Its methods may
+// be called while clients access it.
+type Device struct {
+	mu       sync.Mutex
+	coils    []bool
+	discrete []bool
+	input    []uint16
+	holding  []uint16
+}
+
+// NewDevice returns a device whose four tables hold size values each, all
+// zero.
+func NewDevice(size int) *Device {
+	return &Device{
+		coils:    make([]bool, size),
+		discrete: make([]bool, size),
+		input:    make([]uint16, size),
+		holding:  make([]uint16, size),
+	}
+}
+
+// SetCoils sets the coils from addr on. Like the other setters and
+// getters, it panics when the range doesn't fit the table.
+func (d *Device) SetCoils(addr uint16, values ...bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.coils[addr:int(addr)+len(values)], values)
+}
+
+// SetDiscreteInputs sets the discrete inputs from addr on.
+func (d *Device) SetDiscreteInputs(addr uint16, values ...bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.discrete[addr:int(addr)+len(values)], values)
+}
+
+// SetInputRegisters sets the input registers from addr on.
+func (d *Device) SetInputRegisters(addr uint16, values ...uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.input[addr:int(addr)+len(values)], values)
+}
+
+// SetHoldingRegisters sets the holding registers from addr on.
+func (d *Device) SetHoldingRegisters(addr uint16, values ...uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.holding[addr:int(addr)+len(values)], values)
+}
+
+// Coils returns quantity coils from addr on, e.g. to check what a client
+// wrote.
+func (d *Device) Coils(addr, quantity uint16) []bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.coils[addr : int(addr)+int(quantity)])
+}
+
+// HoldingRegisters returns quantity holding registers from addr on.
+func (d *Device) HoldingRegisters(addr, quantity uint16) []uint16 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.holding[addr : int(addr)+int(quantity)])
+}
+
+// inRange reports whether a request of quantity values from addr fits a
+// table of size values.
+func inRange(addr, quantity uint16, size int) bool {
+	return int(addr)+int(quantity) <= size
+}
+
+// HandleCoils serves coil reads and writes.
+func (d *Device) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !inRange(req.Addr, req.Quantity, len(d.coils)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	if req.IsWrite {
+		copy(d.coils[req.Addr:], req.Args)
+	}
+	return slices.Clone(d.coils[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+// HandleDiscreteInputs serves discrete input reads.
+func (d *Device) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !inRange(req.Addr, req.Quantity, len(d.discrete)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return slices.Clone(d.discrete[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+// HandleHoldingRegisters serves holding register reads and writes.
+func (d *Device) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !inRange(req.Addr, req.Quantity, len(d.holding)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	if req.IsWrite {
+		copy(d.holding[req.Addr:], req.Args)
+	}
+	return slices.Clone(d.holding[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+// HandleInputRegisters serves input register reads.
+func (d *Device) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !inRange(req.Addr, req.Quantity, len(d.input)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return slices.Clone(d.input[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
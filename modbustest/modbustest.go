@@ -0,0 +1,61 @@
+// Package modbustest runs Modbus servers in-process for integration tests,
+// so code that talks Modbus can be tested without a device or serial line.
+//
+// The modbus library's clients only dial URLs, so a server and its client
+// talk over a loopback TCP port rather than an in-memory pipe; nothing
+// leaves the host.
+package modbustest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Start serves handler on a free loopback port until the test ends and
+// returns the server's address as host:port.
+func Start(t testing.TB, handler modbus.RequestHandler) string {
+	t.Helper()
+	// The server takes a URL rather than a listener, so the port is found
+	// by listening on port 0 and handed over once it's free again
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        "tcp://" + addr,
+		Timeout:    time.Minute,
+		MaxClients: 8,
+	}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return addr
+}
+
+// Connect starts a server for handler and returns a client connected to
+// it, which is closed when the test ends.
+func Connect(t testing.TB, handler modbus.RequestHandler) *modbus.ModbusClient {
+	t.Helper()
+	client, err := modbus.NewClient(&modbus.ClientConfiguration{
+		URL:     "tcp://" + Start(t, handler),
+		Timeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -0,0 +1,76 @@
+package modbustest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+func TestConnectReadWrite(t *testing.T) {
+	device := NewDevice(16)
+	device.SetInputRegisters(2, 215, 216)
+	device.SetDiscreteInputs(0, true, false, true)
+	client := Connect(t, device)
+
+	if got, err := client.ReadRegisters(2, 2, modbus.INPUT_REGISTER); err != nil || !reflect.DeepEqual(got, []uint16{215, 216}) {
+		t.Errorf("input registers = %v, %v", got, err)
+	}
+	if got, err := client.ReadDiscreteInputs(0, 3); err != nil || !reflect.DeepEqual(got, []bool{true, false, true}) {
+		t.Errorf("discrete inputs = %v, %v", got, err)
+	}
+
+	if err := client.WriteRegisters(10, []uint16{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := device.HoldingRegisters(10, 3); !reflect.DeepEqual(got, []uint16{1, 2, 3}) {
+		t.Errorf("holding registers after the write = %v", got)
+	}
+	if err := client.WriteCoil(5, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := device.Coils(4, 3); !reflect.DeepEqual(got, []bool{false, true, false}) {
+		t.Errorf("coils after the write = %v", got)
+	}
+
+	device.SetHoldingRegisters(0, 42)
+	device.SetCoils(15, true)
+	if got, err := client.ReadRegister(0, modbus.HOLDING_REGISTER); err != nil || got != 42 {
+		t.Errorf("holding register 0 = %d, %v", got, err)
+	}
+	if got, err := client.ReadCoil(15); err != nil || !got {
+		t.Errorf("coil 15 = %v, %v", got, err)
+	}
+}
+
+func TestConnectIllegalAddress(t *testing.T) {
+	client := Connect(t, NewDevice(16))
+	if _, err := client.ReadRegisters(10, 7, modbus.HOLDING_REGISTER); err != modbus.ErrIllegalDataAddress {
+		t.Errorf("read past the end of the table: %v", err)
+	}
+	if err := client.WriteCoils(15, []bool{true, true}); err != modbus.ErrIllegalDataAddress {
+		t.Errorf("write past the end of the table: %v", err)
+	}
+}
+
+func TestStartSeveralClients(t *testing.T) {
+	device := NewDevice(4)
+	addr := Start(t, device)
+	for i := uint16(0); i < 3; i++ {
+		client, err := modbus.NewClient(&modbus.ClientConfiguration{URL: "tcp://" + addr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		client.SetUnitId(uint8(i + 1))
+		if err := client.WriteRegister(i, i+100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := device.HoldingRegisters(0, 3); !reflect.DeepEqual(got, []uint16{100, 101, 102}) {
+		t.Errorf("holding registers = %v", got)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// msgpackExtTimestamp is the MessagePack extension type for timestamps.
+const msgpackExtTimestamp = -1
+
+// encodeMsgPack encodes a sample as a single MessagePack object.
+func encodeMsgPack(sample *pollSample) ([]byte, error) {
+	w := &msgpackWriter{}
+	if err := encodeBinary(w, reflect.ValueOf(sample)); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type msgpackWriter struct {
+	buf []byte
+}
+
+func (w *msgpackWriter) Nil() {
+	w.buf = append(w.buf, 0xc0)
+}
+
+func (w *msgpackWriter) Bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 0xc3)
+	} else {
+		w.buf = append(w.buf, 0xc2)
+	}
+}
+
+func (w *msgpackWriter) Int(v int64) {
+	switch {
+	case v >= 0:
+		w.Uint(uint64(v))
+	case v >= -32:
+		w.buf = append(w.buf, byte(int8(v)))
+	case v >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(int8(v)))
+	case v >= math.MinInt16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xd2), uint32(v))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xd3), uint64(v))
+	}
+}
+
+func (w *msgpackWriter) Uint(v uint64) {
+	switch {
+	case v < 0x80:
+		w.buf = append(w.buf, byte(v))
+	case v <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xce), uint32(v))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xcf), v)
+	}
+}
+
+func (w *msgpackWriter) Float32(v float32) {
+	w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xca), math.Float32bits(v))
+}
+
+func (w *msgpackWriter) Float64(v float64) {
+	w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xcb), math.Float64bits(v))
+}
+
+func (w *msgpackWriter) String(v string) {
+	n := len(v)
+	switch {
+	case n < 32:
+		w.buf = append(w.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xda), uint16(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xdb), uint32(n))
+	}
+	w.buf = append(w.buf, v...)
+}
+
+// Time uses the 96-bit timestamp extension: nanoseconds then seconds.
+func (w *msgpackWriter) Time(v time.Time) {
+	w.buf = append(w.buf, 0xc7, 12, 0xff) // ext type -1
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(v.Nanosecond()))
+	w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(v.Unix()))
+}
+
+func (w *msgpackWriter) ArrayHeader(n int) {
+	switch {
+	case n < 16:
+		w.buf = append(w.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xdc), uint16(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xdd), uint32(n))
+	}
+}
+
+func (w *msgpackWriter) MapHeader(n int) {
+	switch {
+	case n < 16:
+		w.buf = append(w.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xde), uint16(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xdf), uint32(n))
+	}
+}
+
+// decodeMsgPack reads the next MessagePack object from r. Maps decode to
+// orderedMap and timestamp extensions to time.Time.
+func decodeMsgPack(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return uint64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return decodeMsgPackMap(r, uint64(b&0x0f))
+	case b&0xf0 == 0x90:
+		return decodeMsgPackArray(r, uint64(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return decodeMsgPackString(r, uint64(b&0x1f))
+	}
+
+	// Sized types carry their length or value in the bytes that follow
+	sized := func(size int) (uint64, error) {
+		n, err := readUint(r, size)
+		return n, unexpectedEOF(err)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return sized(1 << (b - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := sized(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xca:
+		n, err := sized(4)
+		return math.Float32frombits(uint32(n)), err
+	case 0xcb:
+		n, err := sized(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := sized(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackString(r, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := sized(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readData(r, n)
+	case 0xdc, 0xdd:
+		n, err := sized(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := sized(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackMap(r, n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decodeMsgPackExt(r, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := sized(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackExt(r, n)
+	}
+
+	return nil, fmt.Errorf("unsupported MessagePack type byte 0x%02x", b)
+}
+
+func decodeMsgPackString(r *bufio.Reader, n uint64) (interface{}, error) {
+	data, err := readData(r, n)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func decodeMsgPackArray(r *bufio.Reader, n uint64) (interface{}, error) {
+	if err := checkLength(n); err != nil {
+		return nil, err
+	}
+	items := []interface{}{}
+	for i := uint64(0); i < n; i++ {
+		item, err := decodeMsgPack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func decodeMsgPackMap(r *bufio.Reader, n uint64) (interface{}, error) {
+	if err := checkLength(n); err != nil {
+		return nil, err
+	}
+	entries := orderedMap{}
+	for i := uint64(0); i < n; i++ {
+		key, err := decodeMsgPack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		value, err := decodeMsgPack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		entries = append(entries, mapEntry{Key: fmt.Sprint(key), Value: value})
+	}
+	return entries, nil
+}
+
+// decodeMsgPackExt decodes an extension of n data bytes. Timestamps become
+// time.Time; other extensions are returned as raw bytes.
+func decodeMsgPackExt(r *bufio.Reader, n uint64) (interface{}, error) {
+	extType, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	data, err := readData(r, n)
+	if err != nil {
+		return nil, err
+	}
+
+	if int8(extType) != msgpackExtTimestamp {
+		return data, nil
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)), nil
+	}
+	return nil, fmt.Errorf("invalid MessagePack timestamp length %d", n)
+}
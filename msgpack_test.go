@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	data, err := encodeMsgPack(testSample())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := decodeAll(t, decodeMsgPack, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := orderedMap{
+		{Key: "timestamp", Value: time.Unix(1714641243, 0)},
+		{Key: "source", Value: "192.168.1.100:502"},
+		{Key: "unit_id", Value: uint64(1)},
+		{Key: "label", Value: "boiler1"},
+		{Key: "data_type", Value: "4"},
+		{Key: "start", Value: uint64(100)},
+		{Key: "values", Value: []interface{}{uint64(7), uint64(65535)}},
+		{Key: "hash", Value: "7c1262ec0cb5ced5"},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("decoded %#v, want %#v", v, want)
+	}
+}
+
+func TestDecodeMsgPackItems(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"fixint", []byte{0x64}, uint64(100)},
+		{"negative fixint", []byte{0xff}, int64(-1)},
+		{"int16", []byte{0xd1, 0xff, 0x9c}, int64(-100)},
+		{"uint32", []byte{0xce, 0x00, 0x01, 0x00, 0x00}, uint64(65536)},
+		{"fixstr", []byte{0xa3, 'a', 'b', 'c'}, "abc"},
+		{"str8", []byte{0xd9, 0x01, 'x'}, "x"},
+		{"empty bin", []byte{0xc4, 0x00}, []byte{}},
+		{"empty array", []byte{0x90}, []interface{}{}},
+		{"empty map", []byte{0x80}, orderedMap{}},
+		{"float32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, float32(1.5)},
+		{"timestamp32", []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x3c}, time.Unix(60, 0)},
+		{"nil", []byte{0xc0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeAll(t, decodeMsgPack, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("decoded %#v, want %#v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMsgPackMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated uint16", []byte{0xcd, 0x01}},
+		{"truncated fixstr", []byte{0xa5, 'a', 'b'}},
+		{"truncated array", []byte{0x93, 0x01, 0x02}},
+		{"truncated map value", []byte{0x81, 0xa1, 'k'}},
+		{"truncated ext", []byte{0xd7, 0xff, 0x00}},
+		{"huge array", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{"huge map", []byte{0xdf, 0xff, 0xff, 0xff, 0xff}},
+		{"huge str", []byte{0xdb, 0xff, 0xff, 0xff, 0xff}},
+		{"huge bin", []byte{0xc6, 0xff, 0xff, 0xff, 0xff}},
+		{"huge ext", []byte{0xc9, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"str past input", []byte{0xdb, 0x00, 0xff, 0xff, 0xff, 'a'}},
+		{"array past input", []byte{0xdd, 0x00, 0xff, 0xff, 0xff, 0x01}},
+		{"bad timestamp length", []byte{0xc7, 0x02, 0xff, 0x00, 0x00}},
+		{"unsupported type", []byte{0xc1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeAll(t, decodeMsgPack, tt.data)
+			if err == nil {
+				t.Fatalf("decoded %#v, want an error", v)
+			}
+			if errors.Is(err, io.EOF) {
+				t.Errorf("got a clean EOF in the middle of an object")
+			}
+		})
+	}
+}
+
+func FuzzDecodeMsgPack(f *testing.F) {
+	sample, _ := encodeMsgPack(testSample())
+	f.Add(sample)
+	f.Add([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0xdb, 0x00, 0xff, 0xff, 0xff, 'a'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeMsgPack(bufio.NewReader(bytes.NewReader(data)))
+	})
+}
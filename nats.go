@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsPublisher is a minimal publish-only client for the NATS text protocol.
+type natsPublisher struct {
+	url     string
+	subject string
+	timeout time.Duration
+
+	mu   sync.Mutex // guards conn and writes to it
+	conn net.Conn
+}
+
+func newNATSPublisher(rawURL, subject string, timeout time.Duration) *natsPublisher {
+	return &natsPublisher{url: rawURL, subject: subject, timeout: timeout}
+}
+
+// Publish sends payload on subject, connecting first if needed.
+func (n *natsPublisher) Publish(subject string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connectLocked(); err != nil {
+			return fmt.Errorf("failed to connect to NATS: %v", err)
+		}
+	}
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+	if _, err := n.conn.Write([]byte(msg)); err != nil {
+		n.conn.Close()
+		n.conn = nil
+		return fmt.Errorf("failed to publish to NATS: %v", err)
+	}
+
+	return nil
+}
+
+// Name identifies the sink in warnings and drop reports.
+func (n *natsPublisher) Name() string {
+	return "nats"
+}
+
+// Send publishes a sample as a JSON message on the configured subject, and
+// a state change on SUBJECT.comm or SUBJECT.alarm.
+func (n *natsPublisher) Send(ev busEvent) error {
+	if ev.state != nil {
+		payload, err := json.Marshal(ev.state)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %v", err)
+		}
+		return n.Publish(n.subject+"."+ev.state.Event, payload)
+	}
+	payload, err := json.Marshal(ev.sample)
+	if err != nil {
+		return fmt.Errorf("failed to encode sample: %v", err)
+	}
+	return n.Publish(n.subject, payload)
+}
+
+// Close closes the connection to the server, if any.
+func (n *natsPublisher) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+}
+
+func (n *natsPublisher) connectLocked() error {
+	u, err := url.Parse(n.url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "nats" {
+		return fmt.Errorf("unsupported URL scheme %q (expected nats://)", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, n.timeout)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+
+	// The server greets us with INFO before anything else
+	conn.SetDeadline(time.Now().Add(n.timeout))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(line[len("INFO "):]), &info)
+	if info.TLSRequired {
+		conn.Close()
+		return fmt.Errorf("server requires TLS, which is not supported")
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "gomodbus",
+		"lang":     "go",
+		"version":  version,
+	}
+	if u.User != nil {
+		connect["user"] = u.User.Username()
+		if pass, ok := u.User.Password(); ok {
+			connect["pass"] = pass
+		}
+	}
+	options, _ := json.Marshal(connect)
+
+	// PING/PONG confirms the server accepted CONNECT
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", options); err != nil {
+		conn.Close()
+		return err
+	}
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if line = strings.TrimSpace(line); line != "PONG" {
+		conn.Close()
+		return fmt.Errorf("server refused connection: %s", line)
+	}
+	conn.SetDeadline(time.Time{})
+
+	n.conn = conn
+	go n.serve(conn, reader)
+
+	return nil
+}
+
+// serve answers server keep-alive PINGs until the connection goes away.
+func (n *natsPublisher) serve(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			n.mu.Lock()
+			if n.conn == conn {
+				n.conn.Close()
+				n.conn = nil
+			}
+			n.mu.Unlock()
+			return
+		}
+
+		if strings.TrimSpace(line) == "PING" {
+			n.mu.Lock()
+			if n.conn == conn {
+				conn.Write([]byte("PONG\r\n"))
+			}
+			n.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+// optionKind says what follows an option on the command line.
+type optionKind int
+
+const (
+	optionSwitch optionKind = iota // nothing; the option turns something on
+	optionValue                    // one value
+	optionList                     // write values up to the next option
+	optionExit                     // nothing; prints and exits
+)
+
+// options lists every option parseArgs accepts, in the order it handles
+// them. Anything else starting with a dash is an unknown option, and the
+// GOMODBUS_* environment variables are the long names listed here.
+var options = map[string]optionKind{
+	"-m":                 optionValue,
+	"--mode":             optionValue,
+	"-a":                 optionValue,
+	"--address":          optionValue,
+	"--label":            optionValue,
+	"-r":                 optionValue,
+	"--reference":        optionValue,
+	"-c":                 optionValue,
+	"--count":            optionValue,
+	"--count-unit":       optionValue,
+	"-t":                 optionValue,
+	"--type":             optionValue,
+	"-0":                 optionSwitch,
+	"--zero-based":       optionSwitch,
+	"-B":                 optionSwitch,
+	"--big-endian":       optionSwitch,
+	"-1":                 optionSwitch,
+	"--once":             optionSwitch,
+	"-l":                 optionValue,
+	"--poll-rate":        optionValue,
+	"-o":                 optionValue,
+	"--timeout":          optionValue,
+	"--connect-timeout":  optionValue,
+	"--busy-patience":    optionValue,
+	"--lock":             optionValue,
+	"--lock-token":       optionValue,
+	"--lock-free":        optionValue,
+	"--lock-wait":        optionValue,
+	"--sync-register":    optionValue,
+	"--sync-wait":        optionValue,
+	"-p":                 optionValue,
+	"--port":             optionValue,
+	"-b":                 optionValue,
+	"--baudrate":         optionValue,
+	"-d":                 optionValue,
+	"--databits":         optionValue,
+	"-s":                 optionValue,
+	"--stopbits":         optionValue,
+	"-P":                 optionValue,
+	"--parity":           optionValue,
+	"--coil-byte-swap":   optionSwitch,
+	"--read-twice":       optionSwitch,
+	"--fail-fast":        optionSwitch,
+	"--vote":             optionValue,
+	"--proxy":            optionValue,
+	"--upstream":         optionValue,
+	"--read-only":        optionSwitch,
+	"--no-color":         optionSwitch,
+	"--treat-as-holding": optionSwitch,
+	"--nats":             optionValue,
+	"--subject":          optionValue,
+	"--sink-exec":        optionValue,
+	"--format":           optionValue,
+	"--output":           optionValue,
+	"--out":              optionValue,
+	"--rotate":           optionValue,
+	"--keep":             optionValue,
+	"--queue-size":       optionValue,
+	"--queue-policy":     optionValue,
+	"--cdc":              optionSwitch,
+	"--decoder":          optionValue,
+	"--health-listen":    optionValue,
+	"--pprof":            optionValue,
+	"--exception-status": optionSwitch,
+	"--diagnostic":       optionValue,
+	"--comm-events":      optionSwitch,
+	"--comm-log":         optionSwitch,
+	"--server-id":        optionSwitch,
+	"--scan":             optionSwitch,
+	"--scan-units":       optionValue,
+	"--scan-probe":       optionValue,
+	"--bit-names":        optionValue,
+	"--expect":           optionValue,
+	"--coil-names":       optionValue,
+	"--layout":           optionValue,
+	"--report":           optionValue,
+	"--control":          optionValue,
+	"--latency-json":     optionValue,
+	"--push-gateway":     optionValue,
+	"--write-loop":       optionValue,
+	"--repeat":           optionSwitch,
+	"--rollback":         optionSwitch,
+	"--atomic":           optionSwitch,
+	"--single":           optionSwitch,
+	"--fanout":           optionSwitch,
+	"--at":               optionValue,
+	"--in":               optionValue,
+	"--replay":           optionValue,
+	"--from":             optionValue,
+	"--sheet":            optionValue,
+	"--replay-speed":     optionValue,
+	"--journal":          optionValue,
+	"--session":          optionValue,
+	"--last":             optionSwitch,
+	"--junit":            optionValue,
+	"--tap":              optionValue,
+	"--write-window":     optionValue,
+	"--targets-file":     optionValue,
+	"--sweep":            optionValue,
+	"--sweep-rate":       optionValue,
+	"--auto-timeout":     optionSwitch,
+	"--warmup":           optionValue,
+	"--mask-values":      optionSwitch,
+	"--truncate":         optionSwitch,
+	"--auto-baud":        optionSwitch,
+	"-v":                 optionSwitch,
+	"--verbose":          optionSwitch,
+	"--lang":             optionValue,
+	"-h":                 optionExit,
+	"--help":             optionExit,
+	"-V":                 optionExit,
+	"--version":          optionExit,
+	"--values":           optionList,
+}
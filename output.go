@@ -0,0 +1,41 @@
+package main
+
+// valueFormatter lists the values of a read, in the form --output selects.
+type valueFormatter interface {
+	formatBits(startRef int, bits []bool, regType string)
+	formatRegisters(startRef int, registers []uint16, regType string) error
+}
+
+func (m *ModbusCLI) newValueFormatter() valueFormatter {
+	switch m.config.Output {
+	case "json":
+		return jsonFormatter{m}
+	case "csv":
+		return newCSVFormatter(m)
+	}
+	return textFormatter{m}
+}
+
+// textFormatter prints the text listing, a header line and one line per
+// value.
+type textFormatter struct{ m *ModbusCLI }
+
+func (f textFormatter) formatBits(startRef int, bits []bool, regType string) {
+	f.m.printBitBlock(startRef, bits, regType)
+}
+
+func (f textFormatter) formatRegisters(startRef int, registers []uint16, regType string) error {
+	return f.m.printRegisters(startRef, registers, regType)
+}
+
+// jsonFormatter prints a line of JSON per value.
+type jsonFormatter struct{ m *ModbusCLI }
+
+func (f jsonFormatter) formatBits(startRef int, bits []bool, regType string) {
+	f.m.printBitsJSON("read", startRef, bits)
+}
+
+func (f jsonFormatter) formatRegisters(startRef int, registers []uint16, regType string) error {
+	f.m.printRegistersJSON("read", startRef, registers)
+	return nil
+}
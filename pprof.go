@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof/ on http.DefaultServeMux
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// connCounts tallies the device connections attempted by connect, for the
+// runtime diagnostics endpoint.
+type connCounts struct {
+	opened atomic.Int64
+	failed atomic.Int64
+}
+
+// servePprof serves the Go runtime profiles under /debug/pprof/, so the
+// heap and allocation rate of a long-running poller can be inspected with
+// "go tool pprof http://ADDR/debug/pprof/heap". /debug/runtime adds a JSON
+// summary of goroutines, memory and device connections for a quick look
+// when a deployment reports CPU spikes.
+func (m *ModbusCLI) servePprof(listener net.Listener) {
+	started := time.Now()
+	http.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"uptime_seconds":      int(time.Since(started).Seconds()),
+			"goroutines":          runtime.NumGoroutine(),
+			"cpus":                runtime.NumCPU(),
+			"heap_alloc_bytes":    mem.HeapAlloc,
+			"heap_objects":        mem.HeapObjects,
+			"total_alloc_bytes":   mem.TotalAlloc,
+			"gc_cycles":           mem.NumGC,
+			"connections_opened":  m.conns.opened.Load(),
+			"connection_failures": m.conns.failed.Load(),
+		})
+	})
+
+	if err := http.Serve(listener, http.DefaultServeMux); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: pprof endpoint stopped: %v\n", err)
+	}
+}
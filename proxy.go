@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// modbusProxy multiplexes any number of Modbus TCP clients onto a single
+// upstream connection, logging every transaction as it goes.
+type modbusProxy struct {
+	config *Config
+
+	mu       sync.Mutex // serializes upstream transactions
+	upstream net.Conn
+	nextTxn  uint16
+}
+
+func (m *ModbusCLI) runProxy() error {
+	listener, err := net.Listen("tcp", m.config.ProxyListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", m.config.ProxyListen, err)
+	}
+	defer listener.Close()
+
+	proxy := &modbusProxy{config: m.config}
+	defer proxy.closeUpstream()
+
+	fmt.Printf("Proxying %s -> %s", listener.Addr(), m.config.Upstream)
+	if m.config.ReadOnly {
+		fmt.Printf(" (read-only)")
+	}
+	fmt.Println()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept client: %v", err)
+		}
+		go proxy.serveClient(conn)
+	}
+}
+
+func (p *modbusProxy) serveClient(conn net.Conn) {
+	defer conn.Close()
+
+	client := conn.RemoteAddr().String()
+	p.logf(client, "connected")
+
+	for {
+		req, err := readMBAPFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				p.logf(client, "dropped: %v", err)
+			} else {
+				p.logf(client, "disconnected")
+			}
+			return
+		}
+
+		res := p.handle(client, req)
+		if _, err := conn.Write(res.Bytes()); err != nil {
+			p.logf(client, "dropped: %v", err)
+			return
+		}
+	}
+}
+
+// handle applies the proxy policy to req and returns the response to send
+// back to the client.
+func (p *modbusProxy) handle(client string, req *mbapFrame) *mbapFrame {
+	desc := fmt.Sprintf("unit=%d %s", req.UnitID, describePDU(req.PDU, p.config.MaskValues))
+
+	if !isReadRequest(req.PDU) {
+		if err := p.config.checkWrite(time.Now()); err != nil {
+			p.logf(client, "%s -> denied (%v)", desc, err)
+			return exceptionResponse(req, exIllegalFunction)
+		}
+	}
+
+	start := time.Now()
+	res, err := p.forward(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		p.logf(client, "%s -> upstream error: %v", desc, err)
+		return exceptionResponse(req, exGWTargetFailedToRespond)
+	}
+
+	if fc := res.FunctionCode(); fc&0x80 != 0 && len(res.PDU) > 1 {
+		p.logf(client, "%s -> exception 0x%02X (%.1f ms)", desc, res.PDU[1],
+			float64(elapsed.Microseconds())/1000)
+	} else {
+		p.logf(client, "%s -> ok (%.1f ms)", desc, float64(elapsed.Microseconds())/1000)
+	}
+
+	return res
+}
+
+// forward sends req upstream under a proxy-owned transaction id and returns
+// the matching response, rewritten to carry the client's transaction id.
+func (p *modbusProxy) forward(req *mbapFrame) (*mbapFrame, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.upstream == nil {
+		conn, err := net.DialTimeout("tcp", p.config.Upstream, p.config.dialTimeout())
+		if err != nil {
+			return nil, err
+		}
+		p.upstream = conn
+	}
+
+	p.nextTxn++
+	upstreamReq := &mbapFrame{TxnID: p.nextTxn, UnitID: req.UnitID, PDU: req.PDU}
+
+	p.upstream.SetDeadline(time.Now().Add(p.config.Timeout))
+	if _, err := p.upstream.Write(upstreamReq.Bytes()); err != nil {
+		p.closeUpstreamLocked()
+		return nil, err
+	}
+
+	for {
+		res, err := readMBAPFrame(p.upstream)
+		if err != nil {
+			// The stream can't be trusted after a timeout or framing error
+			p.closeUpstreamLocked()
+			return nil, err
+		}
+		// Skip late responses to requests that already timed out
+		if res.TxnID != upstreamReq.TxnID {
+			continue
+		}
+		res.TxnID = req.TxnID
+		return res, nil
+	}
+}
+
+func (p *modbusProxy) closeUpstream() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeUpstreamLocked()
+}
+
+func (p *modbusProxy) closeUpstreamLocked() {
+	if p.upstream != nil {
+		p.upstream.Close()
+		p.upstream = nil
+	}
+}
+
+func (p *modbusProxy) logf(client, format string, args ...interface{}) {
+	fmt.Printf("%s %s %s\n", time.Now().Format("2006-01-02 15:04:05.000"), client,
+		fmt.Sprintf(format, args...))
+}
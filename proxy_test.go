@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echoUpstream is a fake upstream device answering every request with its
+// own PDU and recording what reached it.
+type echoUpstream struct {
+	listener net.Listener
+	mu       sync.Mutex
+	received [][]byte
+}
+
+func startEchoUpstream(t *testing.T) *echoUpstream {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &echoUpstream{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					req, err := readMBAPFrame(conn)
+					if err != nil {
+						return
+					}
+					u.mu.Lock()
+					u.received = append(u.received, req.PDU)
+					u.mu.Unlock()
+					conn.Write(req.Bytes())
+				}
+			}()
+		}
+	}()
+	return u
+}
+
+func (u *echoUpstream) forwarded() [][]byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.received
+}
+
+func newTestProxy(t *testing.T, upstream string, readOnly bool) *modbusProxy {
+	t.Helper()
+	p := &modbusProxy{config: &Config{Upstream: upstream, Timeout: time.Second, ReadOnly: readOnly}}
+	t.Cleanup(p.closeUpstream)
+	return p
+}
+
+func TestProxyReadOnly(t *testing.T) {
+	upstream := startEchoUpstream(t)
+	proxy := newTestProxy(t, upstream.listener.Addr().String(), true)
+
+	tests := []struct {
+		name string
+		pdu  []byte
+		pass bool
+	}{
+		{"read holding registers", []byte{0x03, 0, 100, 0, 2}, true},
+		{"read device identification", []byte{0x2b, 0x0e, 1, 0}, true},
+		{"return bus message count", []byte{0x08, 0, 0x0b, 0, 0}, true},
+		{"write single register", []byte{0x06, 0, 1, 0, 7}, false},
+		{"write multiple coils", []byte{0x0f, 0, 1, 0, 1, 1, 1}, false},
+		{"restart communications", []byte{0x08, 0, 0x01, 0, 0}, false},
+		{"force listen only", []byte{0x08, 0, 0x04, 0, 0}, false},
+		{"clear counters", []byte{0x08, 0, 0x0a, 0, 0}, false},
+		{"vendor function", []byte{0x41, 1, 2}, false},
+		{"unknown function", []byte{0x64}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(upstream.forwarded())
+			req := &mbapFrame{TxnID: 0x4242, UnitID: 3, PDU: tt.pdu}
+			var res *mbapFrame
+			out := captureStdout(t, func() { res = proxy.handle("client", req) })
+
+			forwarded := len(upstream.forwarded()) > before
+			if tt.pass {
+				if !forwarded || !bytes.Equal(res.PDU, tt.pdu) || res.TxnID != 0x4242 || res.UnitID != 3 {
+					t.Errorf("read not passed through: %+v (forwarded %v)", res, forwarded)
+				}
+				return
+			}
+			if forwarded {
+				t.Errorf("request reached the upstream device")
+			}
+			if want := []byte{tt.pdu[0] | 0x80, exIllegalFunction}; !bytes.Equal(res.PDU, want) || res.TxnID != 0x4242 {
+				t.Errorf("response = %+v, want Illegal Function", res)
+			}
+			if !strings.Contains(out, "denied (read-only mode is enabled)") {
+				t.Errorf("log:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestProxyPassesWrites(t *testing.T) {
+	upstream := startEchoUpstream(t)
+	proxy := newTestProxy(t, upstream.listener.Addr().String(), false)
+	for _, pdu := range [][]byte{{0x06, 0, 1, 0, 7}, {0x08, 0, 0x01, 0, 0}, {0x41, 1}} {
+		var res *mbapFrame
+		captureStdout(t, func() { res = proxy.handle("client", &mbapFrame{TxnID: 1, UnitID: 1, PDU: pdu}) })
+		if !bytes.Equal(res.PDU, pdu) {
+			t.Errorf("% x answered with % x", pdu, res.PDU)
+		}
+	}
+	if got := len(upstream.forwarded()); got != 3 {
+		t.Errorf("%d requests forwarded, want 3", got)
+	}
+}
+
+func TestProxyUpstreamFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	proxy := newTestProxy(t, addr, false)
+	var res *mbapFrame
+	out := captureStdout(t, func() { res = proxy.handle("client", &mbapFrame{TxnID: 9, UnitID: 1, PDU: []byte{0x03, 0, 0, 0, 1}}) })
+	if want := []byte{0x83, exGWTargetFailedToRespond}; !bytes.Equal(res.PDU, want) || res.TxnID != 9 {
+		t.Errorf("response = %+v, want Gateway Target Failed To Respond", res)
+	}
+	if !strings.Contains(out, "upstream error") {
+		t.Errorf("log:\n%s", out)
+	}
+}
+
+func TestProxyServeClient(t *testing.T) {
+	upstream := startEchoUpstream(t)
+	proxy := newTestProxy(t, upstream.listener.Addr().String(), true)
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	out := captureStdout(t, func() {
+		go func() {
+			proxy.serveClient(server)
+			close(done)
+		}()
+		// Several requests on one connection keep their transaction ids
+		for txn, pdu := range [][]byte{{0x03, 0, 0, 0, 1}, {0x06, 0, 0, 0, 1}} {
+			req := &mbapFrame{TxnID: uint16(txn + 100), UnitID: 1, PDU: pdu}
+			client.Write(req.Bytes())
+			res, err := readMBAPFrame(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.TxnID != req.TxnID {
+				t.Errorf("transaction id %d, want %d", res.TxnID, req.TxnID)
+			}
+		}
+		client.Close()
+		<-done
+	})
+	for _, want := range []string{"connected", "fc=0x03 (read holding registers) addr=0 qty=1 -> ok", "fc=0x06 (write single register)", "denied", "disconnected"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log lacks %q:\n%s", want, out)
+		}
+	}
+}
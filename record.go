@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordFormats maps --format names to their sample encoders. Every encoding
+// is self-delimiting, so records can simply be concatenated in a stream.
+var recordFormats = map[string]func(sample *pollSample) ([]byte, error){
+	"cbor":    encodeCBOR,
+	"msgpack": encodeMsgPack,
+	"ndjson":  encodeNDJSON,
+}
+
+// encodeNDJSON encodes sample as one line of JSON, the payload published to
+// NATS. Records are written unbuffered, so tools tailing the stream see every
+// sample as soon as it is read.
+func encodeNDJSON(sample *pollSample) ([]byte, error) {
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// recordSink writes every sample to a file or stdout in a record format.
+type recordSink struct {
+	name   string
+	mu     sync.Mutex // guards writes and closing the file
+	out    io.Writer
+	file   io.Closer // nil when writing to stdout
+	encode func(sample *pollSample) ([]byte, error)
+	closed bool
+}
+
+// newRecordSink opens the --out file for appending, rotating it with
+// --rotate, or uses stdout when there is none.
+func newRecordSink(c *Config) (*recordSink, error) {
+	encode, ok := recordFormats[c.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format: %s", c.Format)
+	}
+
+	if c.OutFile == "" {
+		return &recordSink{name: c.Format, out: os.Stdout, encode: encode}, nil
+	}
+
+	if c.RotateSize > 0 || c.RotateEvery > 0 {
+		file, err := openRotatingFile(c.OutFile, c.RotateSize, c.RotateEvery, c.Keep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open output file: %v", err)
+		}
+		return &recordSink{name: c.OutFile, out: file, file: file, encode: encode}, nil
+	}
+
+	file, err := os.OpenFile(c.OutFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %v", err)
+	}
+	return &recordSink{name: c.OutFile, out: file, file: file, encode: encode}, nil
+}
+
+func (r *recordSink) Name() string {
+	return r.name
+}
+
+// Send writes samples; state changes aren't recorded.
+func (r *recordSink) Send(ev busEvent) error {
+	if ev.sample == nil {
+		return nil
+	}
+	record, err := r.encode(ev.sample)
+	if err != nil {
+		return fmt.Errorf("failed to encode sample: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return fmt.Errorf("%s is closed", r.name)
+	}
+	_, err = r.out.Write(record)
+	return err
+}
+
+func (r *recordSink) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.file != nil {
+		r.file.Close()
+	}
+}
+
+// binaryEncoder is implemented by the CBOR and MessagePack writers so both
+// can share the reflection walk in encodeBinary.
+type binaryEncoder interface {
+	Nil()
+	Bool(v bool)
+	Int(v int64)
+	Uint(v uint64)
+	Float32(v float32)
+	Float64(v float64)
+	String(v string)
+	Time(v time.Time)
+	ArrayHeader(n int)
+	MapHeader(n int)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeBinary walks v and emits it through enc. Structs are encoded as maps
+// keyed by their json tag names, honoring "-" and omitempty.
+func encodeBinary(enc binaryEncoder, v reflect.Value) error {
+	if !v.IsValid() {
+		enc.Nil()
+		return nil
+	}
+	if v.Type() == timeType {
+		enc.Time(v.Interface().(time.Time))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			enc.Nil()
+			return nil
+		}
+		return encodeBinary(enc, v.Elem())
+
+	case reflect.Bool:
+		enc.Bool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		enc.Int(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		enc.Uint(v.Uint())
+
+	case reflect.Float32:
+		enc.Float32(float32(v.Float()))
+
+	case reflect.Float64:
+		enc.Float64(v.Float())
+
+	case reflect.String:
+		enc.String(v.String())
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			enc.Nil()
+			return nil
+		}
+		enc.ArrayHeader(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeBinary(enc, v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		enc.MapHeader(len(keys))
+		for _, key := range keys {
+			enc.String(key.String())
+			if err := encodeBinary(enc, v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		type field struct {
+			name  string
+			value reflect.Value
+		}
+		var fields []field
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if strings.Contains(opts, "omitempty") && v.Field(i).IsZero() {
+				continue
+			}
+			fields = append(fields, field{name, v.Field(i)})
+		}
+
+		enc.MapHeader(len(fields))
+		for _, f := range fields {
+			enc.String(f.name)
+			if err := encodeBinary(enc, f.value); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// orderedMap keeps decoded map entries in their encoded order when they are
+// rendered back to JSON.
+type orderedMap []mapEntry
+
+type mapEntry struct {
+	Key   string
+	Value interface{}
+}
+
+func (o orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// replayStep is one row of a --replay file: values written to ref at an
+// offset from the first row.
+type replayStep struct {
+	row    int
+	offset time.Duration
+	ref    int
+	args   []string
+}
+
+// replayTimeLayouts are the accepted timestamp formats of a --replay file,
+// besides plain seconds.
+var replayTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseReplayTime parses a --replay timestamp: a date and time, or a number
+// of seconds since any fixed point.
+func parseReplayTime(field string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(field, 64); err == nil {
+		return time.Unix(0, 0).Add(time.Duration(secs * float64(time.Second))), nil
+	}
+	for _, layout := range replayTimeLayouts {
+		if t, err := time.ParseInLocation(layout, field, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", field)
+}
+
+// loadReplay reads a --replay file of TIME,REF,VALUE[,VALUE...] rows, as
+// recorded from an operator sequence. Rows must be in time order.
+func loadReplay(path string) ([]replayStep, error) {
+	records, err := loadWriteRows(path, "", 2, "TIME,REF,VALUE[,VALUE...]")
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]replayStep, 0, len(records))
+	var first time.Time
+	for _, record := range records {
+		at, err := parseReplayTime(record.fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, record.row, err)
+		}
+		ref, err := strconv.Atoi(record.fields[1])
+		if err != nil || ref < 0 || ref > 65535 {
+			return nil, fmt.Errorf("%s:%d: invalid reference %q", path, record.row, record.fields[1])
+		}
+
+		if len(steps) == 0 {
+			first = at
+		}
+		step := replayStep{row: record.row, offset: at.Sub(first), ref: ref, args: record.args}
+		if len(steps) > 0 && step.offset < steps[len(steps)-1].offset {
+			return nil, fmt.Errorf("%s:%d: rows must be in time order", path, record.row)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// runReplay writes the rows of the --replay file at their recorded pace,
+// divided by --replay-speed. Every write is journaled like a normal one, so
+// the whole replay can be reverted with undo --session. The replay stops at
+// the first failed write, as the rest of the sequence would no longer match
+// the recording.
+func (m *ModbusCLI) runReplay() error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	steps := m.config.Replay
+	total := time.Duration(float64(steps[len(steps)-1].offset) / m.config.ReplaySpeed)
+	fmt.Fprintf(m.out, "Replaying %d write(s) over %v\n", len(steps), total.Round(time.Millisecond))
+
+	wait := newPollTimer()
+	defer wait.Stop()
+
+	start := time.Now()
+	for i, step := range steps {
+		due := start.Add(time.Duration(float64(step.offset) / m.config.ReplaySpeed))
+		wait.Reset(time.Until(due))
+		select {
+		case <-stop:
+			fmt.Fprintf(m.out, "Replay interrupted after %d of %d write(s)\n", i, len(steps))
+			return nil
+		case <-wait.C:
+		}
+
+		fmt.Fprintf(m.out, "[+%v] row %d:\n", time.Since(start).Round(time.Millisecond), step.row)
+		m.config.WriteArgs = step.args
+		started := time.Now()
+		m.report.setValues(step.args, m.config.MaskValues)
+		err := m.withLock(func() error { return m.journaledWrite(step.ref) })
+		m.report.request("write", step.ref, len(step.args), started, err)
+		if err != nil {
+			return fmt.Errorf("replay stopped at row %d: %w", step.row, err)
+		}
+	}
+	return nil
+}
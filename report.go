@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxReportRequests bounds the requests listed in a report, so a report of a
+// long polling session stays readable; later requests are only counted.
+const maxReportRequests = 1000
+
+// runReport collects what happened during a run for --report: the
+// configuration, every request with its values and timing, and the outcome
+// of --expect assertions.
+type runReport struct {
+	path       string
+	started    time.Time
+	config     [][2]string
+	requests   []reportRequest
+	omitted    int
+	failed     int
+	assertions []reportAssertion
+	values     string // values of the request in progress
+}
+
+type reportRequest struct {
+	Time      time.Time
+	Operation string
+	Reference string
+	Values    string
+	Error     string
+	Duration  time.Duration
+}
+
+type reportAssertion struct {
+	Time       time.Time
+	Expression string
+	Passed     bool
+}
+
+func newRunReport(path string, config [][2]string) *runReport {
+	return &runReport{path: path, started: time.Now(), config: config}
+}
+
+// reportConfig lists the settings that shaped the run.
+func (m *ModbusCLI) reportConfig() [][2]string {
+	c := m.config
+	config := [][2]string{
+		{"Command", strings.Join(os.Args, " ")},
+		{"gomodbus", describeBuild(currentBuildInfo())},
+		{"Mode", strings.ToUpper(c.Mode)},
+		{"Target", m.target()},
+		{"Unit ID", strconv.Itoa(c.SlaveID)},
+		{"Data type", c.DataType + " (" + dataTypeDescription(c.DataType) + ")"},
+		{"Start reference", strconv.Itoa(m.startRef())},
+		{"Count", strconv.Itoa(c.Count)},
+		{"Timeout", c.Timeout.String()},
+		{"Poll rate", c.PollRate.String()},
+	}
+	if c.ConnectTimeout > 0 {
+		config = append(config, [2]string{"Connect timeout", c.ConnectTimeout.String()})
+	}
+	if c.Mode == "rtu" {
+		config = append(config, [2]string{"Serial settings",
+			fmt.Sprintf("%d baud, %d%c%d", c.Baudrate, c.Databits, m.getParityChar(), c.Stopbits)})
+	}
+	if c.Expect != nil {
+		config = append(config, [2]string{"Expect", c.Expect.src})
+	}
+	return config
+}
+
+// setValues notes the values of the request in progress.
+func (r *runReport) setValues(values interface{}, mask bool) {
+	if r == nil {
+		return
+	}
+	if mask {
+		r.values = maskPlaceholder
+		return
+	}
+	// Coils are listed as 1 and 0 like everywhere else
+	if bits, ok := values.([]bool); ok {
+		ints := make([]int, len(bits))
+		for i, bit := range bits {
+			ints[i] = boolToInt(bit)
+		}
+		values = ints
+	}
+	r.values = strings.Trim(fmt.Sprint(values), "[]")
+}
+
+// request records a finished request.
+func (r *runReport) request(operation string, first, count int, started time.Time, err error) {
+	if r == nil {
+		return
+	}
+	values := r.values
+	r.values = ""
+
+	// A false --expect is an assertion failure, not a failed request
+	if errors.Is(err, errExpectationFailed) {
+		err = nil
+	}
+
+	if err != nil {
+		r.failed++
+	}
+	if len(r.requests) == maxReportRequests {
+		r.omitted++
+		return
+	}
+
+	req := reportRequest{
+		Time:      started,
+		Operation: operation,
+		Reference: strconv.Itoa(first),
+		Values:    values,
+		Duration:  time.Since(started),
+	}
+	if count > 1 {
+		req.Reference += "-" + strconv.Itoa(first+count-1)
+	}
+	if err != nil {
+		req.Error = err.Error()
+	}
+	r.requests = append(r.requests, req)
+}
+
+// assert records the outcome of an --expect evaluation.
+func (r *runReport) assert(expression string, passed bool) {
+	if r == nil {
+		return
+	}
+	r.assertions = append(r.assertions, reportAssertion{time.Now(), expression, passed})
+}
+
+// reportData is what the report templates render.
+type reportData struct {
+	Generated     time.Time
+	Duration      time.Duration
+	Config        [][2]string
+	Requests      []reportRequest
+	Omitted       int
+	Total         int
+	Failed        int
+	MinLatency    time.Duration
+	AvgLatency    time.Duration
+	MaxLatency    time.Duration
+	Assertions    []reportAssertion
+	FailedAsserts int
+	Passed        bool
+}
+
+func (r *runReport) data() reportData {
+	d := reportData{
+		Generated:  time.Now(),
+		Duration:   time.Since(r.started).Round(time.Millisecond),
+		Config:     r.config,
+		Requests:   r.requests,
+		Omitted:    r.omitted,
+		Total:      len(r.requests) + r.omitted,
+		Failed:     r.failed,
+		Assertions: r.assertions,
+	}
+
+	var sum time.Duration
+	for i, req := range r.requests {
+		if i == 0 || req.Duration < d.MinLatency {
+			d.MinLatency = req.Duration
+		}
+		if req.Duration > d.MaxLatency {
+			d.MaxLatency = req.Duration
+		}
+		sum += req.Duration
+	}
+	if len(r.requests) > 0 {
+		d.AvgLatency = sum / time.Duration(len(r.requests))
+	}
+
+	for _, a := range r.assertions {
+		if !a.Passed {
+			d.FailedAsserts++
+		}
+	}
+	d.Passed = d.Failed == 0 && d.FailedAsserts == 0
+	return d
+}
+
+// reportFuncs are shared by the Markdown and HTML templates.
+var reportFuncs = map[string]interface{}{
+	"clock": func(t time.Time) string { return t.Format("15:04:05.000") },
+	"stamp": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"ms":    func(d time.Duration) string { return fmt.Sprintf("%.1f ms", millis(d)) },
+	"cell":  func(s string) string { return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ") },
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(
+	`# gomodbus Commissioning Report
+
+Generated {{stamp .Generated}}, run took {{.Duration}}.
+
+**Result: {{if .Passed}}PASS{{else}}FAIL{{end}}** - {{.Total}} request(s), {{.Failed}} failed
+{{- if .Assertions}}; {{len .Assertions}} assertion(s), {{.FailedAsserts}} failed{{end}}
+
+## Configuration
+
+| Setting | Value |
+|---|---|
+{{range .Config}}| {{index . 0}} | {{cell (index . 1)}} |
+{{end}}
+## Timing
+
+| Requests | Failed | Min | Avg | Max |
+|---|---|---|---|---|
+| {{.Total}} | {{.Failed}} | {{ms .MinLatency}} | {{ms .AvgLatency}} | {{ms .MaxLatency}} |
+{{if .Assertions}}
+## Assertions
+
+| Time | Expression | Result |
+|---|---|---|
+{{range .Assertions}}| {{clock .Time}} | {{cell .Expression}} | {{if .Passed}}PASS{{else}}FAIL{{end}} |
+{{end}}{{end}}
+## Requests
+
+| Time | Operation | Reference | Values | Duration | Result |
+|---|---|---|---|---|---|
+{{range .Requests}}| {{clock .Time}} | {{.Operation}} | {{.Reference}} | {{cell .Values}} | {{ms .Duration}} | {{if .Error}}{{cell .Error}}{{else}}OK{{end}} |
+{{end}}{{if .Omitted}}
+{{.Omitted}} later request(s) not listed.
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(
+	`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gomodbus Commissioning Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.pass { color: #080; font-weight: bold; }
+.fail { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>gomodbus Commissioning Report</h1>
+<p>Generated {{stamp .Generated}}, run took {{.Duration}}.</p>
+<p>Result: {{if .Passed}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}} &ndash;
+{{.Total}} request(s), {{.Failed}} failed{{if .Assertions}}; {{len .Assertions}} assertion(s), {{.FailedAsserts}} failed{{end}}</p>
+
+<h2>Configuration</h2>
+<table>
+<tr><th>Setting</th><th>Value</th></tr>
+{{range .Config}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+
+<h2>Timing</h2>
+<table>
+<tr><th>Requests</th><th>Failed</th><th>Min</th><th>Avg</th><th>Max</th></tr>
+<tr><td>{{.Total}}</td><td>{{.Failed}}</td><td>{{ms .MinLatency}}</td><td>{{ms .AvgLatency}}</td><td>{{ms .MaxLatency}}</td></tr>
+</table>
+{{if .Assertions}}
+<h2>Assertions</h2>
+<table>
+<tr><th>Time</th><th>Expression</th><th>Result</th></tr>
+{{range .Assertions}}<tr><td>{{clock .Time}}</td><td>{{.Expression}}</td><td>{{if .Passed}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Requests</h2>
+<table>
+<tr><th>Time</th><th>Operation</th><th>Reference</th><th>Values</th><th>Duration</th><th>Result</th></tr>
+{{range .Requests}}<tr><td>{{clock .Time}}</td><td>{{.Operation}}</td><td>{{.Reference}}</td><td>{{.Values}}</td><td>{{ms .Duration}}</td><td>{{if .Error}}<span class="fail">{{.Error}}</span>{{else}}OK{{end}}</td></tr>
+{{end}}</table>
+{{if .Omitted}}<p>{{.Omitted}} later request(s) not listed.</p>{{end}}
+</body>
+</html>
+`))
+
+// isHTMLReport reports whether a --report file is rendered as HTML rather
+// than Markdown.
+func isHTMLReport(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+
+// write renders the report to its file.
+func (r *runReport) write() error {
+	file, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+
+	var tmpl interface {
+		Execute(io.Writer, interface{}) error
+	} = markdownReport
+	if isHTMLReport(r.path) {
+		tmpl = htmlReport
+	}
+	if err := tmpl.Execute(file, r.data()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// finishReport writes the --report file at the end of the run.
+func (m *ModbusCLI) finishReport() {
+	if err := m.report.write(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write report: %v\n", err)
+		return
+	}
+	fmt.Fprintf(m.out, "Report written to %s\n", m.report.path)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits are the --rotate size suffixes, 1024-based like logrotate's.
+var byteUnits = map[string]int64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// parseRotation parses a --rotate value: a size such as 100MB, or an
+// interval such as 30m, 6h or 1d.
+func parseRotation(spec string) (size int64, every time.Duration, err error) {
+	upper := strings.ToUpper(spec)
+	for _, unit := range []string{"KB", "MB", "GB", "B"} {
+		if number, ok := strings.CutSuffix(upper, unit); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n <= 0 {
+				return 0, 0, fmt.Errorf("invalid rotation size: %s", spec)
+			}
+			return int64(n * float64(byteUnits[unit])), 0, nil
+		}
+	}
+
+	if days, ok := strings.CutSuffix(spec, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid rotation interval: %s", spec)
+		}
+		return 0, time.Duration(n) * 24 * time.Hour, nil
+	}
+	every, err = time.ParseDuration(spec)
+	if err != nil || every <= 0 {
+		return 0, 0, fmt.Errorf("invalid rotation size or interval: %s (e.g. 100MB or 1h)", spec)
+	}
+	return 0, every, nil
+}
+
+// rotatingFile appends records to a file and rotates it like logrotate:
+// the file is renamed to FILE.1, older files shift up to FILE.keep and the
+// oldest is deleted, so tools following FILE by name pick up the new one.
+type rotatingFile struct {
+	path  string
+	size  int64         // rotate before the file grows past this, 0 for no limit
+	every time.Duration // rotate files older than this, 0 for no limit
+	keep  int
+
+	file    *os.File // nil after a failed rotation, reopened on the next write
+	written int64
+	opened  time.Time
+}
+
+func openRotatingFile(path string, size int64, every time.Duration, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, size: size, every: every, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.written, r.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// Write writes one record, rotating first when the record would take the
+// file past its size or the file has been written for the interval. Records
+// are never split between files.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	due := r.written > 0 &&
+		(r.size > 0 && r.written+int64(len(p)) > r.size ||
+			r.every > 0 && time.Since(r.opened) >= r.every)
+	if due || r.file == nil {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.written += int64(n)
+	return n, err
+}
+
+// rotate moves the current file out of the way and starts a new one. Files
+// are closed before renaming, which Windows requires.
+func (r *rotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+
+		os.Remove(r.backup(r.keep))
+		for i := r.keep - 1; i >= 1; i-- {
+			os.Rename(r.backup(i), r.backup(i+1))
+		}
+		if err := os.Rename(r.path, r.backup(1)); err != nil {
+			return fmt.Errorf("failed to rotate %s: %v", r.path, err)
+		}
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) backup(n int) string {
+	return r.path + "." + strconv.Itoa(n)
+}
+
+func (r *rotatingFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRotation(t *testing.T) {
+	tests := []struct {
+		spec  string
+		size  int64
+		every time.Duration
+	}{
+		{"100MB", 100 << 20, 0},
+		{"1.5kb", 1536, 0},
+		{"2GB", 2 << 30, 0},
+		{"512B", 512, 0},
+		{"30m", 0, 30 * time.Minute},
+		{"6h", 0, 6 * time.Hour},
+		{"1d", 0, 24 * time.Hour},
+		{"1h30m", 0, 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		size, every, err := parseRotation(tt.spec)
+		if err != nil || size != tt.size || every != tt.every {
+			t.Errorf("parseRotation(%q) = %d, %v, %v; want %d, %v", tt.spec, size, every, err, tt.size, tt.every)
+		}
+	}
+
+	for spec, want := range map[string]string{
+		"MB":   "invalid rotation size: MB",
+		"-1MB": "invalid rotation size: -1MB",
+		"0d":   "invalid rotation interval: 0d",
+		"xd":   "invalid rotation interval: xd",
+		"0s":   "invalid rotation size or interval: 0s",
+		"big":  "invalid rotation size or interval: big",
+	} {
+		if _, _, err := parseRotation(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseRotation(%q) error = %v, want %q", spec, err, want)
+		}
+	}
+}
+
+// readRotated returns the contents of path and of its backups 1 to keep, ""
+// for missing files.
+func readRotated(path string, keep int) []string {
+	contents := make([]string, keep+1)
+	for i := range contents {
+		name := path
+		if i > 0 {
+			name += "." + strconv.Itoa(i)
+		}
+		data, _ := os.ReadFile(name)
+		contents[i] = string(data)
+	}
+	return contents
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "poll.ndjson")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Appends to the existing file, and a record never straddles two files
+	for _, record := range []string{"aaaa\n", "bbbb\n", "cc\n", "dddddddddddd\n", "e\n"} {
+		if _, err := r.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := readRotated(path, 3)
+	want := []string{"e\n", "dddddddddddd\n", "bbbb\ncc\n", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "poll.cbor")
+	r, err := openRotatingFile(path, 0, time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+	r.opened = r.opened.Add(-time.Hour)
+	r.Write([]byte("third\n"))
+
+	got := readRotated(path, 1)
+	if got[0] != "third\n" || got[1] != "first\nsecond\n" {
+		t.Errorf("files = %q", got)
+	}
+}
+
+func TestRotatingFileReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "poll.ndjson")
+	r, err := openRotatingFile(path, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// After a failed rotation the next write opens the file again
+	r.file.Close()
+	r.file = nil
+	if _, err := r.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRotated(path, 1); got[0] != "record\n" || got[1] != "" {
+		t.Errorf("files = %q", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// scanProbes are the requests --scan-probe can send to find units: a read of
+// holding register 0, Report Server ID (FC17) or basic Read Device
+// Identification (FC43/14). Some slaves only answer specific functions.
+var scanProbes = map[string][]byte{
+	"read": {0x03, 0x00, 0x00, 0x00, 0x01},
+	"fc17": {fcReportServerID},
+	"fc43": {fcDeviceIdentification, meiReadDeviceID, 0x01, 0x00},
+}
+
+// parseUnitRange parses a --scan-units FIRST-LAST range.
+func parseUnitRange(spec string) (first, last int, err error) {
+	firstStr, lastStr, ok := strings.Cut(spec, "-")
+	first, err1 := strconv.Atoi(strings.TrimSpace(firstStr))
+	last, err2 := strconv.Atoi(strings.TrimSpace(lastStr))
+	if !ok || err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid unit range %q (expected FIRST-LAST, e.g. 1-247)", spec)
+	}
+	return first, last, nil
+}
+
+// runScan probes every unit id in the --scan-units range on the target, for
+// finding the slaves behind a Modbus TCP gateway. Units are probed one at a
+// time over one connection, reopened if the gateway drops it.
+func (m *ModbusCLI) runScan() error {
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	probe := scanProbes[m.config.ScanProbe]
+
+	fmt.Printf("Scanning units %d-%d on %s with %s probes...\n",
+		m.config.ScanFirst, m.config.ScanLast, address, m.config.ScanProbe)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	found := 0
+	for unit := m.config.ScanFirst; unit <= m.config.ScanLast; unit++ {
+		if conn == nil {
+			var err error
+			if conn, err = net.DialTimeout("tcp", address, m.config.dialTimeout()); err != nil {
+				return fmt.Errorf("failed to connect: %v", err)
+			}
+		}
+
+		start := time.Now()
+		res, err := rawExchange(conn, uint16(unit), uint8(unit), probe, m.config.Timeout)
+		elapsed := millis(time.Since(start))
+
+		detail, ok := m.describeScanResult(res, err)
+		if ok {
+			found++
+			fmt.Printf("Unit %3d: live (%s, %.1f ms)\n", unit, detail, elapsed)
+		} else if m.config.Verbose {
+			fmt.Printf("Unit %3d: %s\n", unit, detail)
+		}
+
+		// A timed out or garbled exchange may leave a late response on the
+		// connection; start the next unit on a fresh one
+		if err != nil && !isExceptionResponse(err) {
+			conn.Close()
+			conn = nil
+		}
+	}
+
+	fmt.Printf("%d unit(s) answered\n", found)
+	return nil
+}
+
+// describeScanResult describes a unit's answer to the probe. ok is true when
+// the unit itself answered, even with an exception; gateway exceptions mean
+// nothing answers at that unit id.
+func (m *ModbusCLI) describeScanResult(res []byte, err error) (detail string, ok bool) {
+	switch {
+	case errors.Is(err, modbus.ErrGWTargetFailedToRespond), errors.Is(err, modbus.ErrGWPathUnavailable):
+		return fmt.Sprintf("no response (%v)", err), false
+	case isExceptionResponse(err):
+		return fmt.Sprintf("exception: %v", err), true
+	case err != nil:
+		return fmt.Sprintf("no response (%v)", err), false
+	}
+
+	switch m.config.ScanProbe {
+	case "fc17":
+		desc, err := describeServerID(res)
+		if err != nil {
+			return "malformed server id response", true
+		}
+		return "server id " + desc, true
+	case "fc43":
+		desc, err := describeDeviceID(res)
+		if err != nil {
+			return "malformed device identification response", true
+		}
+		return desc, true
+	}
+	return "answered read of holding register 0", true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// syncRetry is how often the scan-complete register is read while waiting
+// for it to toggle; PLC scan cycles take a few to a few hundred ms.
+const syncRetry = 10 * time.Millisecond
+
+// Some PLCs only hold consistent data blocks between scan cycles and signal
+// the end of each cycle by toggling or counting up a "scan complete"
+// register. With --sync-register every read and write waits for the register
+// to change first, so it lands at the start of a fresh cycle, and no two
+// operations fall into the same one.
+
+// afterScan runs op once the --sync-register holding register has changed
+// value, or right away when there is none.
+func (m *ModbusCLI) afterScan(op func() error) error {
+	if m.config.SyncRef < 0 {
+		return op()
+	}
+	if err := m.waitForScan(); err != nil {
+		return err
+	}
+	return op()
+}
+
+// waitForScan reads the scan-complete register until its value differs from
+// the first one read, for up to --sync-wait.
+func (m *ModbusCLI) waitForScan() error {
+	ref := uint16(m.config.SyncRef)
+	first, err := m.readSyncRegister(ref)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(m.config.SyncWait)
+	for {
+		time.Sleep(syncRetry)
+		value, err := m.readSyncRegister(ref)
+		if err != nil {
+			return err
+		}
+		if value != first {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("scan-complete register %d did not change within %v (holds %d)", ref, m.config.SyncWait, value)
+		}
+	}
+}
+
+func (m *ModbusCLI) readSyncRegister(ref uint16) (uint16, error) {
+	var value uint16
+	err := m.retryBusy(false, func() error {
+		var err error
+		value, err = m.client.ReadRegister(ref, modbus.HOLDING_REGISTER)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read scan-complete register %d: %w", ref, err)
+	}
+	return value, nil
+}
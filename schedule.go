@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// writeAtLayouts are the accepted --at formats. Times without a zone are
+// local, like write windows.
+var writeAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseWriteAt parses the time given to --at.
+func parseWriteAt(value string) (time.Time, error) {
+	for _, layout := range writeAtLayouts {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected e.g. 2024-07-01T06:00:00)", value)
+}
+
+// waitForWriteTime holds a scheduled write until its time. The connection
+// is kept warm by reading the start reference every poll interval, so the
+// write goes out on an established link (and link problems show up before
+// the scheduled time rather than at it). It reports false if the wait was
+// interrupted.
+func (m *ModbusCLI) waitForWriteTime(startRef int) (bool, error) {
+	at := m.config.WriteAt
+	// Fail now rather than at the scheduled time if the write will be refused
+	if err := m.config.checkWrite(at); err != nil {
+		return false, fmt.Errorf("refusing to schedule write: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	fmt.Fprintf(m.out, "Write scheduled for %s (in %v), keeping the connection open\n",
+		at.Format("2006-01-02 15:04:05 MST"), time.Until(at).Round(time.Second))
+
+	due := time.NewTimer(time.Until(at))
+	defer due.Stop()
+	keepalive := time.NewTicker(m.config.PollRate)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-stop:
+			fmt.Fprintln(m.out, "Scheduled write cancelled")
+			return false, nil
+		case <-due.C:
+			return true, nil
+		case <-keepalive.C:
+			if err := probeRead(m.client, m.config.DataType, startRef); err != nil && !isExceptionResponse(err) {
+				fmt.Fprintf(os.Stderr, "Warning: keep-alive read failed: %v\n", err)
+				m.client.Close()
+				if err := m.connect(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
+		}
+	}
+}
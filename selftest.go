@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Outcomes of a self-test check.
+const (
+	checkOK   = "OK"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// selftestCheck is one line of the readiness report.
+type selftestCheck struct {
+	name   string
+	status string
+	detail string
+}
+
+// runSelftest implements "gomodbus selftest [OPTIONS] DEVICE|HOST": it checks
+// that the configured link can be used and that the device answers a read,
+// then prints a readiness report, also written with --junit and --tap. It
+// fails if any check failed, so it can guard service startup (e.g. systemd
+// ExecStartPre).
+func (m *ModbusCLI) runSelftest() error {
+	m.out = os.Stdout
+
+	var checks []selftestCheck
+	if m.config.Mode == "rtu" {
+		checks = append(checks, m.checkSerialPort())
+	} else {
+		checks = append(checks, m.checkReachable(), m.checkTLS())
+	}
+
+	// Only attempt the read once the link itself looks usable
+	linkOK := true
+	for _, check := range checks {
+		if check.status == checkFail {
+			linkOK = false
+		}
+	}
+	if linkOK {
+		checks = append(checks, m.checkRead())
+	} else {
+		checks = append(checks, selftestCheck{"read", checkSkip, "link checks failed"})
+	}
+
+	fmt.Printf("Self-test for %s (%s mode, slave %d)\n", m.target(), m.config.Mode, m.config.SlaveID)
+	suite := newTestSuite("selftest")
+	failed := 0
+	for _, check := range checks {
+		fmt.Printf("  [%-4s] %-10s %s\n", check.status, check.name, check.detail)
+		switch check.status {
+		case checkFail:
+			failed++
+			suite.add(check.name, 0, testFail, check.detail)
+		case checkSkip:
+			suite.add(check.name, 0, testSkip, check.detail)
+		default:
+			suite.add(check.name, 0, testPass, "")
+		}
+	}
+	if err := m.writeTestReports(suite); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("self-test failed: %d of %d check(s) failed", failed, len(checks))
+	}
+	fmt.Println("Ready")
+	return nil
+}
+
+// checkSerialPort verifies the serial device exists and can be opened for
+// reading and writing by the current user.
+func (m *ModbusCLI) checkSerialPort() selftestCheck {
+	check := selftestCheck{name: "serial"}
+
+	info, err := os.Stat(m.config.Device)
+	if err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		check.status, check.detail = checkFail, m.config.Device+" is not a character device"
+		return check
+	}
+
+	file, err := os.OpenFile(m.config.Device, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsPermission(err) {
+			check.status, check.detail = checkFail, "permission denied (is the user in the dialout group?)"
+		} else {
+			check.status, check.detail = checkFail, err.Error()
+		}
+		return check
+	}
+	file.Close()
+
+	check.status = checkOK
+	check.detail = fmt.Sprintf("%s is accessible (%d baud, %d%c%d)", m.config.Device,
+		m.config.Baudrate, m.config.Databits, m.getParityChar(), m.config.Stopbits)
+	return check
+}
+
+// checkReachable dials the host's TCP port. UDP modes are connectionless, so
+// there is nothing to check before the read.
+func (m *ModbusCLI) checkReachable() selftestCheck {
+	check := selftestCheck{name: "reachable"}
+
+	if m.config.Mode == "udp" || m.config.Mode == "rtuoverudp" {
+		check.status, check.detail = checkSkip, "connectionless transport"
+		return check
+	}
+
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, m.config.dialTimeout())
+	if err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	conn.Close()
+
+	check.status = checkOK
+	check.detail = fmt.Sprintf("connected to %s in %.1f ms", address, millis(time.Since(start)))
+	return check
+}
+
+// checkTLS performs a TLS handshake in tls mode.
+func (m *ModbusCLI) checkTLS() selftestCheck {
+	check := selftestCheck{name: "tls"}
+
+	if m.config.Mode != "tls" {
+		check.status, check.detail = checkSkip, "not using tls mode"
+		return check
+	}
+
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := &net.Dialer{Timeout: m.config.dialTimeout()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: m.config.Host})
+	if err != nil {
+		check.status, check.detail = checkFail, fmt.Sprintf("handshake failed: %v", err)
+		return check
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	check.status = checkOK
+	check.detail = fmt.Sprintf("%s, server certificate for %s", tls.VersionName(state.Version),
+		state.PeerCertificates[0].Subject.CommonName)
+	return check
+}
+
+// checkRead performs a single read at the start reference. An exception
+// response still proves the device is there and talking Modbus.
+func (m *ModbusCLI) checkRead() selftestCheck {
+	check := selftestCheck{name: "read"}
+
+	if err := m.setupClient(); err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	if err := m.connect(); err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	defer m.client.Close()
+
+	start := time.Now()
+	err := probeRead(m.client, m.config.DataType, m.startRef())
+	elapsed := millis(time.Since(start))
+
+	switch {
+	case err == nil:
+		check.status = checkOK
+		check.detail = fmt.Sprintf("reference %d answered in %.1f ms", m.startRef(), elapsed)
+	case isExceptionResponse(err):
+		check.status = checkOK
+		check.detail = fmt.Sprintf("device answered with exception: %v (%.1f ms)", err, elapsed)
+	default:
+		check.status, check.detail = checkFail, err.Error()
+	}
+	return check
+}
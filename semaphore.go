@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// lockRetry is how long to wait before checking a semaphore held by another
+// client again.
+const lockRetry = 100 * time.Millisecond
+
+// Some PLCs guard shared data blocks with a semaphore register: a client
+// writes its token to the register, reads it back to confirm that it owns
+// the block, does its reads and writes, and releases the block by writing
+// the free value back. Others grant the block in a separate confirmation
+// register, which --lock REF:CONFIRM names.
+
+// parseLockRefs parses a --lock value: REF, or REF:CONFIRM when the PLC
+// confirms ownership in a register of its own.
+func parseLockRefs(spec string) (ref, confirm int, err error) {
+	refSpec, confirmSpec, split := strings.Cut(spec, ":")
+	ref, err = strconv.Atoi(refSpec)
+	if err != nil || ref < 0 || ref > 65535 {
+		return 0, 0, fmt.Errorf("invalid semaphore register: %s", spec)
+	}
+	if !split {
+		return ref, ref, nil
+	}
+	confirm, err = strconv.Atoi(confirmSpec)
+	if err != nil || confirm < 0 || confirm > 65535 {
+		return 0, 0, fmt.Errorf("invalid semaphore confirmation register: %s", spec)
+	}
+	return ref, confirm, nil
+}
+
+// withLock runs op while holding the --lock semaphore, or simply runs it
+// when there is none. Either way op waits for the next scan cycle with
+// --sync-register.
+func (m *ModbusCLI) withLock(op func() error) error {
+	if m.config.LockRef < 0 {
+		return m.afterScan(op)
+	}
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+
+	err := m.afterScan(op)
+	if rerr := m.releaseLock(); rerr != nil && err == nil {
+		return rerr
+	}
+	return err
+}
+
+// acquireLock claims the semaphore: once the confirmation register reads
+// free, it writes the token and reads the confirmation back, until the
+// token shows there or --lock-wait runs out. Only a read-back following our
+// own write counts: a token found in the register before that belongs to
+// someone else, or to an earlier run that never released it. Claiming it
+// is a write, so read-only mode and write windows apply, even when the lock
+// guards a read.
+func (m *ModbusCLI) acquireLock() error {
+	c := m.config
+	if err := c.checkWrite(time.Now()); err != nil {
+		return fmt.Errorf("refusing to write semaphore register %d: %v", c.LockRef, err)
+	}
+	token := uint16(c.LockToken)
+	deadline := time.Now().Add(c.LockWait)
+	for {
+		owner, err := m.readLockRegister()
+		if err != nil {
+			return err
+		}
+
+		if owner == c.LockFree {
+			err := m.retryBusy(true, func() error {
+				return m.client.WriteRegister(uint16(c.LockRef), token)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write semaphore register %d: %w", c.LockRef, err)
+			}
+			if owner, err = m.readLockRegister(); err != nil {
+				return err
+			}
+			if owner == token {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if owner == token {
+				return fmt.Errorf("semaphore register %d not acquired within %v: it holds our token %d, so another client uses the same --lock-token or an earlier run didn't release it",
+					c.LockConfirm, c.LockWait, owner)
+			}
+			return fmt.Errorf("semaphore register %d not acquired within %v (holds %d)", c.LockConfirm, c.LockWait, owner)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+func (m *ModbusCLI) readLockRegister() (uint16, error) {
+	var value uint16
+	err := m.retryBusy(false, func() error {
+		var err error
+		value, err = m.client.ReadRegister(uint16(m.config.LockConfirm), modbus.HOLDING_REGISTER)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read semaphore register %d: %w", m.config.LockConfirm, err)
+	}
+	return value, nil
+}
+
+// releaseLock hands the semaphore back by writing the free value, once the
+// confirmation register shows it still holds our token; a semaphore the PLC
+// took back or granted to someone else is left alone. It only follows a
+// successful acquireLock, which checked that writes are allowed; a write
+// window closing in between must not leave the block locked.
+func (m *ModbusCLI) releaseLock() error {
+	owner, err := m.readLockRegister()
+	if err != nil {
+		return err
+	}
+	if owner != uint16(m.config.LockToken) {
+		return fmt.Errorf("semaphore register %d no longer holds our token %d (holds %d), so it was not released",
+			m.config.LockConfirm, m.config.LockToken, owner)
+	}
+
+	err = m.retryBusy(true, func() error {
+		return m.client.WriteRegister(uint16(m.config.LockRef), m.config.LockFree)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release semaphore register %d: %w", m.config.LockRef, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseLockRefs(t *testing.T) {
+	tests := []struct {
+		spec         string
+		ref, confirm int
+		wantErr      string
+	}{
+		{"100", 100, 100, ""},
+		{"100:101", 100, 101, ""},
+		{"0:65535", 0, 65535, ""},
+		{"x", 0, 0, "invalid semaphore register"},
+		{"65536", 0, 0, "invalid semaphore register"},
+		{"100:", 0, 0, "invalid semaphore confirmation register"},
+		{"100:-1", 0, 0, "invalid semaphore confirmation register"},
+	}
+	for _, tt := range tests {
+		ref, confirm, err := parseLockRefs(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseLockRefs(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || ref != tt.ref || confirm != tt.confirm {
+			t.Errorf("parseLockRefs(%q) = %d, %d, %v", tt.spec, ref, confirm, err)
+		}
+	}
+}
+
+// newLockClient returns a gomodbus connected to the server on port, using
+// semaphore register 10 with the given token.
+func newLockClient(t *testing.T, port int, token string) *ModbusCLI {
+	t.Helper()
+	m := &ModbusCLI{}
+	config, err := m.parseArgs([]string{"-p", strconv.Itoa(port), "--lock", "10",
+		"--lock-token", token, "--lock-wait", "0.3", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.config = config
+	if err := m.setupClient(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.client.Close() })
+	return m
+}
+
+func TestLockCompetingClients(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	port := startTestServer(t, sim)
+	a := newLockClient(t, port, "1")
+	b := newLockClient(t, port, "2")
+	sameToken := newLockClient(t, port, "1")
+
+	if err := a.acquireLock(); err != nil {
+		t.Fatal(err)
+	}
+	if sim.holding[10] != 1 {
+		t.Fatalf("semaphore = %d after the first client acquired it", sim.holding[10])
+	}
+
+	if err := b.acquireLock(); err == nil || !strings.Contains(err.Error(), "not acquired within 300ms (holds 1)") {
+		t.Errorf("second client acquired a held semaphore: %v", err)
+	}
+	// Finding its own token in the register doesn't make a client the owner
+	if err := sameToken.acquireLock(); err == nil || !strings.Contains(err.Error(), "holds our token 1") {
+		t.Errorf("client with the same token acquired a held semaphore: %v", err)
+	}
+
+	// Releasing a semaphore held by someone else leaves it alone
+	if err := b.releaseLock(); err == nil || !strings.Contains(err.Error(), "no longer holds our token 2 (holds 1)") {
+		t.Errorf("second client released a semaphore it doesn't hold: %v", err)
+	}
+	if sim.holding[10] != 1 {
+		t.Fatalf("semaphore = %d after a foreign release", sim.holding[10])
+	}
+
+	if err := a.releaseLock(); err != nil {
+		t.Fatal(err)
+	}
+	if sim.holding[10] != 0 {
+		t.Errorf("semaphore = %d after the owner released it", sim.holding[10])
+	}
+	if err := b.acquireLock(); err != nil {
+		t.Errorf("second client couldn't acquire the released semaphore: %v", err)
+	}
+	if sim.holding[10] != 2 {
+		t.Errorf("semaphore = %d, want the second client's token", sim.holding[10])
+	}
+}
+
+func TestLockAroundRead(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	sim.holding[3] = 99
+	port := startTestServer(t, sim)
+
+	out := mustRunTestClient(t, port, "-r", "3", "--lock", "10", "--lock-token", "5")
+	if !strings.Contains(out, "[3]: 99") || sim.holding[10] != 0 {
+		t.Errorf("semaphore %d after the locked read:\n%s", sim.holding[10], out)
+	}
+
+	if _, err := runTestClient(t, port, "-r", "3", "--lock", "10"); err == nil ||
+		!strings.Contains(err.Error(), "--lock needs --lock-token") {
+		t.Errorf("--lock without --lock-token: %v", err)
+	}
+}
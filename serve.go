@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// A serve map sizes the four tables of the simulated device and seeds their
+// values, as YAML or as the equivalent JSON object:
+//
+//	coils: 2000
+//	discrete_inputs: 2000
+//	input_registers: 1000
+//	holding_registers: 1000
+//	seed:
+//	  - type: 4:float
+//	    ref: 100
+//	    values: [21.5, 22]
+//	  - type: 1
+//	    ref: 0
+//	    values: [1, 0, 1]
+//	force:
+//	  - type: 4
+//	    ref: 10
+//	    values: [500]
+//
+// Tables that aren't sized span all 65536 addresses. Seed values are given
+// as for a write of their data type, which input registers and discrete
+// inputs may also be seeded with. Force entries are seeded the same way and
+// then keep their values through client writes, like a PLC force table.
+// Only this subset of YAML is understood.
+
+// serveSeed is one seed or force entry of a serve map.
+type serveSeed struct {
+	dataType string
+	exponent int
+	ref      int
+	values   []string
+	force    bool
+}
+
+// serveTables maps the table keys of a serve map to the table of their data
+// type.
+var serveTables = map[string]string{
+	"coils":             "0",
+	"discrete_inputs":   "1",
+	"input_registers":   "3",
+	"holding_registers": "4",
+}
+
+// simulator is the register map of a serve run, answering requests of any
+// unit ID.
+type simulator struct {
+	mu       sync.Mutex
+	coils    []bool
+	discrete []bool
+	input    []uint16
+	holding  []uint16
+
+	// forced holds the forced coils and holding registers by address,
+	// restored after every client write
+	forcedCoils   map[uint16]bool
+	forcedHolding map[uint16]uint16
+
+	// access counts the reads and writes of every address by table, nil
+	// unless --stats is given
+	access map[string]*accessCounts
+}
+
+func newSimulator(sizes map[string]int) *simulator {
+	return &simulator{
+		coils:         make([]bool, sizes["0"]),
+		discrete:      make([]bool, sizes["1"]),
+		input:         make([]uint16, sizes["3"]),
+		holding:       make([]uint16, sizes["4"]),
+		forcedCoils:   make(map[uint16]bool),
+		forcedHolding: make(map[uint16]uint16),
+	}
+}
+
+// loadServeMap reads a serve map into a simulator.
+func loadServeMap(path string) (*simulator, error) {
+	sizes, seeds, err := readServeMap(path)
+	if err != nil {
+		return nil, err
+	}
+	sim := newSimulator(sizes)
+	if err := sim.seedAll(path, seeds); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// readServeMap reads the table sizes and seed entries of a serve map: YAML,
+// JSON, or a register list exported as CSV or .xlsx.
+func readServeMap(path string) (map[string]int, []serveSeed, error) {
+	sizes := map[string]int{"0": 65536, "1": 65536, "3": 65536, "4": 65536}
+	var seeds []serveSeed
+	var err error
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".csv" || ext == ".xlsx" {
+		seeds, err = readServeRegisterList(path)
+	} else {
+		var data []byte
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, nil, err
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			seeds, err = parseServeJSON(data, sizes)
+		} else {
+			seeds, err = parseServeYAML(data, sizes)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return sizes, seeds, nil
+}
+
+// seedAll stores the seed entries read from path. Forced values are stored
+// after the seeded ones, so they win where the two overlap whatever order
+// the map lists them in.
+func (sim *simulator) seedAll(path string, seeds []serveSeed) error {
+	for _, force := range []bool{false, true} {
+		for _, seed := range seeds {
+			if seed.force != force {
+				continue
+			}
+			if err := sim.seed(seed); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseServeYAML(data []byte, sizes map[string]int) ([]serveSeed, error) {
+	var seeds []serveSeed
+	var seed *serveSeed
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		// Top-level keys start in the first column
+		if line[0] != ' ' && line[0] != '-' {
+			key, value, _ := strings.Cut(trimmed, ":")
+			section = ""
+			if key == "seed" || key == "force" {
+				section = key
+				seed = nil
+			} else if err := setServeSize(sizes, key, yamlScalar(value)); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: expected seed: or force:", lineNo)
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "-"); ok {
+			seeds = append(seeds, serveSeed{dataType: "4", ref: -1, force: section == "force"})
+			seed = &seeds[len(seeds)-1]
+			trimmed = strings.TrimSpace(item)
+			if trimmed == "" {
+				continue
+			}
+		}
+		if seed == nil {
+			return nil, fmt.Errorf("line %d: expected a seed entry starting with -", lineNo)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY: VALUE", lineNo)
+		}
+		if err := seed.set(strings.TrimSpace(key), yamlList(value)); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+	return seeds, scanner.Err()
+}
+
+func parseServeJSON(data []byte, sizes map[string]int) ([]serveSeed, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	// Go maps iterate in random order, so the keys are taken sorted to
+	// apply entries and report errors the same way every run
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var seeds []serveSeed
+	for _, key := range keys {
+		raw := doc[key]
+		if key != "seed" && key != "force" {
+			if err := setServeSize(sizes, key, string(raw)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var entries []map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&entries); err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		for i, entry := range entries {
+			seed := serveSeed{dataType: "4", ref: -1, force: key == "force"}
+			fields := make([]string, 0, len(entry))
+			for field := range entry {
+				fields = append(fields, field)
+			}
+			slices.Sort(fields)
+			for _, key := range fields {
+				value := entry[key]
+				var items []string
+				if list, ok := value.([]interface{}); ok {
+					for _, item := range list {
+						items = append(items, fmt.Sprint(item))
+					}
+				} else {
+					items = []string{fmt.Sprint(value)}
+				}
+				if err := seed.set(key, items); err != nil {
+					return nil, fmt.Errorf("%s %d: %v", seed.section(), i+1, err)
+				}
+			}
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds, nil
+}
+
+// setServeSize sets the size of the table named key.
+func setServeSize(sizes map[string]int, key, value string) error {
+	table, ok := serveTables[key]
+	if !ok {
+		return fmt.Errorf("unknown key %s (expected coils, discrete_inputs, input_registers, holding_registers, seed or force)", key)
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 || size > 65536 {
+		return fmt.Errorf("invalid %s size: %s (0-65536)", key, value)
+	}
+	sizes[table] = size
+	return nil
+}
+
+// set assigns a key of a seed entry.
+func (s *serveSeed) set(key string, items []string) error {
+	value := ""
+	if len(items) == 1 {
+		value = items[0]
+	}
+	switch key {
+	case "type":
+		dataType, exponent, fixed, err := parseFixedType(value)
+		if err != nil {
+			return err
+		}
+		name := dataType
+		if fixed {
+			name += ":SCALE"
+		}
+		if !slices.Contains(dataTypes, name) {
+			return fmt.Errorf("unsupported data type: %s", value)
+		}
+		s.dataType, s.exponent = dataType, exponent
+	case "ref":
+		ref, err := strconv.Atoi(value)
+		if err != nil || ref < 0 || ref > 65535 {
+			return fmt.Errorf("invalid reference: %s", value)
+		}
+		s.ref = ref
+	case "values":
+		s.values = items
+	default:
+		return fmt.Errorf("unknown %s key %s (expected type, ref or values)", s.section(), key)
+	}
+	return nil
+}
+
+// section returns the map section the entry is from.
+func (s *serveSeed) section() string {
+	if s.force {
+		return "force"
+	}
+	return "seed"
+}
+
+// seed stores the values of a seed entry, encoded as a write of its data
+// type would encode them.
+func (sim *simulator) seed(seed serveSeed) error {
+	if seed.ref < 0 {
+		return fmt.Errorf("%s entry needs a ref", seed.section())
+	}
+	for _, value := range seed.values {
+		if !isWriteValue(value) || isWriteTemplate(value) {
+			return fmt.Errorf("invalid value: %s", value)
+		}
+	}
+
+	table := seed.dataType[:1]
+	dataType := seed.dataType
+	if table == "1" {
+		dataType = "0"
+	} else if table == "3" {
+		dataType = "4" + dataType[1:]
+	}
+	m := &ModbusCLI{config: &Config{DataType: dataType, FixedExponent: seed.exponent, BigEndian: true, WriteArgs: seed.values}}
+	raw, err := m.encodeWriteRaw(dataType[:1])
+	if err != nil {
+		return err
+	}
+
+	var size int
+	switch table {
+	case "0":
+		size = len(sim.coils)
+	case "1":
+		size = len(sim.discrete)
+	case "3":
+		size = len(sim.input)
+	default:
+		size = len(sim.holding)
+	}
+	if seed.ref+len(raw) > size {
+		return fmt.Errorf("%s values from %d run past the end of the table (%d)", seed.section(), seed.ref, size)
+	}
+	for i, word := range raw {
+		addr := seed.ref + i
+		if seed.force && table == "0" {
+			sim.forcedCoils[uint16(addr)] = word != 0
+		} else if seed.force && table == "4" {
+			sim.forcedHolding[uint16(addr)] = word
+		}
+		switch table {
+		case "0":
+			sim.coils[seed.ref+i] = word != 0
+		case "1":
+			sim.discrete[seed.ref+i] = word != 0
+		case "3":
+			sim.input[seed.ref+i] = word
+		default:
+			sim.holding[seed.ref+i] = word
+		}
+	}
+	return nil
+}
+
+// inRange reports whether a request of quantity values from addr fits a
+// table of size values.
+func inRange(addr, quantity uint16, size int) bool {
+	return int(addr)+int(quantity) <= size
+}
+
+func (sim *simulator) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	serveLogf(req.ClientAddr, "unit=%d %s coils %d+%d", req.UnitId, readOrWrite(req.IsWrite), req.Addr, req.Quantity)
+	sim.countAccess("0", req.Addr, req.Quantity, req.IsWrite)
+
+	if !inRange(req.Addr, req.Quantity, len(sim.coils)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	if req.IsWrite {
+		copy(sim.coils[req.Addr:], req.Args)
+		kept := 0
+		for i := 0; i < int(req.Quantity); i++ {
+			addr := req.Addr + uint16(i)
+			if value, ok := sim.forcedCoils[addr]; ok {
+				sim.coils[addr] = value
+				kept++
+			}
+		}
+		logForced(req.ClientAddr, kept)
+	}
+	return slices.Clone(sim.coils[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+func (sim *simulator) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	serveLogf(req.ClientAddr, "unit=%d read discrete inputs %d+%d", req.UnitId, req.Addr, req.Quantity)
+	sim.countAccess("1", req.Addr, req.Quantity, false)
+
+	if !inRange(req.Addr, req.Quantity, len(sim.discrete)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return slices.Clone(sim.discrete[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+func (sim *simulator) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	serveLogf(req.ClientAddr, "unit=%d %s holding registers %d+%d", req.UnitId, readOrWrite(req.IsWrite), req.Addr, req.Quantity)
+	sim.countAccess("4", req.Addr, req.Quantity, req.IsWrite)
+
+	if !inRange(req.Addr, req.Quantity, len(sim.holding)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	if req.IsWrite {
+		copy(sim.holding[req.Addr:], req.Args)
+		kept := 0
+		for i := 0; i < int(req.Quantity); i++ {
+			addr := req.Addr + uint16(i)
+			if value, ok := sim.forcedHolding[addr]; ok {
+				sim.holding[addr] = value
+				kept++
+			}
+		}
+		logForced(req.ClientAddr, kept)
+	}
+	return slices.Clone(sim.holding[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+func (sim *simulator) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	serveLogf(req.ClientAddr, "unit=%d read input registers %d+%d", req.UnitId, req.Addr, req.Quantity)
+	sim.countAccess("3", req.Addr, req.Quantity, false)
+
+	if !inRange(req.Addr, req.Quantity, len(sim.input)) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return slices.Clone(sim.input[int(req.Addr) : int(req.Addr)+int(req.Quantity)]), nil
+}
+
+// logForced notes a client write that left kept forced values unchanged.
+func logForced(client string, kept int) {
+	if kept > 0 {
+		serveLogf(client, "kept %d forced value(s)", kept)
+	}
+}
+
+func readOrWrite(write bool) string {
+	if write {
+		return "write"
+	}
+	return "read"
+}
+
+// serveLogf logs a request in the format of the proxy log.
+func serveLogf(client, format string, args ...interface{}) {
+	fmt.Printf("%s %s %s\n", time.Now().Format("2006-01-02 15:04:05.000"), client,
+		fmt.Sprintf(format, args...))
+}
+
+// runServe implements "gomodbus serve [--map FILE] [--sunspec FILE...]
+// [--stats FILE] [LISTEN]": a Modbus TCP server simulating a device, for
+// testing SCADA clients without hardware. Every unit ID sees the same tables.
+func runServe(args []string) error {
+	listen := ":502"
+	path := ""
+	statsPath := ""
+	var models []string
+	sunspecBase := 40000
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--map":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			path = args[i+1]
+			i++
+		case "--stats":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			statsPath = args[i+1]
+			i++
+		case "--sunspec":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			models = append(models, strings.Split(args[i+1], ",")...)
+			i++
+		case "--sunspec-base":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			base, err := strconv.Atoi(args[i+1])
+			if err != nil || base < 0 || base > 65535 {
+				return fmt.Errorf("invalid --sunspec-base: %s (0-65535)", args[i+1])
+			}
+			sunspecBase = base
+			i++
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("unknown serve option: %s", arg)
+			}
+			listen = arg
+		}
+	}
+
+	sizes := map[string]int{"0": 65536, "1": 65536, "3": 65536, "4": 65536}
+	var seeds []serveSeed
+	if path != "" {
+		var err error
+		if sizes, seeds, err = readServeMap(path); err != nil {
+			return err
+		}
+	}
+	sim := newSimulator(sizes)
+	// The map's seeds go over the model defaults, so a map can set the
+	// values a test needs
+	if len(models) > 0 {
+		end, err := sim.loadSunSpec(models, sunspecBase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("SunSpec models in holding registers %d-%d\n", sunspecBase, end)
+	}
+	if err := sim.seedAll(path, seeds); err != nil {
+		return err
+	}
+
+	if statsPath != "" {
+		sim.trackAccess()
+	}
+
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        "tcp://" + listen,
+		Timeout:    time.Minute,
+		MaxClients: 32,
+	}, sim)
+	if err != nil {
+		return err
+	}
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", listen, err)
+	}
+	defer server.Stop()
+	fmt.Printf("Serving %d coils, %d discrete inputs, %d input and %d holding registers on %s\n",
+		len(sim.coils), len(sim.discrete), len(sim.input), len(sim.holding), listen)
+	if forced := len(sim.forcedCoils) + len(sim.forcedHolding); forced > 0 {
+		fmt.Printf("%d coil(s) and holding register(s) are forced\n", forced)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	<-stop
+
+	if statsPath != "" {
+		return sim.saveAccessStats(statsPath)
+	}
+	return nil
+}
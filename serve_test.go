@@ -0,0 +1,283 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+// loadTestServeMap loads a serve map given as text.
+func loadTestServeMap(t *testing.T, text string) *simulator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "map.yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sim, err := loadServeMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func TestServeForce(t *testing.T) {
+	for _, text := range []string{
+		`
+holding_registers: 100
+coils: 16
+force:
+  - type: 4
+    ref: 10
+    values: [500]
+  - type: 0
+    ref: 3
+    values: [1]
+`,
+		`{"holding_registers": 100, "coils": 16,
+ "force": [{"type": "4", "ref": 10, "values": [500]}, {"type": "0", "ref": 3, "values": [1]}]}`,
+	} {
+		sim := loadTestServeMap(t, text)
+		if sim.holding[10] != 500 || !sim.coils[3] {
+			t.Fatalf("forced values weren't seeded: %d %v", sim.holding[10], sim.coils[3])
+		}
+
+		got, err := sim.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{
+			Addr: 9, Quantity: 3, IsWrite: true, Args: []uint16{1, 2, 3},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []uint16{1, 500, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("holding registers after the write = %v, want %v", got, want)
+		}
+
+		coils, err := sim.HandleCoils(&modbus.CoilsRequest{
+			Addr: 2, Quantity: 3, IsWrite: true, Args: []bool{true, false, true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []bool{true, true, true}; !reflect.DeepEqual(coils, want) {
+			t.Errorf("coils after the write = %v, want %v", coils, want)
+		}
+	}
+}
+
+func TestServeForceAtTableEnd(t *testing.T) {
+	sim := loadTestServeMap(t, "force:\n  - ref: 65535\n    values: [7]\n")
+	got, err := sim.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{
+		Addr: 65534, Quantity: 2, IsWrite: true, Args: []uint16{1, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{1, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseServeMap(t *testing.T) {
+	yaml := `# test rig
+coils: 2000
+holding_registers: "1000"   # quoted
+---
+seed:
+  - type: 4:float
+    ref: 100
+    values: [21.5, 22]
+  -
+    type: 1
+    ref: 0
+    values: [1, 0, 1]
+  - ref: 5
+    values: 0x10
+`
+	json := `{"coils": 2000, "holding_registers": 1000, "seed": [
+ {"type": "4:float", "ref": 100, "values": [21.5, 22]},
+ {"type": 1, "ref": 0, "values": [1, 0, 1]},
+ {"ref": 5, "values": "0x10"}]}`
+	want := []serveSeed{
+		{dataType: "4:float", ref: 100, values: []string{"21.5", "22"}},
+		{dataType: "1", ref: 0, values: []string{"1", "0", "1"}},
+		{dataType: "4", ref: 5, values: []string{"0x10"}},
+	}
+	wantSizes := map[string]int{"0": 2000, "1": 65536, "3": 65536, "4": 1000}
+
+	for name, parse := range map[string]func() ([]serveSeed, map[string]int, error){
+		"yaml": func() ([]serveSeed, map[string]int, error) {
+			sizes := map[string]int{"0": 65536, "1": 65536, "3": 65536, "4": 65536}
+			seeds, err := parseServeYAML([]byte(yaml), sizes)
+			return seeds, sizes, err
+		},
+		"json": func() ([]serveSeed, map[string]int, error) {
+			sizes := map[string]int{"0": 65536, "1": 65536, "3": 65536, "4": 65536}
+			seeds, err := parseServeJSON([]byte(json), sizes)
+			return seeds, sizes, err
+		},
+	} {
+		seeds, sizes, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(sizes, wantSizes) {
+			t.Errorf("%s: sizes = %v, want %v", name, sizes, wantSizes)
+		}
+		if !reflect.DeepEqual(seeds, want) {
+			t.Errorf("%s: seeds = %+v, want %+v", name, seeds, want)
+		}
+	}
+}
+
+func TestParseServeMapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"unknown key", "registers: 10\n", "line 1: unknown key registers"},
+		{"bad size", "coils: 70000\n", "line 1: invalid coils size: 70000"},
+		{"indented outside seed", "coils: 10\n  ref: 1\n", "line 2: expected seed: or force:"},
+		{"entry without dash", "seed:\n  ref: 1\n", "line 2: expected a seed entry starting with -"},
+		{"missing colon", "seed:\n  - ref 1\n", "line 2: expected KEY: VALUE"},
+		{"unknown seed key", "seed:\n  - name: x\n", "line 2: unknown seed key name"},
+		{"unknown force key", "force:\n  - name: x\n", "line 2: unknown force key name"},
+		{"bad type", "seed:\n  - type: 4:double\n", "line 2: unsupported data type"},
+		{"bad ref", "seed:\n  - ref: -1\n", "line 2: invalid reference: -1"},
+		{"missing ref", "seed:\n  - values: [1]\n", "seed entry needs a ref"},
+		{"invalid value", "seed:\n  - ref: 1\n    values: [abc]\n", "invalid value: abc"},
+		{"template value", "seed:\n  - ref: 1\n    values: ['{{ now.Unix }}']\n", "invalid value"},
+		{"past the end", "holding_registers: 10\nseed:\n  - type: 4:int\n    ref: 9\n    values: [1]\n", "run past the end of the table (10)"},
+		{"overflow", "seed:\n  - ref: 1\n    values: [70000]\n", "overflows a 16-bit register"},
+		{"json syntax", `{"coils": `, "unexpected end of JSON input"},
+		{"json seed", `{"seed": [{"ref": "x"}]}`, "seed 1: invalid reference: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "map.yaml")
+			if err := os.WriteFile(path, []byte(tt.text), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadServeMap(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeSeed(t *testing.T) {
+	sim := loadTestServeMap(t, `
+seed:
+  - type: 4:float
+    ref: 100
+    values: [1.5]
+  - type: 3:int
+    ref: 0
+    values: [-2]
+  - type: 1
+    ref: 4
+    values: [on, 0, true]
+`)
+	if got := sim.holding[100:102]; !reflect.DeepEqual(got, []uint16{0x3fc0, 0}) {
+		t.Errorf("float seed = %#x", got)
+	}
+	if got := sim.input[0:2]; !reflect.DeepEqual(got, []uint16{0xffff, 0xfffe}) {
+		t.Errorf("input register seed = %#x", got)
+	}
+	if got := sim.discrete[4:7]; !reflect.DeepEqual(got, []bool{true, false, true}) {
+		t.Errorf("discrete input seed = %v", got)
+	}
+
+	if _, err := sim.HandleInputRegisters(&modbus.InputRegistersRequest{Addr: 65535, Quantity: 2}); err != modbus.ErrIllegalDataAddress {
+		t.Errorf("a read past the end gave %v, want Illegal Data Address", err)
+	}
+}
+
+func TestServeForceOverSeed(t *testing.T) {
+	// Forced values win over seeded ones whichever comes first in the map;
+	// JSON keys are applied in the same order on every run
+	for _, text := range []string{
+		"force:\n  - ref: 10\n    values: [500]\nseed:\n  - ref: 9\n    values: [1, 2, 3]\n",
+		"seed:\n  - ref: 9\n    values: [1, 2, 3]\nforce:\n  - ref: 10\n    values: [500]\n",
+		`{"force": [{"ref": 10, "values": [500]}], "seed": [{"ref": 9, "values": [1, 2, 3]}]}`,
+		`{"seed": [{"ref": 9, "values": [1, 2, 3]}], "force": [{"ref": 10, "values": [500]}]}`,
+	} {
+		for run := 0; run < 20; run++ {
+			sim := loadTestServeMap(t, text)
+			if got := sim.holding[9:12]; !reflect.DeepEqual(got, []uint16{1, 500, 3}) {
+				t.Fatalf("%s: holding registers = %v, want the forced value", text, got)
+			}
+		}
+	}
+
+	// Of several bad keys, the same one is reported every time
+	for run := 0; run < 20; run++ {
+		path := filepath.Join(t.TempDir(), "map.json")
+		if err := os.WriteFile(path, []byte(`{"zeta": 1, "alpha": 1, "seed": [{"ref": "x", "type": "y"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadServeMap(path); err == nil || !strings.Contains(err.Error(), "unknown key alpha") {
+			t.Fatalf("error = %v, want the alpha key reported", err)
+		}
+		if err := os.WriteFile(path, []byte(`{"seed": [{"type": "y", "ref": "x"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadServeMap(path); err == nil || !strings.Contains(err.Error(), "seed 1: invalid reference: x") {
+			t.Fatalf("error = %v, want the ref reported", err)
+		}
+	}
+}
+
+func TestServeAccessStats(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 200})
+	sim.trackAccess()
+	for i := 0; i < 3; i++ {
+		sim.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{Addr: 100, Quantity: 4})
+	}
+	sim.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{Addr: 101, Quantity: 1, IsWrite: true, Args: []uint16{7}})
+	sim.HandleCoils(&modbus.CoilsRequest{Addr: 0, Quantity: 2})
+	// Outside the table: answered with an exception and not counted
+	sim.HandleInputRegisters(&modbus.InputRegistersRequest{Addr: 10, Quantity: 10})
+
+	var csv strings.Builder
+	if err := sim.writeAccessCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	want := `table,address,reads,writes
+coils,0,1,0
+coils,1,1,0
+holding_registers,100,3,0
+holding_registers,101,3,1
+holding_registers,102,3,0
+holding_registers,103,3,0
+`
+	if csv.String() != want {
+		t.Errorf("CSV report:\n%s\nwant:\n%s", csv.String(), want)
+	}
+
+	var heatmap strings.Builder
+	sim.printHeatmap(&heatmap)
+	lines := strings.Split(strings.TrimSpace(heatmap.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "busiest with 4 accesses") {
+		t.Fatalf("heatmap:\n%s", heatmap.String())
+	}
+	if want := "coils                 0-15    |..              |"; lines[1] != want {
+		t.Errorf("coils row = %q, want %q", lines[1], want)
+	}
+	row := lines[2][strings.Index(lines[2], "|")+1:]
+	if !strings.HasPrefix(lines[2], "holding_registers    64-127") || row[100-64:104-64] != "*@**" {
+		t.Errorf("holding register row = %q", lines[2])
+	}
+}
+
+func TestServeAccessStatsOff(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	sim.HandleHoldingRegisters(&modbus.HoldingRegistersRequest{Addr: 0, Quantity: 4})
+	if sim.access != nil {
+		t.Errorf("accesses were tracked without --stats")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// serveTableNames names the tables of a simulator in access reports, in
+// report order.
+var serveTableNames = []struct{ table, name string }{
+	{"0", "coils"},
+	{"1", "discrete_inputs"},
+	{"3", "input_registers"},
+	{"4", "holding_registers"},
+}
+
+// heatShades are the heatmap characters from untouched to busiest.
+const heatShades = " .:-=+*#%@"
+
+// heatmapWidth is the number of addresses per heatmap row.
+const heatmapWidth = 64
+
+// accessCounts counts the reads and writes of each address of one table.
+type accessCounts struct {
+	reads, writes []uint32
+}
+
+// trackAccess makes the simulator count the accesses to every address, for
+// serve --stats.
+func (sim *simulator) trackAccess() {
+	sim.access = map[string]*accessCounts{
+		"0": {make([]uint32, len(sim.coils)), make([]uint32, len(sim.coils))},
+		"1": {make([]uint32, len(sim.discrete)), make([]uint32, len(sim.discrete))},
+		"3": {make([]uint32, len(sim.input)), make([]uint32, len(sim.input))},
+		"4": {make([]uint32, len(sim.holding)), make([]uint32, len(sim.holding))},
+	}
+}
+
+// countAccess counts a request of quantity values from addr. Requests
+// outside the table aren't counted, as they touch no register.
+func (sim *simulator) countAccess(table string, addr, quantity uint16, write bool) {
+	counts := sim.access[table]
+	if counts == nil || !inRange(addr, quantity, len(counts.reads)) {
+		return
+	}
+	tally := counts.reads
+	if write {
+		tally = counts.writes
+	}
+	for i := int(addr); i < int(addr)+int(quantity); i++ {
+		tally[i]++
+	}
+}
+
+// writeAccessCSV writes a table,address,reads,writes row for every address
+// that was accessed.
+func (sim *simulator) writeAccessCSV(w io.Writer) error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"table", "address", "reads", "writes"})
+	for _, t := range serveTableNames {
+		counts := sim.access[t.table]
+		for addr := range counts.reads {
+			if counts.reads[addr] == 0 && counts.writes[addr] == 0 {
+				continue
+			}
+			out.Write([]string{t.name, strconv.Itoa(addr),
+				strconv.FormatUint(uint64(counts.reads[addr]), 10),
+				strconv.FormatUint(uint64(counts.writes[addr]), 10)})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// printHeatmap prints the accesses of each table as rows of heatmapWidth
+// addresses, one character per address shaded by its reads and writes
+// relative to the busiest address. Rows without accesses are left out.
+func (sim *simulator) printHeatmap(w io.Writer) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	var busiest uint32
+	for _, counts := range sim.access {
+		for addr := range counts.reads {
+			busiest = max(busiest, counts.reads[addr]+counts.writes[addr])
+		}
+	}
+	if busiest == 0 {
+		fmt.Fprintln(w, "No registers were accessed")
+		return
+	}
+
+	fmt.Fprintf(w, "Access heatmap, %d addresses per row (' ' untouched, '%c' busiest with %d accesses):\n",
+		heatmapWidth, heatShades[len(heatShades)-1], busiest)
+	for _, t := range serveTableNames {
+		counts := sim.access[t.table]
+		for start := 0; start < len(counts.reads); start += heatmapWidth {
+			end := min(start+heatmapWidth, len(counts.reads))
+			var row bytes.Buffer
+			touched := false
+			for addr := start; addr < end; addr++ {
+				total := counts.reads[addr] + counts.writes[addr]
+				// Any access shows, however small next to the busiest
+				shade := 0
+				if total == busiest {
+					shade = len(heatShades) - 1
+				} else if total > 0 {
+					shade = 1 + int(uint64(total-1)*uint64(len(heatShades)-2)/uint64(busiest-1))
+				}
+				touched = touched || total > 0
+				row.WriteByte(heatShades[shade])
+			}
+			if touched {
+				fmt.Fprintf(w, "%-17s %5d-%-5d |%s|\n", t.name, start, end-1, row.String())
+			}
+		}
+	}
+}
+
+// saveAccessStats writes the access report to path and prints the heatmap.
+func (sim *simulator) saveAccessStats(path string) error {
+	sim.printHeatmap(os.Stdout)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write access statistics: %v", err)
+	}
+	if err := sim.writeAccessCSV(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write access statistics: %v", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Large devices are described by register lists rather than hand-written
+// serve maps: SunSpec model definitions, and the register lists vendors
+// export from their configuration tools as spreadsheets.
+
+// sunspecModel is a SunSpec model definition in the JSON format the SunSpec
+// Alliance publishes its models in (model_1.json and so on).
+type sunspecModel struct {
+	ID    int          `json:"id"`
+	Group sunspecGroup `json:"group"`
+}
+
+// sunspecGroup is a group of points, possibly with repeating subgroups.
+type sunspecGroup struct {
+	Name   string          `json:"name"`
+	Count  json.RawMessage `json:"count"` // a number, or the name of the point holding it
+	Points []sunspecPoint  `json:"points"`
+	Groups []sunspecGroup  `json:"groups"`
+}
+
+type sunspecPoint struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Size  int         `json:"size"`
+	Value interface{} `json:"value"`
+}
+
+// sunspecMarker is the "SunS" marker that starts the SunSpec register map.
+var sunspecMarker = []uint16{0x5375, 0x6e53}
+
+// loadSunSpec lays the models of the given SunSpec model definition files
+// out in the holding registers from base, as a SunSpec device does: the
+// SunS marker, each model with its ID and length, and the end model. Points
+// without a value read as "not implemented". It returns the last address
+// used.
+func (sim *simulator) loadSunSpec(paths []string, base int) (int, error) {
+	registers := slices.Clone(sunspecMarker)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		var model sunspecModel
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&model); err != nil {
+			return 0, fmt.Errorf("%s: %v", path, err)
+		}
+		words, err := model.encode()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", path, err)
+		}
+		registers = append(registers, words...)
+	}
+	registers = append(registers, 0xffff, 0)
+
+	if base+len(registers) > len(sim.holding) {
+		return 0, fmt.Errorf("the SunSpec models need holding registers %d-%d, beyond the table (%d)",
+			base, base+len(registers)-1, len(sim.holding))
+	}
+	copy(sim.holding[base:], registers)
+	return base + len(registers) - 1, nil
+}
+
+// encode returns the registers of the model, its ID and length included.
+func (m *sunspecModel) encode() ([]uint16, error) {
+	values := make(map[string]string)
+	words, err := m.Group.encode(nil, values)
+	if err != nil {
+		return nil, err
+	}
+	// The first two points are ID and L by definition
+	if len(words) < 2 {
+		return nil, fmt.Errorf("model %d has no ID and L points", m.ID)
+	}
+	if m.ID != 0 {
+		words[0] = uint16(m.ID)
+	}
+	words[1] = uint16(len(words) - 2)
+	return words, nil
+}
+
+// encode appends the registers of the group's points and of its repeated
+// subgroups, noting the point values a later count may refer to.
+func (g *sunspecGroup) encode(words []uint16, values map[string]string) ([]uint16, error) {
+	for _, point := range g.Points {
+		encoded, err := point.encode()
+		if err != nil {
+			return nil, fmt.Errorf("point %s: %v", point.Name, err)
+		}
+		words = append(words, encoded...)
+		if point.Value != nil {
+			values[point.Name] = fmt.Sprint(point.Value)
+		}
+	}
+	for _, sub := range g.Groups {
+		count, err := sub.count(values)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			if words, err = sub.encode(words, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return words, nil
+}
+
+// count returns the number of times the group repeats. A count naming a
+// point without a value gives one instance.
+func (g *sunspecGroup) count(values map[string]string) (int, error) {
+	if len(g.Count) == 0 {
+		return 1, nil
+	}
+	var count interface{}
+	dec := json.NewDecoder(bytes.NewReader(g.Count))
+	dec.UseNumber()
+	if err := dec.Decode(&count); err != nil {
+		return 0, fmt.Errorf("group %s: invalid count: %s", g.Name, g.Count)
+	}
+	text := fmt.Sprint(count)
+	if name, ok := count.(string); ok {
+		if text, ok = values[name]; !ok {
+			return 1, nil
+		}
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > 65535 {
+		return 0, fmt.Errorf("group %s: invalid count: %s", g.Name, text)
+	}
+	return n, nil
+}
+
+// encode returns the registers of the point: its value, or the SunSpec
+// "not implemented" value of its type.
+func (p *sunspecPoint) encode() ([]uint16, error) {
+	if p.Size < 1 {
+		return nil, fmt.Errorf("invalid size %d", p.Size)
+	}
+	words := make([]uint16, p.Size)
+	if p.Value == nil {
+		switch {
+		case strings.HasPrefix(p.Type, "int"), p.Type == "sunssf", p.Type == "pad":
+			words[0] = 0x8000
+		case strings.HasPrefix(p.Type, "uint"), strings.HasPrefix(p.Type, "enum"), strings.HasPrefix(p.Type, "bitfield"):
+			for i := range words {
+				words[i] = 0xffff
+			}
+		case p.Type == "float32" || p.Type == "float64":
+			words[0] = 0x7fc0
+			if p.Type == "float64" {
+				words[0] = 0x7ff8
+			}
+		}
+		return words, nil
+	}
+
+	value := fmt.Sprint(p.Value)
+	var bits uint64
+	switch p.Type {
+	case "string", "ipv6addr", "eui48":
+		if len(value) > 2*p.Size {
+			return nil, fmt.Errorf("%q is longer than %d characters", value, 2*p.Size)
+		}
+		text := make([]byte, 2*p.Size)
+		copy(text, value)
+		for i := range words {
+			words[i] = uint16(text[2*i])<<8 | uint16(text[2*i+1])
+		}
+		return words, nil
+	case "float32":
+		f, err := parseWriteFloat32(value)
+		if err != nil {
+			return nil, err
+		}
+		bits = uint64(math.Float32bits(f))
+	case "float64":
+		r, err := parseWriteNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		f, _ := r.Float64()
+		bits = math.Float64bits(f)
+	default:
+		if p.Size > 4 {
+			return nil, fmt.Errorf("unsupported size %d for type %s", p.Size, p.Type)
+		}
+		var err error
+		if bits, err = parseWriteInt(value, uint(16*p.Size), false); err != nil {
+			return nil, err
+		}
+	}
+	for i := range words {
+		words[i] = uint16(bits >> (16 * (len(words) - 1 - i)))
+	}
+	return words, nil
+}
+
+// registerListColumns are the column names accepted in a register list, by
+// what they hold.
+var registerListColumns = map[string][]string{
+	"ref":   {"address", "addr", "ref", "reference", "register", "start"},
+	"type":  {"type", "data type", "datatype"},
+	"value": {"value", "default", "initial", "initial value"},
+}
+
+// vendorTypes maps the data type names of vendor register lists to the data
+// types of gomodbus, for holding registers and coils.
+var vendorTypes = map[string]string{
+	"uint16": "4", "int16": "4", "word": "4", "int": "4", "uint": "4", "short": "4",
+	"uint32": "4:int", "int32": "4:int", "dword": "4:int", "dint": "4:int", "udint": "4:int",
+	"float": "4:float", "float32": "4:float", "real": "4:float",
+	"bool": "0", "bit": "0", "coil": "0",
+}
+
+// readServeRegisterList reads a register list exported as CSV or as the
+// first sheet of an .xlsx workbook. A header row names the columns: the
+// address, its data type (a gomodbus type such as 3:float, or a common
+// vendor name such as uint32 or real for holding registers) and its value;
+// other columns, like names and units, are ignored, and so are rows without
+// a value. Addresses are protocol addresses, starting at 0.
+func readServeRegisterList(path string) ([]serveSeed, error) {
+	var records [][]string
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		records, err = readXLSXSheet(path, "")
+	} else {
+		records, err = readCSVRecords(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	var seeds []serveSeed
+	for i, record := range records {
+		if len(columns) == 0 {
+			for col, name := range record {
+				name = strings.ToLower(strings.TrimSpace(name))
+				for key, names := range registerListColumns {
+					if slices.Contains(names, name) {
+						columns[key] = col
+					}
+				}
+			}
+			if len(columns) > 0 {
+				if _, ok := columns["ref"]; !ok {
+					return nil, fmt.Errorf("line %d: no address column (%s)", i+1, strings.Join(registerListColumns["ref"], ", "))
+				}
+				if _, ok := columns["value"]; !ok {
+					return nil, fmt.Errorf("line %d: no value column (%s)", i+1, strings.Join(registerListColumns["value"], ", "))
+				}
+			}
+			continue
+		}
+
+		field := func(key string) string {
+			col, ok := columns[key]
+			if !ok || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+		if field("value") == "" {
+			continue
+		}
+		seed := serveSeed{dataType: "4", ref: -1, values: []string{field("value")}}
+		if dataType := field("type"); dataType != "" {
+			if vendor, ok := vendorTypes[strings.ToLower(dataType)]; ok {
+				dataType = vendor
+			}
+			if err := seed.set("type", []string{dataType}); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		if err := seed.set("ref", []string{field("ref")}); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		seeds = append(seeds, seed)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no header row naming the address and value columns")
+	}
+	return seeds, nil
+}
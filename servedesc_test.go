@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCommonModel is a shortened SunSpec common model (1).
+const testCommonModel = `{
+  "id": 1,
+  "group": {
+    "name": "common",
+    "type": "group",
+    "points": [
+      {"name": "ID", "value": 1, "type": "uint16", "size": 1, "mandatory": "M", "static": "S"},
+      {"name": "L", "type": "uint16", "size": 1},
+      {"name": "Mn", "type": "string", "size": 4, "value": "Acme"},
+      {"name": "SN", "type": "string", "size": 2},
+      {"name": "DA", "type": "uint16", "size": 1, "value": 3},
+      {"name": "Pad", "type": "pad", "size": 1}
+    ]
+  }
+}`
+
+// testRepeatingModel has a repeating group counted by one of its points.
+const testRepeatingModel = `{
+  "id": 160,
+  "group": {
+    "name": "mppt",
+    "points": [
+      {"name": "ID", "type": "uint16", "size": 1},
+      {"name": "L", "type": "uint16", "size": 1},
+      {"name": "DCA_SF", "type": "sunssf", "size": 1, "value": -2},
+      {"name": "N", "type": "count", "size": 1, "value": 2},
+      {"name": "Evt", "type": "bitfield32", "size": 2}
+    ],
+    "groups": [{
+      "name": "module",
+      "count": "N",
+      "points": [
+        {"name": "DCA", "type": "uint16", "size": 1, "value": 150},
+        {"name": "DCWH", "type": "acc32", "size": 2, "value": 70000},
+        {"name": "Tmp", "type": "int16", "size": 1},
+        {"name": "W", "type": "float32", "size": 2, "value": 1.5}
+      ]
+    }]
+  }
+}`
+
+func TestLoadSunSpec(t *testing.T) {
+	common := writeTempFile(t, "model_1.json", testCommonModel)
+	mppt := writeTempFile(t, "model_160.json", testRepeatingModel)
+	sim := newSimulator(map[string]int{"0": 0, "1": 0, "3": 0, "4": 65536})
+	end, err := sim.loadSunSpec([]string{common, mppt}, 40000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint16{
+		0x5375, 0x6e53, // SunS
+		1, 8, 0x4163, 0x6d65, 0, 0, 0, 0, 3, 0x8000, // common model
+		160, 16, 0xfffe, 2, 0xffff, 0xffff, // mppt model
+		150, 0x0001, 0x1170, 0x8000, 0x3fc0, 0, // module 1
+		150, 0x0001, 0x1170, 0x8000, 0x3fc0, 0, // module 2
+		0xffff, 0, // end model
+	}
+	if got := sim.holding[40000 : 40000+len(want)]; !reflect.DeepEqual(got, want) {
+		t.Errorf("registers =\n%#x\nwant\n%#x", got, want)
+	}
+	if end != 40000+len(want)-1 {
+		t.Errorf("end = %d, want %d", end, 40000+len(want)-1)
+	}
+}
+
+func TestLoadSunSpecErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  string
+	}{
+		{"syntax", `{"id": 1, "group": `, "unexpected EOF"},
+		{"no points", `{"id": 1, "group": {"name": "x"}}`, "has no ID and L points"},
+		{"bad size", `{"id": 1, "group": {"points": [{"name": "ID", "type": "uint16"}]}}`, "point ID: invalid size 0"},
+		{"string too long", `{"id": 1, "group": {"points": [
+			{"name": "ID", "type": "uint16", "size": 1}, {"name": "L", "type": "uint16", "size": 1},
+			{"name": "Mn", "type": "string", "size": 1, "value": "Acme"}]}}`, `point Mn: "Acme" is longer than 2 characters`},
+		{"overflow", `{"id": 1, "group": {"points": [
+			{"name": "ID", "type": "uint16", "size": 1}, {"name": "L", "type": "uint16", "size": 1},
+			{"name": "DA", "type": "uint16", "size": 1, "value": 70000}]}}`, "point DA: value 70000 overflows a 16-bit register"},
+		{"bad count", `{"id": 1, "group": {"points": [
+			{"name": "ID", "type": "uint16", "size": 1}, {"name": "L", "type": "uint16", "size": 1}],
+			"groups": [{"name": "ch", "count": -1}]}}`, "group ch: invalid count: -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newSimulator(map[string]int{"4": 65536})
+			_, err := sim.loadSunSpec([]string{writeTempFile(t, "model.json", tt.model)}, 40000)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	sim := newSimulator(map[string]int{"4": 40010})
+	if _, err := sim.loadSunSpec([]string{writeTempFile(t, "model_1.json", testCommonModel)}, 40000); err == nil ||
+		!strings.Contains(err.Error(), "beyond the table (40010)") {
+		t.Errorf("models past the end of the table: %v", err)
+	}
+}
+
+func TestReadServeRegisterList(t *testing.T) {
+	path := writeTempFile(t, "registers.csv", `Register export, PLC-1
+Name,Address,Data Type,Unit,Default
+Setpoint,100,REAL,degC,21.5
+Mode,102,uint16,,3
+Counter,103,udint,,4294967295
+Alarm,5,bool,,true
+Level,10,3:int,mm,-2
+Spare,110,uint16,,
+Gain,111,,,7
+`)
+	seeds, err := readServeRegisterList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []serveSeed{
+		{dataType: "4:float", ref: 100, values: []string{"21.5"}},
+		{dataType: "4", ref: 102, values: []string{"3"}},
+		{dataType: "4:int", ref: 103, values: []string{"4294967295"}},
+		{dataType: "0", ref: 5, values: []string{"true"}},
+		{dataType: "3:int", ref: 10, values: []string{"-2"}},
+		{dataType: "4", ref: 111, values: []string{"7"}},
+	}
+	if !reflect.DeepEqual(seeds, want) {
+		t.Errorf("seeds =\n%+v\nwant\n%+v", seeds, want)
+	}
+
+	sim, err := loadServeMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sim.holding[100:106]; !reflect.DeepEqual(got, []uint16{0x41ac, 0, 3, 0xffff, 0xffff, 0}) {
+		t.Errorf("holding registers = %#x", got)
+	}
+	if !sim.coils[5] || sim.input[11] != 0xfffe {
+		t.Errorf("coil %v, input register %#x", sim.coils[5], sim.input[11])
+	}
+}
+
+func TestReadServeRegisterListErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"no header", "100,1\n", "no header row"},
+		{"no address column", "name,value\nx,1\n", "line 1: no address column"},
+		{"no value column", "address,type\n1,4\n", "line 1: no value column"},
+		{"bad address", "address,value\nx,1\n", "line 2: invalid reference: x"},
+		{"bad type", "address,type,value\n1,double,1\n", "line 2: unsupported data type: double"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readServeRegisterList(writeTempFile(t, "registers.csv", tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
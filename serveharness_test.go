@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gomodbus/modbustest"
+)
+
+// Integration tests run gomodbus against the serve simulator, served
+// in-process by modbustest.
+
+// startTestServer serves sim on a free loopback port until the test ends
+// and returns the port. The environment is cleared of gomodbus variables,
+// and the write journal goes to a temporary configuration directory.
+func startTestServer(t *testing.T, sim *simulator) int {
+	t.Helper()
+	clearEnv(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	_, port, err := net.SplitHostPort(modbustest.Start(t, sim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// runTestClient runs gomodbus once against the server on port, as if given
+// the server's address and then args on the command line, and returns its
+// output. A subcommand such as undo stays in front.
+func runTestClient(t *testing.T, port int, args ...string) (string, error) {
+	t.Helper()
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+	os.Args = []string{"gomodbus"}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		os.Args, args = append(os.Args, args[0]), args[1:]
+	}
+	os.Args = append(os.Args, "-1", "-p", strconv.Itoa(port), "127.0.0.1")
+	os.Args = append(os.Args, args...)
+
+	var err error
+	out := captureStdout(t, func() { err = (&ModbusCLI{}).run() })
+	return out, err
+}
+
+// mustRunTestClient is runTestClient for runs that must succeed.
+func mustRunTestClient(t *testing.T, port int, args ...string) string {
+	t.Helper()
+	out, err := runTestClient(t, port, args...)
+	if err != nil {
+		t.Fatalf("gomodbus %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+func TestServeReadWrite(t *testing.T) {
+	sim := loadTestServeMap(t, `
+seed:
+  - type: 4:float
+    ref: 100
+    values: [21.5]
+  - type: 0
+    ref: 3
+    values: [1, 0, 1]
+`)
+	port := startTestServer(t, sim)
+
+	out := mustRunTestClient(t, port, "-t", "4:float", "-r", "100")
+	if !strings.Contains(out, "[100]: 21.5") {
+		t.Errorf("float read:\n%s", out)
+	}
+	out = mustRunTestClient(t, port, "-t", "0", "-r", "3", "-c", "3")
+	for _, want := range []string{"[3]: 1", "[4]: 0", "[5]: 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("coil read lacks %q:\n%s", want, out)
+		}
+	}
+
+	mustRunTestClient(t, port, "-t", "4:int", "-r", "200", "--values", "-2", "70000")
+	if got := sim.holding[200:204]; !reflect.DeepEqual(got, []uint16{0xffff, 0xfffe, 0x0001, 0x1170}) {
+		t.Errorf("holding registers after the write = %#x", got)
+	}
+	mustRunTestClient(t, port, "-t", "0", "-r", "8", "--values", "1", "1")
+	if !sim.coils[8] || !sim.coils[9] {
+		t.Errorf("coils after the write = %v", sim.coils[8:10])
+	}
+}
+
+func TestServeForcedWrite(t *testing.T) {
+	sim := loadTestServeMap(t, "force:\n  - ref: 10\n    values: [500]\n")
+	port := startTestServer(t, sim)
+	mustRunTestClient(t, port, "-t", "4", "-r", "9", "--values", "1", "2", "3")
+	if got := sim.holding[9:12]; !reflect.DeepEqual(got, []uint16{1, 500, 3}) {
+		t.Errorf("holding registers = %v, want the forced value kept", got)
+	}
+}
+
+func TestServeIllegalAddress(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	port := startTestServer(t, sim)
+	_, err := runTestClient(t, port, "-t", "4", "-r", "10", "-c", "10")
+	if err == nil || !strings.Contains(err.Error(), "illegal data address") {
+		t.Errorf("read past the end of the table: %v", err)
+	}
+}
+
+func TestServeUndo(t *testing.T) {
+	sim := newSimulator(map[string]int{"0": 16, "1": 16, "3": 16, "4": 16})
+	sim.holding[5] = 42
+	port := startTestServer(t, sim)
+
+	mustRunTestClient(t, port, "-r", "5", "--values", "7")
+	if sim.holding[5] != 7 {
+		t.Fatalf("holding register 5 = %d after the write", sim.holding[5])
+	}
+	mustRunTestClient(t, port, "undo", "--last")
+	if sim.holding[5] != 42 {
+		t.Errorf("holding register 5 = %d after the undo, want 42", sim.holding[5])
+	}
+}
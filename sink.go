@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// sink is an output destination subscribed to the event bus. Send may block
+// for as long as the destination needs; the bus keeps that from stalling
+// polling. When a destination is still busy at shutdown, Close is called
+// while Send runs, so the two must be safe to call concurrently, and Close
+// should make a blocked Send return.
+type sink interface {
+	Name() string
+	Send(ev busEvent) error
+	Close()
+}
+
+// sinkCloseTimeout bounds how long closing the bus waits for a sink that
+// was still sending when the drain timeout expired.
+const sinkCloseTimeout = time.Second
+
+// Queue policies applied when a sink falls behind.
+const (
+	policyDropOldest = "drop-oldest"
+	policyDropNewest = "drop-newest"
+	policyBlock      = "block"
+)
+
+// eventBus fans the events of polling (samples read, comm and alarm state
+// changes) out to every sink through a bounded queue per sink, so one slow
+// or unreachable sink can't hold up polling or the others.
+type eventBus struct {
+	workers []*sinkWorker
+}
+
+type sinkWorker struct {
+	sink    sink
+	policy  string
+	queue   chan busEvent
+	done    chan struct{}
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func newEventBus(sinks []sink, queueSize int, policy string) *eventBus {
+	p := &eventBus{}
+	for _, s := range sinks {
+		w := &sinkWorker{
+			sink:   s,
+			policy: policy,
+			queue:  make(chan busEvent, queueSize),
+			done:   make(chan struct{}),
+		}
+		go w.run()
+		p.workers = append(p.workers, w)
+	}
+	return p
+}
+
+// Publish queues ev for every sink, applying the queue policy to sinks
+// whose queue is full.
+func (p *eventBus) Publish(ev busEvent) {
+	for _, w := range p.workers {
+		w.enqueue(ev)
+	}
+}
+
+// Close waits up to drainTimeout for queued events to be delivered, closes
+// the sinks and reports any events that were lost along the way. A sink
+// still sending is closed under its worker, which is then given up to
+// sinkCloseTimeout to stop; one that doesn't is abandoned.
+func (p *eventBus) Close(drainTimeout time.Duration) {
+	for _, w := range p.workers {
+		close(w.queue)
+	}
+
+	deadline := time.NewTimer(drainTimeout)
+	defer deadline.Stop()
+	expired := false
+	for _, w := range p.workers {
+		if expired {
+			break
+		}
+		select {
+		case <-w.done:
+		case <-deadline.C:
+			expired = true
+		}
+	}
+
+	for _, w := range p.workers {
+		select {
+		case <-w.done:
+			w.sink.Close()
+		default:
+			go w.sink.Close()
+			stuck := time.NewTimer(sinkCloseTimeout)
+			select {
+			case <-w.done:
+			case <-stuck.C:
+			}
+			stuck.Stop()
+		}
+
+		dropped := w.dropped.Load()
+		select {
+		case <-w.done:
+		default:
+			// Whatever is still queued will never be delivered
+			dropped += uint64(len(w.queue))
+		}
+		if failed := w.failed.Load(); dropped > 0 || failed > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d event(s) dropped, %d failed\n",
+				w.sink.Name(), dropped, failed)
+		}
+	}
+}
+
+func (w *sinkWorker) enqueue(ev busEvent) {
+	switch w.policy {
+	case policyBlock:
+		w.queue <- ev
+
+	case policyDropNewest:
+		select {
+		case w.queue <- ev:
+		default:
+			w.dropped.Add(1)
+		}
+
+	default:
+		// Evict the oldest events until there is room for the new one
+		for {
+			select {
+			case w.queue <- ev:
+				return
+			default:
+			}
+			select {
+			case <-w.queue:
+				w.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.done)
+
+	for ev := range w.queue {
+		if err := w.sink.Send(ev); err != nil {
+			w.failed.Add(1)
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", w.sink.Name(), err)
+		}
+	}
+}
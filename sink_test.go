@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink blocks every Send until it is closed, like a destination
+// that stopped accepting data.
+type blockingSink struct {
+	once    sync.Once
+	closed  chan struct{}
+	mu      sync.Mutex
+	sent    int
+	sending bool
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{closed: make(chan struct{})}
+}
+
+func (s *blockingSink) Name() string { return "blocking" }
+
+func (s *blockingSink) Send(ev busEvent) error {
+	s.mu.Lock()
+	s.sending = true
+	s.mu.Unlock()
+	<-s.closed
+	s.mu.Lock()
+	s.sending = false
+	s.sent++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingSink) Close() {
+	s.once.Do(func() { close(s.closed) })
+}
+
+// stuckSink never returns from Send.
+type stuckSink struct{ closed chan struct{} }
+
+func (s *stuckSink) Name() string           { return "stuck" }
+func (s *stuckSink) Send(ev busEvent) error { select {} }
+func (s *stuckSink) Close()                 { close(s.closed) }
+
+func TestEventBusCloseWaitsForSenders(t *testing.T) {
+	blocking := newBlockingSink()
+	stuck := &stuckSink{closed: make(chan struct{})}
+	bus := newEventBus([]sink{blocking, stuck}, 4, policyDropOldest)
+	bus.Publish(busEvent{sample: &pollSample{}})
+	bus.Publish(busEvent{sample: &pollSample{}})
+
+	started := time.Now()
+	bus.Close(50 * time.Millisecond)
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond+sinkCloseTimeout+500*time.Millisecond {
+		t.Errorf("Close took %v with a stuck sink", elapsed)
+	}
+
+	// Closing unblocked the sink, and its worker finished before Close
+	// returned, so nothing of it runs on
+	blocking.mu.Lock()
+	defer blocking.mu.Unlock()
+	if blocking.sending || blocking.sent != 2 {
+		t.Errorf("blocking sink: sending %v, %d sent", blocking.sending, blocking.sent)
+	}
+	select {
+	case <-stuck.closed:
+	default:
+		t.Error("the stuck sink wasn't closed")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+
+	"github.com/simonvetter/modbus"
+)
+
+// errorLabels names the device and link errors tallied in the poll summary.
+var errorLabels = map[modbus.Error]string{
+	modbus.ErrIllegalFunction:         "Illegal Function (0x01)",
+	modbus.ErrIllegalDataAddress:      "Illegal Data Address (0x02)",
+	modbus.ErrIllegalDataValue:        "Illegal Data Value (0x03)",
+	modbus.ErrServerDeviceFailure:     "Server Device Failure (0x04)",
+	modbus.ErrAcknowledge:             "Acknowledge (0x05)",
+	modbus.ErrServerDeviceBusy:        "Server Device Busy (0x06)",
+	modbus.ErrMemoryParityError:       "Memory Parity Error (0x08)",
+	modbus.ErrGWPathUnavailable:       "Gateway Path Unavailable (0x0A)",
+	modbus.ErrGWTargetFailedToRespond: "Gateway Target Failed To Respond (0x0B)",
+	modbus.ErrRequestTimedOut:         "Timeout",
+	modbus.ErrBadCRC:                  "Bad CRC",
+	modbus.ErrShortFrame:              "Short Frame",
+	modbus.ErrProtocolError:           "Protocol Error",
+	modbus.ErrBadUnitId:               "Bad Unit ID",
+	modbus.ErrBadTransactionId:        "Bad Transaction ID",
+	modbus.ErrUnknownProtocolId:       "Unknown Protocol ID",
+}
+
+// isExceptionResponse reports whether err is a Modbus exception sent back by
+// the device, as opposed to a timeout or framing problem.
+func isExceptionResponse(err error) bool {
+	var mbErr modbus.Error
+	if !errors.As(err, &mbErr) {
+		return false
+	}
+
+	switch mbErr {
+	case modbus.ErrIllegalFunction, modbus.ErrIllegalDataAddress, modbus.ErrIllegalDataValue,
+		modbus.ErrServerDeviceFailure, modbus.ErrAcknowledge, modbus.ErrServerDeviceBusy,
+		modbus.ErrMemoryParityError, modbus.ErrGWPathUnavailable, modbus.ErrGWTargetFailedToRespond:
+		return true
+	}
+	return false
+}
+
+// classifyError returns the summary label for a device or link error. ok is
+// false for errors that don't come from talking to the device (bad
+// configuration, failing decoders, ...), which should end polling.
+func classifyError(err error) (label string, ok bool) {
+	var mbErr modbus.Error
+	if errors.As(err, &mbErr) {
+		if label, found := errorLabels[mbErr]; found {
+			return label, true
+		}
+		return string(mbErr), true
+	}
+
+	if errors.Is(err, errUnstableRead) {
+		return "Unstable Read", true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "Timeout", true
+		}
+		return "Network Error", true
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "Connection Closed", true
+	}
+
+	return "", false
+}
+
+// pollStats tallies the outcome of every poll in a session.
+type pollStats struct {
+	requests int
+	failures map[string]int
+}
+
+func newPollStats() *pollStats {
+	return &pollStats{failures: make(map[string]int)}
+}
+
+// record counts one poll; err is nil for a successful one.
+func (s *pollStats) record(err error) {
+	s.requests++
+	if err == nil {
+		return
+	}
+
+	label, ok := classifyError(err)
+	if !ok {
+		label = "Other"
+	}
+	s.failures[label]++
+}
+
+// print writes the session summary with a per-error histogram.
+func (s *pollStats) print(w io.Writer) {
+	failed := 0
+	labels := make([]string, 0, len(s.failures))
+	for label, count := range s.failures {
+		failed += count
+		labels = append(labels, label)
+	}
+
+	// Most frequent errors first
+	sort.Slice(labels, func(i, j int) bool {
+		if s.failures[labels[i]] != s.failures[labels[j]] {
+			return s.failures[labels[i]] > s.failures[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+
+	rate := 0.0
+	if s.requests > 0 {
+		rate = float64(failed) * 100 / float64(s.requests)
+	}
+
+	fmt.Fprintf(w, "--- Poll summary: %d request(s), %d ok, %d failed (%.1f%%) ---\n",
+		s.requests, s.requests-failed, failed, rate)
+	for _, label := range labels {
+		fmt.Fprintf(w, "  %-40s %d\n", label+":", s.failures[label])
+	}
+}
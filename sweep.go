@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// maxSweepHosts caps the number of addresses a single CIDR sweep expands to.
+const maxSweepHosts = 65536
+
+// runSweep probes every target from --targets-file and --sweep for a Modbus
+// TCP device, at no more than --sweep-rate probes per second.
+func (m *ModbusCLI) runSweep() error {
+	var targets []string
+	if m.config.TargetsFile != "" {
+		fileTargets, err := readTargetsFile(m.config.TargetsFile, m.config.Port)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, fileTargets...)
+	}
+	for _, spec := range m.config.Sweeps {
+		hosts, err := expandSweep(spec, m.config.Port)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, hosts...)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets to probe")
+	}
+
+	fmt.Printf("Probing %d target(s) for unit %d at up to %d probe(s)/s...\n",
+		len(targets), m.config.SlaveID, m.config.SweepRate)
+
+	ticker := time.NewTicker(time.Second / time.Duration(m.config.SweepRate))
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex // serializes output and the live counter
+	live := 0
+
+	for i, target := range targets {
+		if i > 0 {
+			<-ticker.C
+		}
+
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+
+			detail, ok := m.probeTarget(target)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				live++
+				fmt.Printf("%-40s live (%s)\n", target, detail)
+			} else if m.config.Verbose {
+				fmt.Printf("%-40s %s\n", target, detail)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	fmt.Printf("%d of %d target(s) answered\n", live, len(targets))
+	return nil
+}
+
+// probeTarget issues the probe read against target and describes the outcome.
+// ok is true when a Modbus device answered, even with an exception.
+func (m *ModbusCLI) probeTarget(target string) (detail string, ok bool) {
+	// Check the port with our own timeout first: the client dials with a
+	// fixed 5 second timeout, which would make sweeping dead hosts slow
+	conn, err := net.DialTimeout("tcp", target, m.config.dialTimeout())
+	if err != nil {
+		return fmt.Sprintf("no connection (%v)", err), false
+	}
+	conn.Close()
+
+	client, err := modbus.NewClient(&modbus.ClientConfiguration{
+		URL:     "tcp://" + target,
+		Timeout: m.config.Timeout,
+	})
+	if err != nil {
+		return fmt.Sprintf("client error (%v)", err), false
+	}
+	if err := client.Open(); err != nil {
+		return fmt.Sprintf("no connection (%v)", err), false
+	}
+	defer client.Close()
+	client.SetUnitId(uint8(m.config.SlaveID))
+
+	start := time.Now()
+	err = probeRead(client, m.config.DataType, m.startRef())
+	elapsed := float64(time.Since(start).Microseconds()) / 1000
+
+	switch {
+	case err == nil:
+		return fmt.Sprintf("%.1f ms", elapsed), true
+	case isExceptionResponse(err):
+		return fmt.Sprintf("exception: %v, %.1f ms", err, elapsed), true
+	default:
+		return fmt.Sprintf("port open, no Modbus response (%v)", err), false
+	}
+}
+
+// readTargetsFile reads one HOST[:PORT] or CIDR[:PORT] per line; blank lines
+// and lines starting with # are ignored.
+func readTargetsFile(path string, defaultPort int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open targets file: %v", err)
+	}
+	defer file.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.Contains(line, "/") {
+			hosts, err := expandSweep(line, defaultPort)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+			targets = append(targets, hosts...)
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(line); err != nil {
+			line = net.JoinHostPort(strings.Trim(line, "[]"), strconv.Itoa(defaultPort))
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %v", err)
+	}
+
+	return targets, nil
+}
+
+// expandSweep expands CIDR[:PORT] (e.g. 192.168.10.0/24:502 or fd00::/120)
+// into host:port targets. IPv4 network and broadcast addresses are skipped.
+func expandSweep(spec string, defaultPort int) ([]string, error) {
+	cidr, port := spec, defaultPort
+	if slash := strings.Index(spec, "/"); slash >= 0 {
+		if colon := strings.Index(spec[slash:], ":"); colon >= 0 {
+			p, err := strconv.Atoi(spec[slash+colon+1:])
+			if err != nil || p < 1 || p > 65535 {
+				return nil, fmt.Errorf("invalid port in sweep %q", spec)
+			}
+			cidr, port = spec[:slash+colon], p
+		}
+	}
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sweep %q: %v", spec, err)
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("sweep %q is too large (at most %d addresses)", spec, maxSweepHosts)
+	}
+
+	var targets []string
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		targets = append(targets, net.JoinHostPort(addr.String(), strconv.Itoa(port)))
+		if !addr.Next().IsValid() {
+			break
+		}
+	}
+
+	if prefix.Addr().Is4() && hostBits >= 2 {
+		targets = targets[1 : len(targets)-1]
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of a test case.
+const (
+	testPass  = "pass"
+	testFail  = "fail"  // the device answered, with the wrong values
+	testError = "error" // the test couldn't be carried out
+	testSkip  = "skip"
+)
+
+// testCase is one test, check or assertion in a JUnit or TAP report.
+type testCase struct {
+	name     string
+	duration time.Duration
+	outcome  string
+	message  string
+}
+
+// testSuite collects the outcomes of a verify-device, selftest or --expect
+// run for --junit and --tap, so they show up in CI test reporting.
+type testSuite struct {
+	name    string
+	started time.Time
+	cases   []testCase
+}
+
+func newTestSuite(name string) *testSuite {
+	return &testSuite{name: name, started: time.Now()}
+}
+
+func (s *testSuite) add(name string, duration time.Duration, outcome, message string) {
+	s.cases = append(s.cases, testCase{name, duration, outcome, message})
+}
+
+// testReports reports whether --junit or --tap asks for test reports.
+func (c *Config) testReports() bool {
+	return c.JUnit != "" || c.TAP != ""
+}
+
+// writeTestReports writes the suite to the --junit and --tap files.
+func (m *ModbusCLI) writeTestReports(suite *testSuite) error {
+	if m.config.JUnit != "" {
+		if err := writeJUnit(m.config.JUnit, suite); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %v", err)
+		}
+	}
+	if m.config.TAP != "" {
+		if err := writeTAP(m.config.TAP, suite); err != nil {
+			return fmt.Errorf("failed to write TAP report: %v", err)
+		}
+	}
+	return nil
+}
+
+// finishTests writes the test reports of an --expect run once it ends with
+// err. A run that failed before evaluating anything, e.g. because the device
+// didn't answer, is reported as an error.
+func (m *ModbusCLI) finishTests(err error) {
+	if len(m.tests.cases) == 0 && err != nil {
+		m.tests.add(m.config.Expect.src, time.Since(m.tests.started), testError, localizeError(err))
+	}
+	if werr := m.writeTestReports(m.tests); werr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", werr)
+	}
+}
+
+// JUnit XML, in the form CI servers (Jenkins, GitLab, GitHub Actions
+// reporters) read.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the suite as JUnit XML.
+func writeJUnit(path string, suite *testSuite) error {
+	js := junitSuite{
+		Name:      suite.name,
+		Tests:     len(suite.cases),
+		Time:      junitSeconds(time.Since(suite.started)),
+		Timestamp: suite.started.Format("2006-01-02T15:04:05"),
+	}
+	for _, tc := range suite.cases {
+		jc := junitCase{Name: tc.name, ClassName: suite.name, Time: junitSeconds(tc.duration)}
+		problem := &junitProblem{Message: tc.message}
+		switch tc.outcome {
+		case testFail:
+			jc.Failure = problem
+			js.Failures++
+		case testError:
+			jc.Error = problem
+			js.Errors++
+		case testSkip:
+			jc.Skipped = problem
+			js.Skipped++
+		}
+		js.Cases = append(js.Cases, jc)
+	}
+
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{js}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// writeTAP writes the suite in the Test Anything Protocol (version 13), with
+// the message of a failed test in a YAML block.
+func writeTAP(path string, suite *testSuite) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(suite.cases))
+	for i, tc := range suite.cases {
+		switch tc.outcome {
+		case testPass:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, tapEscape(tc.name))
+		case testSkip:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", i+1, tapEscape(tc.name), tc.message)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, tapEscape(tc.name))
+			fmt.Fprintf(&b, "  ---\n  message: %s\n  severity: %s\n  ...\n", strconv.Quote(tc.message), tc.outcome)
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// tapEscape escapes the # that would start a directive in a test name.
+func tapEscape(name string) string {
+	return strings.ReplaceAll(name, "#", "\\#")
+}
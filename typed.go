@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// valueKind is the type of a value decoded from registers.
+type valueKind int
+
+const (
+	kindUint16 valueKind = iota
+	kindInt32
+	kindFloat32
+	kindFixed16 // signed fixed-point, Bits holds the raw register
+	kindFixed32
+)
+
+// typedValue is one value decoded from a block of registers. The raw bits
+// are kept rather than an interface{} so decoding a block doesn't allocate.
+type typedValue struct {
+	Offset  int // offset of its first register within the block
+	Kind    valueKind
+	Bits    uint32 // the register itself for kindUint16
+	Partial bool   // a 32-bit value whose second register wasn't read
+}
+
+// Int32 returns the value of a kindInt32 value.
+func (v typedValue) Int32() int32 {
+	return int32(v.Bits)
+}
+
+// Float32 returns the value of a kindFloat32 value.
+func (v typedValue) Float32() float32 {
+	return math.Float32frombits(v.Bits)
+}
+
+// decodeRegisters splits a block of registers into values of dataType,
+// appending them to dst[:0] so a poll loop can reuse one buffer. 32-bit
+// types consume two registers per value in the configured word order; a
+// trailing odd register is returned raw and marked Partial.
+func decodeRegisters(dst []typedValue, registers []uint16, dataType string, bigEndian bool) []typedValue {
+	values := dst[:0]
+
+	kind := kindUint16
+	if _, suffix, ok := strings.Cut(dataType, ":"); ok {
+		switch suffix {
+		case "int":
+			kind = kindInt32
+		case "float":
+			kind = kindFloat32
+		case "fixed":
+			kind = kindFixed16
+		case "fixed32":
+			kind = kindFixed32
+		}
+	}
+
+	if kind == kindUint16 || kind == kindFixed16 {
+		for i, reg := range registers {
+			values = append(values, typedValue{Offset: i, Kind: kind, Bits: uint32(reg)})
+		}
+		return values
+	}
+
+	for i := 0; i < len(registers); i += 2 {
+		if i+1 == len(registers) {
+			values = append(values, typedValue{Offset: i, Bits: uint32(registers[i]), Partial: true})
+			break
+		}
+		values = append(values, typedValue{
+			Offset: i,
+			Kind:   kind,
+			Bits:   joinWords(registers[i], registers[i+1], bigEndian),
+		})
+	}
+	return values
+}
+
+// joinWords combines two consecutive registers into a 32-bit word. With
+// bigEndian the first register holds the high word.
+func joinWords(first, second uint16, bigEndian bool) uint32 {
+	if bigEndian {
+		return uint32(first)<<16 | uint32(second)
+	}
+	return uint32(second)<<16 | uint32(first)
+}
+
+// splitWords is the inverse of joinWords: the two registers holding v in the
+// configured word order.
+func splitWords(v uint32, bigEndian bool) (first, second uint16) {
+	if bigEndian {
+		return uint16(v >> 16), uint16(v)
+	}
+	return uint16(v), uint16(v >> 16)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRegisters(t *testing.T) {
+	pi := math.Float32bits(3.14159)
+	tests := []struct {
+		name      string
+		registers []uint16
+		dataType  string
+		bigEndian bool
+		want      []typedValue
+	}{
+		{
+			name:      "16-bit holding registers",
+			registers: []uint16{1, 0xffff, 42},
+			dataType:  "4",
+			bigEndian: true,
+			want: []typedValue{
+				{Offset: 0, Kind: kindUint16, Bits: 1},
+				{Offset: 1, Kind: kindUint16, Bits: 0xffff},
+				{Offset: 2, Kind: kindUint16, Bits: 42},
+			},
+		},
+		{
+			name:      "hex input registers",
+			registers: []uint16{0xbeef},
+			dataType:  "3:hex",
+			bigEndian: true,
+			want:      []typedValue{{Offset: 0, Kind: kindUint16, Bits: 0xbeef}},
+		},
+		{
+			name:      "16-bit fixed-point keeps one register per value",
+			registers: []uint16{0xfff6, 250},
+			dataType:  "4:fixed",
+			bigEndian: true,
+			want: []typedValue{
+				{Offset: 0, Kind: kindFixed16, Bits: 0xfff6},
+				{Offset: 1, Kind: kindFixed16, Bits: 250},
+			},
+		},
+		{
+			name:      "int32 high word first",
+			registers: []uint16{0x0001, 0x0002, 0xffff, 0xfffe},
+			dataType:  "4:int",
+			bigEndian: true,
+			want: []typedValue{
+				{Offset: 0, Kind: kindInt32, Bits: 0x00010002},
+				{Offset: 2, Kind: kindInt32, Bits: 0xfffffffe},
+			},
+		},
+		{
+			name:      "int32 low word first",
+			registers: []uint16{0x0002, 0x0001},
+			dataType:  "3:int",
+			bigEndian: false,
+			want:      []typedValue{{Offset: 0, Kind: kindInt32, Bits: 0x00010002}},
+		},
+		{
+			name:      "float32 high word first",
+			registers: []uint16{uint16(pi >> 16), uint16(pi)},
+			dataType:  "4:float",
+			bigEndian: true,
+			want:      []typedValue{{Offset: 0, Kind: kindFloat32, Bits: pi}},
+		},
+		{
+			name:      "float32 low word first",
+			registers: []uint16{uint16(pi), uint16(pi >> 16)},
+			dataType:  "4:float",
+			bigEndian: false,
+			want:      []typedValue{{Offset: 0, Kind: kindFloat32, Bits: pi}},
+		},
+		{
+			name:      "32-bit fixed-point strides by two",
+			registers: []uint16{0, 100, 0xffff, 0xff9c, 0, 7},
+			dataType:  "4:fixed32",
+			bigEndian: true,
+			want: []typedValue{
+				{Offset: 0, Kind: kindFixed32, Bits: 100},
+				{Offset: 2, Kind: kindFixed32, Bits: 0xffffff9c},
+				{Offset: 4, Kind: kindFixed32, Bits: 7},
+			},
+		},
+		{
+			name:      "trailing odd register is partial",
+			registers: []uint16{0, 1, 0x1234},
+			dataType:  "4:int",
+			bigEndian: true,
+			want: []typedValue{
+				{Offset: 0, Kind: kindInt32, Bits: 1},
+				{Offset: 2, Kind: kindUint16, Bits: 0x1234, Partial: true},
+			},
+		},
+		{
+			name:      "single register of a 32-bit type",
+			registers: []uint16{0x4049},
+			dataType:  "4:float",
+			bigEndian: true,
+			want:      []typedValue{{Offset: 0, Kind: kindUint16, Bits: 0x4049, Partial: true}},
+		},
+		{
+			name:      "no registers",
+			registers: nil,
+			dataType:  "4:int",
+			bigEndian: true,
+			want:      []typedValue{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeRegisters(nil, tt.registers, tt.dataType, tt.bigEndian)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRegisters(%v, %q) = %+v, want %+v", tt.registers, tt.dataType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRegistersReusesBuffer(t *testing.T) {
+	buf := decodeRegisters(nil, []uint16{1, 2, 3, 4}, "4", true)
+	got := decodeRegisters(buf, []uint16{0, 5}, "4:int", true)
+	if len(got) != 1 || got[0].Bits != 5 || got[0].Kind != kindInt32 {
+		t.Fatalf("decoded %+v, want one int32 of 5", got)
+	}
+	if &got[0] != &buf[0] {
+		t.Errorf("decodeRegisters allocated instead of reusing dst")
+	}
+}
+
+func TestTypedValueAccessors(t *testing.T) {
+	v := typedValue{Kind: kindInt32, Bits: 0xfffffffe}
+	if v.Int32() != -2 {
+		t.Errorf("Int32() = %d, want -2", v.Int32())
+	}
+	v = typedValue{Kind: kindFloat32, Bits: math.Float32bits(-1.5)}
+	if v.Float32() != -1.5 {
+		t.Errorf("Float32() = %v, want -1.5", v.Float32())
+	}
+}
+
+func TestSplitWordsInvertsJoinWords(t *testing.T) {
+	for _, bigEndian := range []bool{true, false} {
+		for _, v := range []uint32{0, 1, 0x12345678, 0xffff0000, math.MaxUint32} {
+			first, second := splitWords(v, bigEndian)
+			if got := joinWords(first, second, bigEndian); got != v {
+				t.Errorf("joinWords(splitWords(%#x, %v)) = %#x", v, bigEndian, got)
+			}
+		}
+	}
+	if first, second := splitWords(0x12345678, true); first != 0x1234 || second != 0x5678 {
+		t.Errorf("big-endian split gave %#x %#x", first, second)
+	}
+	if first, second := splitWords(0x12345678, false); first != 0x5678 || second != 0x1234 {
+		t.Errorf("little-endian split gave %#x %#x", first, second)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// withUmask runs fn; there is no creation mask outside Unix.
+func withUmask(mask int, fn func() error) error {
+	return fn()
+}
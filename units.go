@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSlaveList parses the -a option: one slave address or a comma
+// separated list of addresses polled in turn.
+func parseSlaveList(spec string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(spec, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid slave address: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseLabels parses a --label list such as "boiler1=1,boiler2=2" into the
+// label of each unit.
+func parseLabels(spec string, labels map[int]string) (map[int]string, error) {
+	if labels == nil {
+		labels = make(map[int]string)
+	}
+	for _, part := range strings.Split(spec, ",") {
+		name, unitStr, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		unit, err := strconv.Atoi(strings.TrimSpace(unitStr))
+		if !ok || err != nil || !isLabel(name) {
+			return nil, fmt.Errorf("invalid label %q (expected NAME=UNIT with NAME of letters, digits, '_', '-' and '.')", part)
+		}
+		labels[unit] = name
+	}
+	return labels, nil
+}
+
+// isLabel reports whether name can label a unit. Labels prefix output lines,
+// so they are kept to characters that need no quoting.
+func isLabel(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i], false) && name[i] != '-' && name[i] != '.' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// units returns the slave addresses to poll.
+func (c *Config) units() []int {
+	if len(c.SlaveIDs) > 0 {
+		return c.SlaveIDs
+	}
+	return []int{c.SlaveID}
+}
+
+// unitTag returns the tag marking the output of unit: its --label, or
+// "unitN" when several units are polled, or nothing.
+func (c *Config) unitTag(unit int) string {
+	if label, ok := c.Labels[unit]; ok {
+		return label
+	}
+	if len(c.SlaveIDs) > 1 {
+		return "unit" + strconv.Itoa(unit)
+	}
+	return ""
+}
+
+// selectUnit addresses the following requests to unit and tags their output
+// with its label.
+func (m *ModbusCLI) selectUnit(unit int) error {
+	m.config.SlaveID = unit
+	m.tag = m.config.unitTag(unit)
+	return m.client.SetUnitId(uint8(unit))
+}
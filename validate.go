@@ -0,0 +1,550 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// configCheck collects the problems and warnings found while validating a
+// configuration, so they can all be reported at once.
+type configCheck struct {
+	problems []string
+	warnings []string
+}
+
+func (c *configCheck) fail(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(tr(format), args...))
+}
+
+func (c *configCheck) warn(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(tr(format), args...))
+}
+
+// configError lists every problem found in a configuration.
+type configError []string
+
+func (e configError) Error() string {
+	if len(e) == 1 {
+		return e[0]
+	}
+	return fmt.Sprintf(tr("%d configuration problems:")+"\n  - %s", len(e), strings.Join(e, "\n  - "))
+}
+
+// networkModes are the modes that talk to a HOST rather than a serial DEVICE.
+var networkModes = map[string]bool{
+	"tcp":        true,
+	"tls":        true,
+	"udp":        true,
+	"rtuovertcp": true,
+	"rtuoverudp": true,
+}
+
+// Maximum quantities per request, from the Modbus application protocol
+// specification.
+const (
+	maxReadBits       = 2000 // FC01, FC02
+	maxReadRegisters  = 125  // FC03, FC04
+	maxWriteBits      = 1968 // FC15
+	maxWriteRegisters = 123  // FC16
+)
+
+// validateConfig checks config as a whole, printing warnings for settings
+// that will be ignored and returning every problem found in one error.
+func (m *ModbusCLI) validateConfig(config *Config) error {
+	c := &configCheck{}
+	c.checkTarget(config)
+	c.checkSerial(config)
+	c.checkData(config)
+	c.checkModes(config)
+	c.checkOutput(config)
+	c.checkTiming(config)
+
+	for _, warning := range c.warnings {
+		fmt.Fprintf(os.Stderr, tr("Warning: %s\n"), warning)
+	}
+	if len(c.problems) > 0 {
+		return configError(c.problems)
+	}
+	return nil
+}
+
+// checkTarget validates the mode and that it has the device or host it needs.
+func (c *configCheck) checkTarget(config *Config) {
+	if config.Mode != "rtu" && !networkModes[config.Mode] {
+		c.fail("unsupported mode: %s (supported: tcp, tls, udp, rtu, rtuovertcp, rtuoverudp)", config.Mode)
+		return
+	}
+
+	switch {
+	case config.ProxyListen != "":
+		if config.Upstream == "" {
+			c.fail("proxy mode requires --upstream HOST[:PORT]")
+		}
+	case config.TargetsFile != "" || len(config.Sweeps) > 0:
+		if config.Mode != "tcp" {
+			c.fail("--targets-file and --sweep require tcp mode")
+		}
+	case config.Host == "" && config.Device == "":
+		c.fail("device or host parameter missing ! Try -h for help")
+	}
+
+	// Validate port
+	if config.Port < 1 || config.Port > 65535 {
+		c.fail("port must be between 1 and 65535")
+	}
+	if config.Mode == "rtu" && config.Port != defaultConfig().Port {
+		c.warn("--port is ignored in rtu mode")
+	}
+}
+
+// checkSerial validates the serial line settings, warning when they are set
+// in a mode that doesn't use them.
+func (c *configCheck) checkSerial(config *Config) {
+	// Validate baudrate range
+	if config.Baudrate < 1200 || config.Baudrate > 921600 {
+		c.fail("baudrate must be between 1200 and 921600")
+	}
+
+	// Validate databits
+	if config.Databits != 7 && config.Databits != 8 {
+		c.fail("databits must be 7 or 8")
+	}
+
+	// Validate stopbits
+	if config.Stopbits != 1 && config.Stopbits != 2 {
+		c.fail("stopbits must be 1 or 2")
+	}
+
+	// Validate parity
+	validParities := map[string]bool{
+		"none": true,
+		"even": true,
+		"odd":  true,
+	}
+	if !validParities[config.Parity] {
+		c.fail("parity must be none, even, or odd")
+	}
+
+	if config.Mode == "rtu" {
+		return
+	}
+
+	// RTU over TCP/UDP still uses the baudrate to time frames
+	defaults := defaultConfig()
+	if config.Baudrate != defaults.Baudrate && !strings.HasPrefix(config.Mode, "rtuover") {
+		c.warn("--baudrate is ignored in %s mode", config.Mode)
+	}
+	if config.Databits != defaults.Databits || config.Stopbits != defaults.Stopbits ||
+		config.Parity != defaults.Parity {
+		c.warn("--databits, --stopbits and --parity are ignored in %s mode", config.Mode)
+	}
+
+	// Auto-baud only makes sense on a local serial line
+	if config.AutoBaud {
+		c.fail("--auto-baud requires rtu mode")
+	}
+}
+
+// checkData validates the slave address, data type, count and write values.
+func (c *configCheck) checkData(config *Config) {
+	// Validate slave address range
+	polled := make(map[int]bool)
+	for _, unit := range config.units() {
+		if unit < 0 || unit > 255 {
+			c.fail("slave address must be between 0 and 255")
+		}
+		polled[unit] = true
+	}
+	for unit, label := range config.Labels {
+		if !polled[unit] {
+			c.fail("label %s names unit %d, which isn't polled", label, unit)
+		}
+	}
+	if len(config.SlaveIDs) > 1 && len(polled) < len(config.SlaveIDs) {
+		c.fail("slave address list contains duplicates")
+	}
+
+	// Validate data type and count per function code
+	switch config.DataType {
+	case "0", "1":
+		if config.Count < 1 || config.Count > maxReadBits {
+			c.fail("count must be between 1 and %d for coils and discrete inputs", maxReadBits)
+		}
+	case "3", "3:hex", "3:int", "3:float", "3:fixed", "3:fixed32",
+		"4", "4:hex", "4:int", "4:float", "4:fixed", "4:fixed32":
+		// Longer reads are split into several requests, but all of them
+		// must fit below the last address
+		first := config.StartRef
+		if config.ZeroBased {
+			first = 0
+		}
+		if config.Count < 1 {
+			c.fail("count must be at least 1")
+		} else if first+config.registerCount() > 65536 {
+			c.fail("reading %d registers from reference %d runs past the last address 65535", config.registerCount(), config.StartRef)
+		}
+	default:
+		c.fail("unsupported data type: %s", config.DataType)
+	}
+
+	// Validate count unit
+	if config.CountUnit != "values" && config.CountUnit != "registers" {
+		c.fail("count unit must be values or registers")
+	}
+
+	// Templates are evaluated at send time, but must parse
+	for _, arg := range config.WriteArgs {
+		if isWriteTemplate(arg) {
+			if _, err := parseWriteTemplate(arg); err != nil {
+				c.fail("invalid write value template %s: %v", arg, err)
+			}
+		}
+	}
+
+	// Validate write values
+	if len(config.WriteArgs) > 0 {
+		switch {
+		case config.DataType == "0" || strings.HasPrefix(config.DataType, "4"):
+			// Longer writes are split into several requests, but all of
+			// them must fit below the last address
+			first := config.StartRef
+			if config.ZeroBased {
+				first = 0
+			}
+			if first+config.writeRegisterCount() > 65536 {
+				c.fail("writing %d values from reference %d runs past the last address 65535", len(config.WriteArgs), config.StartRef)
+			}
+		case config.DataType == "1":
+			c.fail("discrete inputs (-t 1) are read-only: the Modbus data model has no function to write them; to write bits, use coils (-t 0)")
+		case strings.HasPrefix(config.DataType, "3"):
+			c.fail("input registers (-t %s) are read-only: the Modbus data model has no function to write them; write the holding registers with -t 4%s, or add --treat-as-holding if a gateway maps these values onto holding registers", config.DataType, config.DataType[1:])
+		default:
+			c.fail("write operations not supported for data type: %s", config.DataType)
+		}
+	}
+	if config.TreatAsHolding && (config.DataType == "0" || config.DataType == "1") {
+		c.fail("--treat-as-holding applies to the input register types (-t 3...)")
+	}
+
+	// Scheduled writes
+	if !config.WriteAt.IsZero() {
+		if len(config.WriteArgs) == 0 {
+			c.fail("--at and --in require write values")
+		}
+		if !config.WriteAt.After(time.Now()) {
+			c.fail("scheduled write time %s has already passed", config.WriteAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	// Replays write the values of each row
+	if config.Replay != nil {
+		if len(config.WriteArgs) > 0 || config.WriteLoop != nil {
+			c.fail("--replay can't be combined with write values or --write-loop")
+		}
+		if config.DataType != "0" && !strings.HasPrefix(config.DataType, "4") {
+			c.fail("--replay requires a coil or holding register data type (0 or 4)")
+		}
+		if math.IsNaN(config.ReplaySpeed) || config.ReplaySpeed < 0.01 || config.ReplaySpeed > 1000 {
+			c.fail("replay speed must be between 0.01 and 1000")
+		}
+	}
+
+	// Batches write the values of each row
+	if config.BatchSheet != "" && config.BatchFile == "" {
+		c.fail("--sheet requires --from")
+	}
+	if config.Batch != nil {
+		if len(config.WriteArgs) > 0 || config.WriteLoop != nil || config.Replay != nil {
+			c.fail("--from can't be combined with write values, --write-loop or --replay")
+		}
+		if config.DataType != "0" && !strings.HasPrefix(config.DataType, "4") {
+			c.fail("--from requires a coil or holding register data type (0 or 4)")
+		}
+	}
+
+	// Repeated writes refresh the write values
+	if config.Repeat {
+		if len(config.WriteArgs) == 0 {
+			c.fail("--repeat requires write values")
+		}
+		if config.WriteLoop != nil {
+			c.fail("--repeat and --write-loop can't be combined")
+		}
+		if config.PollOnce {
+			c.warn("--repeat has no effect with --once")
+		}
+	}
+
+	// Rollbacks restore the values a failed chunked write overwrote
+	if config.Rollback && len(config.WriteArgs) == 0 && config.Replay == nil && config.Batch == nil {
+		c.fail("--rollback requires write values")
+	}
+	if config.Atomic && len(config.WriteArgs) == 0 && config.Replay == nil && config.Batch == nil {
+		c.fail("--atomic requires write values")
+	}
+
+	// FC06 writes one holding register per request
+	if config.Single && (len(config.WriteArgs) > 0 || config.WriteLoop != nil || config.Batch != nil) {
+		if !strings.HasPrefix(config.DataType, "4") {
+			c.fail("--single requires a holding register data type (4)")
+		} else if config.wordsPerValue() == 2 {
+			c.warn("--single writes the two halves of each 32-bit value in separate requests")
+		}
+	}
+
+	// A fanout applies one write to a list of hosts and units
+	if config.Fanout {
+		if len(config.WriteArgs) == 0 {
+			c.fail("--fanout requires write values")
+		}
+		if config.Repeat || config.WriteLoop != nil || config.Replay != nil || config.Batch != nil || !config.WriteAt.IsZero() {
+			c.fail("--fanout can't be combined with --repeat, --write-loop, --replay, --from or a scheduled write")
+		}
+		if config.Report != "" {
+			c.fail("--fanout can't be combined with --report")
+		}
+		if _, err := config.fanoutHosts(); err != nil {
+			c.fail("invalid --fanout host list: %v", err)
+		}
+	} else if strings.Contains(config.Host, ",") {
+		c.fail("a list of hosts requires --fanout")
+	}
+
+	// The control socket steers a continuous poll
+	if config.Control != "" {
+		if config.PollOnce || len(config.WriteArgs) > 0 || config.WriteLoop != nil || config.Replay != nil || config.Batch != nil {
+			c.fail("--control requires continuous polling (no --once, write values, --write-loop, --replay or --from)")
+		}
+	}
+
+	// Write loops write one value at a time
+	if config.WriteLoop != nil {
+		if len(config.WriteArgs) > 0 {
+			c.fail("--write-loop can't be combined with write values")
+		}
+		if config.DataType != "0" && !strings.HasPrefix(config.DataType, "4") {
+			c.fail("--write-loop requires a coil or holding register data type (0 or 4)")
+		}
+	}
+
+	// Validate decoder usage
+	if config.Decoder != "" {
+		if strings.TrimSpace(config.Decoder) == "" {
+			c.fail("decoder command must not be empty")
+		}
+		if !strings.HasPrefix(config.DataType, "3") && !strings.HasPrefix(config.DataType, "4") {
+			c.fail("--decoder requires a register data type (3 or 4)")
+		}
+	}
+
+	// Expressions are evaluated against the block read
+	if config.Expect != nil {
+		if config.DataType != "0" && config.DataType != "1" {
+			c.fail("--expect requires a coil or discrete input data type (0 or 1)")
+		}
+		if len(config.WriteArgs) > 0 || config.WriteLoop != nil {
+			c.fail("--expect can't be combined with writes")
+		}
+		start := config.StartRef
+		if config.ZeroBased {
+			start = 0
+		}
+		for _, ref := range config.Expect.refs {
+			if ref < start || ref >= start+config.Count {
+				c.fail("--expect reads reference %d outside the block read (%d-%d)", ref, start, start+config.Count-1)
+				break
+			}
+		}
+	}
+
+	// Layouts describe whole register blocks
+	if config.Layout != nil {
+		if config.DataType != "3" && config.DataType != "4" {
+			c.fail("--layout requires a 16-bit register data type (3 or 4)")
+		}
+		if config.Decoder != "" {
+			c.fail("--layout and --decoder can't be combined")
+		}
+		if len(config.WriteArgs) > 0 || config.WriteLoop != nil {
+			c.fail("--layout can't be combined with writes")
+		}
+		if config.CountUnit == "registers" && config.Count%config.Layout.words != 0 {
+			c.fail("count must be a multiple of the layout's %d registers", config.Layout.words)
+		}
+	}
+
+	// Coil byte swapping only applies to bit reads
+	if config.CoilSwap && config.DataType != "0" && config.DataType != "1" {
+		c.fail("--coil-byte-swap requires a coil or discrete input data type (0 or 1)")
+	}
+
+	// Validate vote count
+	if config.Votes < 1 || config.Votes > 10 {
+		c.fail("vote count must be between 1 and 10")
+	}
+}
+
+// checkModes validates the settings of the discovery, diagnostics and
+// auto-tuning modes.
+func (c *configCheck) checkModes(config *Config) {
+	// Diagnostics are sent as raw Modbus TCP requests
+	diagnostics := 0
+	for _, set := range []bool{config.ExceptionStatus, config.Diagnostic, config.CommEvents, config.CommLog, config.ServerID, config.Scan} {
+		if set {
+			diagnostics++
+		}
+	}
+	if diagnostics > 0 {
+		if diagnostics > 1 {
+			c.fail("diagnostic and scan options can't be combined")
+		}
+		if config.Mode != "tcp" {
+			c.fail("diagnostic and scan options require tcp mode")
+		}
+		if len(config.WriteArgs) > 0 || config.WriteLoop != nil {
+			c.fail("diagnostic and scan options can't be combined with writes")
+		}
+	}
+
+	// Validate scan settings
+	if _, ok := scanProbes[config.ScanProbe]; !ok {
+		c.fail("invalid scan probe: %s (expected read, fc17 or fc43)", config.ScanProbe)
+	}
+	if config.ScanFirst < 0 || config.ScanLast > 255 || config.ScanFirst > config.ScanLast {
+		c.fail("scan units must be a range within 0-255, e.g. 1-247")
+	}
+
+	// Several units are only polled; everything else addresses one unit
+	if len(config.SlaveIDs) > 1 {
+		if len(config.WriteArgs) > 0 && !config.Fanout || config.WriteLoop != nil || config.Replay != nil || config.Batch != nil {
+			c.fail("writes can only address a single slave (use --fanout to write several)")
+		}
+		if diagnostics > 0 || config.AutoBaud || config.ProxyListen != "" || config.TargetsFile != "" || len(config.Sweeps) > 0 {
+			c.fail("a slave address list can only be used for polling")
+		}
+	}
+
+	// Validate sweep rate
+	if config.SweepRate < 1 || config.SweepRate > 1000 {
+		c.fail("sweep rate must be between 1 and 1000 probes per second")
+	}
+
+	// Validate warm-up length
+	if config.Warmup < 5 || config.Warmup > 1000 {
+		c.fail("warmup must be between 5 and 1000 requests")
+	}
+	if config.AutoTimeout && config.PollOnce {
+		c.warn("--auto-timeout has no effect with --once")
+	}
+}
+
+// checkOutput validates the output format, sinks and queue.
+func (c *configCheck) checkOutput(config *Config) {
+	// Validate NATS subject
+	if config.NATSSubject == "" || strings.ContainsAny(config.NATSSubject, " \t\r\n") {
+		c.fail("NATS subject must be non-empty and contain no whitespace")
+	}
+
+	// Validate output format
+	if _, ok := recordFormats[config.Format]; !ok && config.Format != "text" {
+		c.fail("output format must be text, cbor, msgpack, or ndjson")
+	}
+	if config.Output != "text" && config.Output != "json" && config.Output != "csv" {
+		c.fail("--output must be text, json or csv")
+	}
+	if config.Output != "text" && config.Format != "text" && config.OutFile == "" {
+		c.fail("--output %s and a record --format both use stdout, write the records with --out", config.Output)
+	}
+	if config.Output != "text" && config.Decoder != "" {
+		c.fail("--output %s cannot be combined with --decoder", config.Output)
+	}
+	if config.Output == "csv" && (len(config.WriteArgs) > 0 || config.Batch != nil) {
+		c.fail("--output csv lists reads, not writes")
+	}
+	if config.OutFile != "" && config.Format == "text" {
+		c.fail("--out requires a record --format")
+	}
+	if (config.RotateSize > 0 || config.RotateEvery > 0) && config.OutFile == "" {
+		c.fail("--rotate requires --out")
+	}
+	if config.Keep < 1 || config.Keep > 1000 {
+		c.fail("--keep must be between 1 and 1000")
+	}
+
+	// Validate report format
+	if config.Report != "" && !isHTMLReport(config.Report) {
+		if ext := strings.ToLower(filepath.Ext(config.Report)); ext != ".md" && ext != ".markdown" {
+			c.fail("report file must end in .html, .htm or .md")
+		}
+	}
+
+	// Validate Pushgateway URL
+	if config.PushGateway != "" {
+		if u, err := url.Parse(config.PushGateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.fail("push gateway must be an http:// or https:// URL")
+		}
+	}
+
+	// Validate output queue
+	if config.QueueSize < 1 || config.QueueSize > 100000 {
+		c.fail("queue size must be between 1 and 100000")
+	}
+	if config.QueuePolicy != policyDropOldest && config.QueuePolicy != policyDropNewest &&
+		config.QueuePolicy != policyBlock {
+		c.fail("queue policy must be drop-oldest, drop-newest, or block")
+	}
+	if config.SinkExec != "" && strings.TrimSpace(config.SinkExec) == "" {
+		c.fail("sink command must not be empty")
+	}
+	if config.CDC && config.Format == "text" && config.NATSURL == "" && config.SinkExec == "" {
+		c.fail("--cdc requires a record --format, --nats or --sink-exec")
+	}
+}
+
+// checkTiming validates the poll rate, timeout and busy patience.
+func (c *configCheck) checkTiming(config *Config) {
+	// Validate poll rate
+	if config.PollRate < 10*time.Millisecond {
+		c.fail("poll rate must be at least 10ms")
+	}
+
+	// Validate timeout
+	if config.Timeout < 10*time.Millisecond || config.Timeout > 10*time.Second {
+		c.fail("timeout must be between 0.01 and 10.00 seconds")
+	}
+	if config.ConnectTimeout != 0 && (config.ConnectTimeout < 10*time.Millisecond || config.ConnectTimeout > time.Minute) {
+		c.fail("connect timeout must be between 0.01 and 60 seconds")
+	}
+	if config.ConnectTimeout != 0 && (config.Mode == "rtu" || config.Mode == "udp" || config.Mode == "rtuoverudp") {
+		c.warn("--connect-timeout has no effect in %s mode, which opens no TCP connection", config.Mode)
+	}
+
+	// Validate busy patience
+	if config.BusyPatience < 0 || config.BusyPatience > time.Minute {
+		c.fail("busy patience must be between 0 and 60 seconds")
+	}
+
+	// Validate semaphore
+	if config.LockRef >= 0 && config.LockToken < 0 {
+		c.fail("--lock needs --lock-token, a value no other client uses")
+	}
+	if config.LockRef >= 0 && config.LockToken == int(config.LockFree) {
+		c.fail("--lock-token and --lock-free must differ")
+	}
+	if config.LockRef >= 0 && config.ReadOnly {
+		c.fail("--lock writes the semaphore register, which read-only mode refuses")
+	}
+	if config.LockWait < 0 || config.LockWait > 10*time.Minute {
+		c.fail("lock wait must be between 0 and 600 seconds")
+	}
+
+	// Validate scan cycle sync
+	if config.SyncWait < 0 || config.SyncWait > 10*time.Minute {
+		c.fail("sync wait must be between 0 and 600 seconds")
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// A verify-device spec is a YAML file of device acceptance tests:
+//
+//	name: pump controller
+//	tests:
+//	  - name: firmware version
+//	    type: 4
+//	    ref: 100
+//	    expect: [2, 5]
+//	  - name: setpoint round trip
+//	    type: 4:float
+//	    ref: 200
+//	    write: [21.5]
+//
+// Every test reads the values at ref and compares them with expect, after
+// writing write first if given; a test that only writes expects the written
+// values back. Tests may also name a unit. Only this subset of YAML is
+// understood: scalars, flow lists and a list of flat mappings under tests.
+
+// verifyTest is one test case of a spec.
+type verifyTest struct {
+	name     string
+	line     int // spec line the test starts on
+	unit     int // -1 for the -a unit
+	dataType string
+	exponent int // of a fixed-point type
+	ref      int
+	write    []string
+	expect   []string
+}
+
+type verifySpec struct {
+	name  string
+	tests []verifyTest
+}
+
+// verifyMismatch is a test whose values differ from the expected ones, as
+// opposed to one that could not be carried out.
+type verifyMismatch struct {
+	msg string
+}
+
+func (e *verifyMismatch) Error() string {
+	return e.msg
+}
+
+// loadVerifySpec reads a spec, taking data types without one of their own
+// from -t.
+func loadVerifySpec(path string, c *Config) (*verifySpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	spec := &verifySpec{name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	var test *verifyTest
+	inTests := false
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		// Top-level keys start in the first column
+		if line[0] != ' ' && line[0] != '-' {
+			key, value, _ := strings.Cut(trimmed, ":")
+			switch key {
+			case "name":
+				spec.name = yamlScalar(value)
+			case "tests":
+				inTests = true
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown key %s", path, lineNo, key)
+			}
+			continue
+		}
+		if !inTests {
+			return nil, fmt.Errorf("%s:%d: expected tests:", path, lineNo)
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "-"); ok {
+			spec.tests = append(spec.tests, verifyTest{line: lineNo, unit: -1, dataType: c.DataType, exponent: c.FixedExponent, ref: -1})
+			test = &spec.tests[len(spec.tests)-1]
+			trimmed = strings.TrimSpace(item)
+			if trimmed == "" {
+				continue
+			}
+		}
+		if test == nil {
+			return nil, fmt.Errorf("%s:%d: expected a test starting with -", path, lineNo)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY: VALUE", path, lineNo)
+		}
+		if err := test.set(strings.TrimSpace(key), value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(spec.tests) == 0 {
+		return nil, fmt.Errorf("%s: no tests", path)
+	}
+	for i := range spec.tests {
+		if err := spec.tests[i].check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, spec.tests[i].line, err)
+		}
+	}
+	return spec, nil
+}
+
+// set assigns a key of a test.
+func (t *verifyTest) set(key, value string) error {
+	switch key {
+	case "name":
+		t.name = yamlScalar(value)
+	case "unit":
+		unit, err := strconv.Atoi(yamlScalar(value))
+		if err != nil || unit < 0 || unit > 255 {
+			return fmt.Errorf("invalid unit: %s", yamlScalar(value))
+		}
+		t.unit = unit
+	case "type":
+		dataType, exponent, fixed, err := parseFixedType(yamlScalar(value))
+		if err != nil {
+			return err
+		}
+		name := dataType
+		if fixed {
+			name += ":SCALE"
+		}
+		if !slices.Contains(dataTypes, name) {
+			return fmt.Errorf("unsupported data type: %s", yamlScalar(value))
+		}
+		t.dataType, t.exponent = dataType, exponent
+	case "ref":
+		ref, err := strconv.Atoi(yamlScalar(value))
+		if err != nil || ref < 0 || ref > 65535 {
+			return fmt.Errorf("invalid reference: %s", yamlScalar(value))
+		}
+		t.ref = ref
+	case "write":
+		t.write = yamlList(value)
+	case "expect":
+		t.expect = yamlList(value)
+	default:
+		return fmt.Errorf("unknown test key %s (expected name, unit, type, ref, write or expect)", key)
+	}
+	return nil
+}
+
+// check completes a test once all its keys are read.
+func (t *verifyTest) check() error {
+	if t.ref < 0 {
+		return fmt.Errorf("test needs a ref")
+	}
+	if len(t.write) > 0 && t.dataType != "0" && !strings.HasPrefix(t.dataType, "4") {
+		return fmt.Errorf("write operations not supported for data type: %s", t.dataType)
+	}
+	if len(t.expect) == 0 {
+		t.expect = t.write
+	}
+	if len(t.expect) == 0 {
+		return fmt.Errorf("test needs expect or write values")
+	}
+	for _, value := range append(slices.Clip(t.write), t.expect...) {
+		if !isWriteValue(value) {
+			return fmt.Errorf("invalid value: %s", value)
+		}
+	}
+	if t.name == "" {
+		t.name = fmt.Sprintf("%s at %d", dataTypeDescription(t.dataType), t.ref)
+	}
+	return nil
+}
+
+// yamlScalar returns a scalar value without its quotes.
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// yamlList returns the items of a flow list such as [1, 2, 3], or a single
+// scalar as a list of one.
+func yamlList(value string) []string {
+	value = strings.TrimSpace(value)
+	inner, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return []string{yamlScalar(value)}
+	}
+	var items []string
+	for _, item := range strings.Split(strings.TrimSuffix(inner, "]"), ",") {
+		if item = yamlScalar(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runVerify implements "gomodbus verify-device SPEC [OPTIONS] DEVICE|HOST":
+// it runs the tests of a spec against the device, prints a report, and
+// writes it as JUnit XML or TAP with --junit and --tap, so device acceptance
+// tests can run in CI. It fails if any test failed.
+func (m *ModbusCLI) runVerify(path string) error {
+	spec, err := loadVerifySpec(path, m.config)
+	if err != nil {
+		return err
+	}
+	if err := m.setupClient(); err != nil {
+		return err
+	}
+	if err := m.connect(); err != nil {
+		return err
+	}
+	defer m.client.Close()
+
+	fmt.Printf("Verifying %s against %s (%d test(s))\n", spec.name, m.target(), len(spec.tests))
+	suite := newTestSuite(spec.name)
+	failed := 0
+	for i := range spec.tests {
+		test := &spec.tests[i]
+		started := time.Now()
+		err := m.withLock(func() error { return m.runVerifyTest(test) })
+		duration := time.Since(started)
+
+		// A wrong value is a failure, a test that couldn't be carried out
+		// (no answer, exception) an error
+		var mismatch *verifyMismatch
+		switch {
+		case errors.As(err, &mismatch):
+			suite.add(test.name, duration, testFail, err.Error())
+		case err != nil:
+			suite.add(test.name, duration, testError, localizeError(err))
+		default:
+			suite.add(test.name, duration, testPass, "")
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("  [FAIL] %s: %v\n", test.name, localizeError(err))
+		} else {
+			fmt.Printf("  [PASS] %s\n", test.name)
+		}
+	}
+
+	if err := m.writeTestReports(suite); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verification failed: %d of %d test(s) failed", failed, len(spec.tests))
+	}
+	fmt.Printf("All %d test(s) passed\n", len(spec.tests))
+	return nil
+}
+
+// runVerifyTest carries out one test with its unit and data type, leaving
+// the configuration as it was.
+func (m *ModbusCLI) runVerifyTest(t *verifyTest) error {
+	unit := t.unit
+	if unit < 0 {
+		unit = m.config.SlaveID
+	}
+	defaultUnit := m.config.SlaveID
+	if err := m.selectUnit(unit); err != nil {
+		return err
+	}
+	defer m.selectUnit(defaultUnit)
+
+	c := m.config
+	dataType, exponent := c.DataType, c.FixedExponent
+	defer func() { c.DataType, c.FixedExponent, c.WriteArgs = dataType, exponent, nil }()
+	c.DataType, c.FixedExponent = t.dataType, t.exponent
+
+	table := t.dataType[:1]
+	if len(t.write) > 0 {
+		if err := c.checkWrite(time.Now()); err != nil {
+			return fmt.Errorf("refusing to write: %v", err)
+		}
+		c.WriteArgs = t.write
+		if _, err := m.expandWriteArgs(); err != nil {
+			return err
+		}
+		values, err := m.encodeWriteRaw(journalTable(t.dataType))
+		if err != nil {
+			return err
+		}
+		if err := m.writeRaw(journalTable(t.dataType), t.ref, values); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+
+	// Expected values are encoded like writes of the same type, so they
+	// compare exactly with the raw values read
+	c.WriteArgs = t.expect
+	if _, err := m.expandWriteArgs(); err != nil {
+		return err
+	}
+	if table == "1" || table == "3" {
+		c.DataType = map[string]string{"1": "0", "3": "4"}[table] + t.dataType[1:]
+	}
+	expected, err := m.encodeWriteRaw(journalTable(c.DataType))
+	c.DataType = t.dataType
+	if err != nil {
+		return err
+	}
+
+	got, err := m.readTestValues(table, t.ref, len(expected))
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	words := 1
+	if table == "3" || table == "4" {
+		words = c.wordsPerValue()
+	}
+	for i, value := range t.expect {
+		word := i * words
+		if !slices.Equal(got[word:word+words], expected[word:word+words]) {
+			return &verifyMismatch{fmt.Sprintf("reference %d reads %s, expected %s",
+				t.ref+word, m.formatTestValue(table, got[word:word+words]), value)}
+		}
+	}
+	return nil
+}
+
+// readTestValues reads count coils or discrete inputs (as 0 or 1) or input
+// or holding registers of table.
+func (m *ModbusCLI) readTestValues(table string, ref, count int) ([]uint16, error) {
+	switch table {
+	case "0", "4":
+		return m.readOriginal(table, ref, count)
+	case "1":
+		var values []uint16
+		err := m.retryBusy(false, func() error {
+			inputs, err := m.client.ReadDiscreteInputs(uint16(ref), uint16(count))
+			for _, input := range inputs {
+				values = append(values, uint16(boolToInt(input)))
+			}
+			return err
+		})
+		return values, err
+	default:
+		var values []uint16
+		err := m.retryBusy(false, func() error {
+			var err error
+			values, err = m.client.ReadRegisters(uint16(ref), uint16(count), modbus.INPUT_REGISTER)
+			return err
+		})
+		return values, err
+	}
+}
+
+// formatTestValue formats the words of one value read by a test as the
+// data type shows it.
+func (m *ModbusCLI) formatTestValue(table string, words []uint16) string {
+	if table == "0" || table == "1" {
+		return strconv.Itoa(int(words[0]))
+	}
+	value := decodeRegisters(nil, words, m.config.DataType, m.config.BigEndian)[0]
+	switch value.Kind {
+	case kindInt32:
+		return strconv.Itoa(int(value.Int32()))
+	case kindFloat32:
+		return strconv.FormatFloat(float64(value.Float32()), 'g', -1, 32)
+	case kindFixed16:
+		return string(appendFixed(nil, int64(int16(value.Bits)), m.config.FixedExponent))
+	case kindFixed32:
+		return string(appendFixed(nil, int64(value.Int32()), m.config.FixedExponent))
+	}
+	return strconv.Itoa(int(value.Bits))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeWindow is a recurring period during which writes are permitted,
+// e.g. "Mon-Fri 22:00-06:00". Windows that end before they start run past
+// midnight and belong to the day they start on.
+type writeWindow struct {
+	spec  string
+	days  [7]bool // indexed by time.Weekday
+	start int     // minutes after midnight
+	end   int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWriteWindow parses "[DAYS] HH:MM-HH:MM", where DAYS is a comma
+// separated list of days or day ranges (Mon-Fri,Sun). Without DAYS the
+// window applies every day.
+func parseWriteWindow(spec string) (writeWindow, error) {
+	w := writeWindow{spec: spec}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid write window %q (expected [DAYS] HH:MM-HH:MM)", spec)
+	}
+
+	if len(fields) == 1 {
+		for d := range w.days {
+			w.days[d] = true
+		}
+	} else {
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, ok := weekdayNames[strings.ToLower(from)]
+			if !ok {
+				return w, fmt.Errorf("invalid day %q in write window %q", from, spec)
+			}
+			last := first
+			if isRange {
+				if last, ok = weekdayNames[strings.ToLower(to)]; !ok {
+					return w, fmt.Errorf("invalid day %q in write window %q", to, spec)
+				}
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return w, fmt.Errorf("invalid time range in write window %q", spec)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return w, fmt.Errorf("invalid write window %q: %v", spec, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return w, fmt.Errorf("invalid write window %q: %v", spec, err)
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("write window %q is empty", spec)
+	}
+
+	return w, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 is accepted as
+// the end of the day.
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	hours, err1 := strconv.Atoi(hh)
+	minutes, err2 := strconv.Atoi(mm)
+	if err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 ||
+		hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// contains reports whether t falls inside the window.
+func (w writeWindow) contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minutes >= w.start && minutes < w.end
+	}
+
+	// The window runs past midnight
+	if minutes >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minutes < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+// checkWrite returns why a write at the given time must be refused, or nil
+// if it is allowed.
+func (c *Config) checkWrite(now time.Time) error {
+	if c.ReadOnly {
+		return fmt.Errorf("read-only mode is enabled")
+	}
+
+	if len(c.WriteWindows) == 0 {
+		return nil
+	}
+	specs := make([]string, len(c.WriteWindows))
+	for i, w := range c.WriteWindows {
+		if w.contains(now) {
+			return nil
+		}
+		specs[i] = w.spec
+	}
+	return fmt.Errorf("writes are only permitted during %s", strings.Join(specs, "; "))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	for s, want := range map[string]int{"00:00": 0, "6:05": 365, "23:59": 1439, "24:00": 1440} {
+		if got, err := parseClock(s); err != nil || got != want {
+			t.Errorf("parseClock(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "12", "12:60", "24:01", "25:00", "-1:00", "ab:cd", "12:"} {
+		if _, err := parseClock(s); err == nil {
+			t.Errorf("parseClock(%q) succeeded", s)
+		}
+	}
+}
+
+func TestWriteWindow(t *testing.T) {
+	// 2024-01-01 was a Monday
+	at := func(day int, clock string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", fmt.Sprintf("2024-01-%02d %s", day, clock))
+		return t
+	}
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"08:00-17:00", at(7, "08:00"), true},
+		{"08:00-17:00", at(7, "16:59"), true},
+		{"08:00-17:00", at(7, "17:00"), false},
+		{"08:00-17:00", at(7, "07:59"), false},
+		{"Mon-Fri 08:00-17:00", at(5, "12:00"), true},
+		{"Mon-Fri 08:00-17:00", at(6, "12:00"), false},
+		{"mon,Wed 08:00-17:00", at(3, "12:00"), true},
+		{"mon,Wed 08:00-17:00", at(2, "12:00"), false},
+		// Ranges wrap around the week
+		{"Fri-Mon 00:00-24:00", at(7, "23:59"), true},
+		{"Fri-Mon 00:00-24:00", at(2, "00:00"), false},
+		// Past midnight, the window belongs to the day it starts on
+		{"Fri 22:00-06:00", at(5, "23:00"), true},
+		{"Fri 22:00-06:00", at(6, "05:59"), true},
+		{"Fri 22:00-06:00", at(6, "06:00"), false},
+		{"Fri 22:00-06:00", at(5, "05:00"), false},
+	}
+	for _, tt := range tests {
+		w, err := parseWriteWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if got := w.contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.spec, tt.at.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestParseWriteWindowErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "expected [DAYS] HH:MM-HH:MM"},
+		{"Mon 08:00-17:00 extra", "expected [DAYS] HH:MM-HH:MM"},
+		{"Someday 08:00-17:00", `invalid day "Someday"`},
+		{"Mon-Funday 08:00-17:00", `invalid day "Funday"`},
+		{"08:00", "invalid time range"},
+		{"8-17", `invalid time "8"`},
+		{"08:00-08:00", "is empty"},
+	}
+	for _, tt := range tests {
+		if _, err := parseWriteWindow(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseWriteWindow(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestCheckWrite(t *testing.T) {
+	night, _ := parseWriteWindow("22:00-06:00")
+	weekend, _ := parseWriteWindow("Sat,Sun 08:00-12:00")
+	c := &Config{WriteWindows: []writeWindow{night, weekend}}
+
+	saturday := time.Date(2024, 1, 6, 9, 0, 0, 0, time.UTC)
+	if err := c.checkWrite(saturday); err != nil {
+		t.Errorf("write on Saturday morning refused: %v", err)
+	}
+	err := c.checkWrite(saturday.Add(6 * time.Hour))
+	if want := "writes are only permitted during 22:00-06:00; Sat,Sun 08:00-12:00"; err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+
+	c.ReadOnly = true
+	if err := c.checkWrite(saturday); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("read-only error = %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writePattern produces the successive values written by --write-loop, as
+// write values that the data type's parser turns into registers.
+type writePattern interface {
+	next() string
+}
+
+// formatPatternValue formats a generated value as a write value.
+func formatPatternValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// rampPattern counts from min to max in steps, then starts over. Values are
+// computed from the step count rather than added up, so fractional steps
+// don't accumulate rounding error over a long run.
+type rampPattern struct {
+	min, step float64
+	steps     int // steps after min that stay within max
+	n         int
+}
+
+func newRampPattern(min, max, step float64) *rampPattern {
+	// The tolerance keeps max itself when (max-min)/step is a whole number
+	// that float division lands just below, e.g. 0:0.3:0.1
+	steps := int(math.Floor((max-min)/step + 1e-9))
+	return &rampPattern{min: min, step: step, steps: steps}
+}
+
+func (p *rampPattern) next() string {
+	val := p.min + float64(p.n)*p.step
+	p.n++
+	if p.n > p.steps {
+		p.n = 0
+	}
+	return formatPatternValue(val)
+}
+
+// squarePattern alternates between low and high, holding each level for
+// hold writes.
+type squarePattern struct {
+	low, high float64
+	hold      int
+	n         int
+}
+
+func (p *squarePattern) next() string {
+	val := p.low
+	if (p.n/p.hold)%2 == 1 {
+		val = p.high
+	}
+	p.n++
+	return formatPatternValue(val)
+}
+
+// randomPattern picks whole numbers uniformly between min and max inclusive.
+type randomPattern struct {
+	min, max float64
+}
+
+func (p *randomPattern) next() string {
+	return formatPatternValue(p.min + math.Floor(rand.Float64()*(p.max-p.min+1)))
+}
+
+// replayPattern plays back values loaded from a CSV file, starting over at
+// the end. The values are kept as written in the file.
+type replayPattern struct {
+	values []string
+	n      int
+}
+
+func (p *replayPattern) next() string {
+	val := p.values[p.n%len(p.values)]
+	p.n++
+	return val
+}
+
+// parseWritePattern parses a --write-loop spec: ramp:MIN:MAX[:STEP],
+// square:LOW:HIGH[:HOLD], random:MIN:MAX or csv:FILE.
+func parseWritePattern(spec string) (writePattern, error) {
+	kind, rest, _ := strings.Cut(spec, ":")
+	if kind == "csv" {
+		return loadReplayPattern(rest)
+	}
+
+	var args []float64
+	for _, field := range strings.Split(rest, ":") {
+		r, err := parseWriteNumber(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid write loop %q: bad value %q", spec, field)
+		}
+		val, _ := r.Float64()
+		args = append(args, val)
+	}
+
+	switch kind {
+	case "ramp":
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("invalid write loop %q (expected ramp:MIN:MAX[:STEP])", spec)
+		}
+		step := 1.0
+		if len(args) == 3 {
+			step = args[2]
+		}
+		if args[0] > args[1] || step <= 0 {
+			return nil, fmt.Errorf("invalid write loop %q: MIN must not exceed MAX and STEP must be positive", spec)
+		}
+		return newRampPattern(args[0], args[1], step), nil
+
+	case "square":
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("invalid write loop %q (expected square:LOW:HIGH[:HOLD])", spec)
+		}
+		p := &squarePattern{low: args[0], high: args[1], hold: 1}
+		if len(args) == 3 {
+			if args[2] < 1 || args[2] != math.Trunc(args[2]) {
+				return nil, fmt.Errorf("invalid write loop %q: HOLD must be a positive number of writes", spec)
+			}
+			p.hold = int(args[2])
+		}
+		return p, nil
+
+	case "random":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("invalid write loop %q (expected random:MIN:MAX)", spec)
+		}
+		if args[0] > args[1] || args[0] != math.Trunc(args[0]) || args[1] != math.Trunc(args[1]) {
+			return nil, fmt.Errorf("invalid write loop %q: MIN and MAX must be whole numbers with MIN <= MAX", spec)
+		}
+		return &randomPattern{min: args[0], max: args[1]}, nil
+	}
+
+	return nil, fmt.Errorf("invalid write loop %q (patterns: ramp, square, random, csv)", spec)
+}
+
+// loadReplayPattern reads the values to replay from the last column of a CSV
+// file or .xlsx workbook.
+func loadReplayPattern(path string) (writePattern, error) {
+	rows, err := loadWriteRows(path, "", -1, "VALUE")
+	if err != nil {
+		return nil, err
+	}
+	p := &replayPattern{}
+	for _, row := range rows {
+		p.values = append(p.values, row.args[0])
+	}
+	return p, nil
+}
+
+// runWriteLoop writes the next value of the write loop pattern, or with
+// --repeat the write values again, to startRef every poll interval until
+// interrupted. Only the first write is journaled: it holds the values from
+// before the loop started, which undo restores, and journaling every write
+// would grow the journal and double the bus traffic of an endurance run.
+func (m *ModbusCLI) runWriteLoop(startRef int, stop <-chan os.Signal, stats *pollStats) error {
+	wait := newPollTimer()
+	defer wait.Stop()
+
+	journal := m.config.Journal
+	defer func() { m.config.Journal = journal }()
+
+	for {
+		if m.config.WriteLoop != nil {
+			m.config.WriteArgs = []string{m.config.WriteLoop.next()}
+		}
+		started := time.Now()
+		m.report.setValues(m.config.WriteArgs, m.config.MaskValues)
+		err := m.withLock(func() error { return m.journaledWrite(startRef) })
+		if err == nil {
+			m.config.Journal = journalOff
+		}
+		m.report.request("write", startRef, len(m.config.WriteArgs), started, err)
+		stats.record(err)
+		if m.health != nil {
+			m.health.record(m.target(), m.config.SlaveID, err)
+		}
+		if err != nil {
+			// Device and link errors are tallied and the loop carries on
+			if _, ok := classifyError(err); !ok || m.config.PollOnce {
+				return err
+			}
+			m.printPollError(err)
+		}
+
+		if m.config.PollOnce {
+			return nil
+		}
+
+		wait.Reset(m.config.PollRate)
+		select {
+		case <-stop:
+			return nil
+		case <-wait.C:
+		}
+	}
+}
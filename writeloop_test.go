@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRampPattern(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"ramp:0:3", []string{"0", "1", "2", "3", "0", "1"}},
+		{"ramp:10:20:5", []string{"10", "15", "20", "10"}},
+		{"ramp:0:10:4", []string{"0", "4", "8", "0"}},
+		{"ramp:0:0.3:0.1", []string{"0", "0.1", "0.2", "0.30000000000000004", "0"}},
+		{"ramp:5:5", []string{"5", "5"}},
+	}
+	for _, tt := range tests {
+		p, err := parseWritePattern(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		var got []string
+		for range tt.want {
+			got = append(got, p.next())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s gave %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestRampPatternLongRun checks that a fractional step doesn't drift: after
+// many cycles the values are the same as in the first one.
+func TestRampPatternLongRun(t *testing.T) {
+	p := newRampPattern(0, 100, 0.1)
+	first := make([]string, 1001)
+	for i := range first {
+		first[i] = p.next()
+	}
+	if first[1000] != "100" {
+		t.Fatalf("the ramp ends at %s, want 100", first[1000])
+	}
+	for cycle := 0; cycle < 1000; cycle++ {
+		for i, want := range first {
+			if got := p.next(); got != want {
+				t.Fatalf("cycle %d, step %d: %v, want %v", cycle, i, got, want)
+			}
+		}
+	}
+}
+
+func TestParseWritePatternErrors(t *testing.T) {
+	for _, spec := range []string{
+		"ramp:5", "ramp:5:1", "ramp:0:10:0", "ramp:0:10:-1",
+		"square:1", "square:0:1:0.5", "random:0", "random:0.5:3", "sine:0:1",
+	} {
+		if _, err := parseWritePattern(spec); err == nil {
+			t.Errorf("parseWritePattern(%q) accepted an invalid spec", spec)
+		}
+	}
+}
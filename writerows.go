@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeRow is one row of a file of writes (--from, --replay and
+// --write-loop csv:): the leading fields, such as a time or a reference, and
+// the write values after them.
+type writeRow struct {
+	row    int
+	fields []string
+	args   []string
+}
+
+// loadWriteRows reads the rows of a CSV file, or of a sheet of an Excel
+// workbook (.xlsx), as "leading" fields followed by one or more write
+// values; with leading < 0 every field but the last leads. Fields are
+// trimmed and empty rows skipped. Rows before the first one whose values
+// are all write values are headers, so a title and column names need no
+// marking; after it an invalid value is an error. usage describes the row
+// layout for errors, e.g. "REF,VALUE[,VALUE...]".
+func loadWriteRows(path, sheet string, leading int, usage string) ([]writeRow, error) {
+	var records [][]string
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		records, err = readXLSXSheet(path, sheet)
+	} else if sheet != "" {
+		return nil, fmt.Errorf("--sheet applies to .xlsx workbooks, not %s", path)
+	} else {
+		records, err = readCSVRecords(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []writeRow
+	for i, record := range records {
+		n := i + 1
+		for len(record) > 0 && strings.TrimSpace(record[len(record)-1]) == "" {
+			record = record[:len(record)-1]
+		}
+		if len(record) == 0 {
+			continue
+		}
+		fields := make([]string, len(record))
+		for j, field := range record {
+			fields[j] = strings.TrimSpace(field)
+		}
+
+		split := leading
+		if split < 0 {
+			split = len(fields) - 1
+		}
+		short := len(fields) <= split
+		bad := -1
+		for j := split; j < len(fields) && bad < 0; j++ {
+			if !isWriteValue(fields[j]) {
+				bad = j
+			}
+		}
+		switch {
+		case (short || bad >= 0) && len(rows) == 0:
+			continue // header
+		case short:
+			return nil, fmt.Errorf("%s:%d: expected %s", path, n, usage)
+		case bad >= 0:
+			return nil, fmt.Errorf("%s:%d: invalid value %q", path, n, fields[bad])
+		}
+		rows = append(rows, writeRow{row: n, fields: fields[:split], args: fields[split:]})
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s contains no rows to write", path)
+	}
+	return rows, nil
+}
+
+func readCSVRecords(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	// Blank and comment lines are kept as empty records, so a record's
+	// index gives its line number like a row of a sheet
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+		for len(records) < line-1 {
+			records = append(records, nil)
+		}
+		records = append(records, record)
+	}
+}
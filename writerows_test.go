@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWriteRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		leading int
+		want    []writeRow
+		wantErr string
+	}{
+		{
+			name:    "title and header rows are skipped",
+			content: "Setpoints for line 1\nREF,VALUE\n\n100, 450 ,\n101,0x10,-2\n",
+			leading: 1,
+			want: []writeRow{
+				{row: 4, fields: []string{"100"}, args: []string{"450"}},
+				{row: 5, fields: []string{"101"}, args: []string{"0x10", "-2"}},
+			},
+		},
+		{
+			name:    "comments",
+			content: "# exported 2024-07-01\n100,1\n",
+			leading: 1,
+			want:    []writeRow{{row: 2, fields: []string{"100"}, args: []string{"1"}}},
+		},
+		{
+			name:    "last column only",
+			content: "time,value\n2024-07-01 06:00,on\n2024-07-01 06:01,\"{{ env \"\"X\"\" }}\"\n",
+			leading: -1,
+			want: []writeRow{
+				{row: 2, fields: []string{"2024-07-01 06:00"}, args: []string{"on"}},
+				{row: 3, fields: []string{"2024-07-01 06:01"}, args: []string{`{{ env "X" }}`}},
+			},
+		},
+		{
+			name:    "invalid value after the first row",
+			content: "REF,VALUE\n100,1\n101,abc\n",
+			leading: 1,
+			wantErr: `:3: invalid value "abc"`,
+		},
+		{
+			name:    "missing value after the first row",
+			content: "100,1\n101\n",
+			leading: 1,
+			wantErr: ":2: expected REF,VALUE",
+		},
+		{
+			name:    "only headers",
+			content: "REF,VALUE\n",
+			leading: 1,
+			wantErr: "contains no rows to write",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, "rows.csv", tt.content)
+			rows, err := loadWriteRows(path, "", tt.leading, "REF,VALUE")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %+v, want %+v", rows, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadWriteRows(writeTempFile(t, "rows.csv", "100,1\n"), "Tuning", 1, "REF,VALUE"); err == nil {
+		t.Errorf("--sheet was accepted for a CSV file")
+	}
+}
+
+func TestLoadWriteRowsXLSX(t *testing.T) {
+	path := writeXLSX(t, testWorkbookParts())
+	rows, err := loadBatch(path, "Tuning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []batchRow{
+		{row: 3, ref: 100, args: []string{"0.3"}},
+		{row: 4, ref: 101, args: []string{"true", "2k"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestLoadBatchAndReplay(t *testing.T) {
+	if _, err := loadBatch(writeTempFile(t, "b.csv", "100,1\n70000,1\n"), ""); err == nil ||
+		!strings.Contains(err.Error(), `:2: invalid reference "70000"`) {
+		t.Errorf("loadBatch error = %v", err)
+	}
+
+	steps, err := loadReplay(writeTempFile(t, "r.csv", "time,ref,value\n10,100,1\n10.5,101,2,3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []replayStep{
+		{row: 2, offset: 0, ref: 100, args: []string{"1"}},
+		{row: 3, offset: 500 * time.Millisecond, ref: 101, args: []string{"2", "3"}},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %+v, want %+v", steps, want)
+	}
+
+	if _, err := loadReplay(writeTempFile(t, "r.csv", "10,100,1\n9,100,2\n")); err == nil ||
+		!strings.Contains(err.Error(), "time order") {
+		t.Errorf("loadReplay accepted rows out of order: %v", err)
+	}
+	if _, err := loadReplay(writeTempFile(t, "r.csv", "10,100,1\nlater,100,2\n")); err == nil ||
+		!strings.Contains(err.Error(), `invalid timestamp "later"`) {
+		t.Errorf("loadReplay error = %v", err)
+	}
+}
+
+func TestWriteSourcesNeedSingleUnit(t *testing.T) {
+	clearEnv(t)
+	batch := writeTempFile(t, "b.csv", "100,1\n")
+	replay := writeTempFile(t, "r.csv", "10,100,1\n")
+	for _, args := range [][]string{
+		{"--from", batch},
+		{"--replay", replay},
+		{"--write-loop", "ramp:0:3"},
+		{"-t", "4", "-r", "1", "--values", "5"},
+	} {
+		m := &ModbusCLI{}
+		_, err := m.parseArgs(append(args, "-a", "1,2", "192.168.1.100"))
+		if err == nil || !strings.Contains(err.Error(), "writes can only address a single slave") {
+			t.Errorf("%q with two units: %v", args, err)
+		}
+		if _, err := m.parseArgs(append(args, "-a", "2", "192.168.1.100")); err != nil {
+			t.Errorf("%q with one unit: %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Write values may be Go templates evaluated each time the values are sent,
+// for scripted periodic writes of timestamps or values kept elsewhere:
+//
+//	{{ now.Unix }}                 seconds since 1970 (a time.Time method)
+//	{{ now.Format "1504" }}        the time of day as HHMM
+//	{{ env "SETPOINT" }}           an environment variable
+var writeTemplateFuncs = template.FuncMap{
+	"now": time.Now,
+	"env": os.Getenv,
+}
+
+// isWriteTemplate reports whether a write value is a template.
+func isWriteTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func parseWriteTemplate(s string) (*template.Template, error) {
+	return template.New("value").Funcs(writeTemplateFuncs).Option("missingkey=error").Parse(s)
+}
+
+// expandWriteTemplate evaluates a template write value.
+func expandWriteTemplate(s string) (string, error) {
+	tmpl, err := parseWriteTemplate(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid write value template %s: %v", s, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("write value template %s failed: %v", s, err)
+	}
+	value := strings.TrimSpace(b.String())
+	if !isWriteValue(value) || isWriteTemplate(value) {
+		return "", fmt.Errorf("write value template %s gave %q, not a number or boolean", s, value)
+	}
+	return value, nil
+}
+
+// expandWriteArgs evaluates the template write values, once per write so
+// every request of it and an --atomic read-back see the same values. The
+// returned function puts the templates back for the next write.
+func (m *ModbusCLI) expandWriteArgs() (restore func(), err error) {
+	args := m.config.WriteArgs
+	var expanded []string
+	for i, arg := range args {
+		if !isWriteTemplate(arg) {
+			continue
+		}
+		if expanded == nil {
+			expanded = append([]string(nil), args...)
+		}
+		if expanded[i], err = expandWriteTemplate(arg); err != nil {
+			return nil, err
+		}
+	}
+	if expanded == nil {
+		return func() {}, nil
+	}
+
+	m.config.WriteArgs = expanded
+	return func() { m.config.WriteArgs = args }, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Write values are kept as given and parsed once the data type is known, by
+// the parser of that type. Going through float64 would round integers above
+// 2^53 and couldn't accept hex or true/false.
+
+// engineeringSuffixes maps the suffixes accepted on write values to their multipliers.
+var engineeringSuffixes = map[byte]float64{
+	'k': 1e3,
+	'K': 1e3,
+	'M': 1e6,
+	'G': 1e9,
+}
+
+// parseWriteNumber parses a numeric write value exactly: a decimal number,
+// possibly in scientific notation (1.5e3) or with an engineering suffix (2k,
+// 3.3M), or an integer in hex (0x1F), octal (0o17) or binary (0b101).
+func parseWriteNumber(s string) (*big.Rat, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	if len(digits) > 2 && digits[0] == '0' && strings.ContainsRune("xXoObB", rune(digits[1])) {
+		n, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid write value: %s", s)
+		}
+		return new(big.Rat).SetInt(n), nil
+	}
+
+	multiplier := big.NewRat(1, 1)
+	if n := len(s); n > 1 {
+		if mult, ok := engineeringSuffixes[s[n-1]]; ok {
+			multiplier.SetFloat64(mult)
+			s = s[:n-1]
+		}
+	}
+	// big.Rat also takes fractions like 1/3, which aren't write values
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return nil, fmt.Errorf("invalid write value: %s", s)
+	}
+	return r.Mul(r, multiplier), nil
+}
+
+// parseWriteBool parses a coil write value: 1, 0, true, false, on or off.
+// With truncate any non-zero number turns the coil on.
+func parseWriteBool(s string, truncate bool) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "on":
+		return true, nil
+	case "0", "false", "off":
+		return false, nil
+	}
+	if r, err := parseWriteNumber(s); err == nil && truncate {
+		return r.Sign() != 0, nil
+	}
+	return false, fmt.Errorf("value %s is not a valid coil state (expected 0, 1, true, false, on or off)", s)
+}
+
+// parseWriteInt parses an integer write value for a register of bits bits,
+// accepting both the signed and the unsigned range, and returns it as two's
+// complement. With truncate a fractional part is dropped and out-of-range
+// values wrap around.
+func parseWriteInt(s string, bits uint, truncate bool) (uint64, error) {
+	r, err := parseWriteNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() && !truncate {
+		return 0, fmt.Errorf("value %s is not an integer (use --truncate to drop the fractional part)", s)
+	}
+	n := new(big.Int).Quo(r.Num(), r.Denom())
+
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), bits-1))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1))
+	if n.Cmp(min) < 0 || n.Cmp(max) > 0 {
+		if !truncate {
+			return 0, fmt.Errorf("value %s overflows a %d-bit register (valid range %s to %s)", s, bits, min, max)
+		}
+	}
+	// And on a negative big.Int works on its two's complement
+	return new(big.Int).And(n, max).Uint64(), nil
+}
+
+// parseWriteFloat32 parses a write value for a 32-bit float, rejecting values
+// beyond its range.
+func parseWriteFloat32(s string) (float32, error) {
+	r, err := parseWriteNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	if math.IsInf(f, 0) || math.Abs(f) > math.MaxFloat32 {
+		return 0, fmt.Errorf("value %s overflows a 32-bit float", s)
+	}
+	return float32(f), nil
+}
+
+// isWriteValue reports whether s parses as a write value of any data type,
+// or is a template for one.
+func isWriteValue(s string) bool {
+	if isWriteTemplate(s) {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "on", "off":
+		return true
+	}
+	_, err := parseWriteNumber(s)
+	return err == nil
+}
+
+// encodeWriteCoils parses the write values as coil states.
+func (m *ModbusCLI) encodeWriteCoils() ([]bool, error) {
+	coils := make([]bool, len(m.config.WriteArgs))
+	for i, arg := range m.config.WriteArgs {
+		coil, err := parseWriteBool(arg, m.config.Truncate)
+		if err != nil {
+			return nil, err
+		}
+		coils[i] = coil
+	}
+	return coils, nil
+}
+
+// encodeWriteRaw encodes the write values as they are journaled and read
+// back: coil states as 0 or 1 for table "0", registers otherwise.
+func (m *ModbusCLI) encodeWriteRaw(table string) ([]uint16, error) {
+	if table != "0" {
+		return m.encodeWriteRegisters()
+	}
+	coils, err := m.encodeWriteCoils()
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]uint16, len(coils))
+	for i, coil := range coils {
+		raw[i] = uint16(boolToInt(coil))
+	}
+	return raw, nil
+}
+
+// encodeWriteRegisters parses the write values with the parser of the data
+// type and lays them out in registers, 32-bit values in the word order reads
+// decode them with.
+func (m *ModbusCLI) encodeWriteRegisters() ([]uint16, error) {
+	registers := make([]uint16, 0, m.config.writeRegisterCount())
+	for _, arg := range m.config.WriteArgs {
+		var bits uint64
+		var err error
+		switch m.config.DataType {
+		case "4", "4:hex":
+			bits, err = parseWriteInt(arg, 16, m.config.Truncate)
+		case "4:int":
+			bits, err = parseWriteInt(arg, 32, m.config.Truncate)
+		case "4:float":
+			var f float32
+			f, err = parseWriteFloat32(arg)
+			bits = uint64(math.Float32bits(f))
+		case "4:fixed", "4:fixed32":
+			var raw int64
+			raw, err = encodeFixed(arg, m.config.FixedExponent, 16*m.config.wordsPerValue(), m.config.Truncate)
+			bits = uint64(raw)
+		default:
+			return nil, fmt.Errorf("write operations not supported for data type: %s", m.config.DataType)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if m.config.wordsPerValue() == 1 {
+			registers = append(registers, uint16(bits))
+		} else {
+			first, second := splitWords(uint32(bits), m.config.BigEndian)
+			registers = append(registers, first, second)
+		}
+	}
+	return registers, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseWriteNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // as a big.Rat, "" for an error
+	}{
+		{"123", "123"},
+		{"-789012", "-789012"},
+		{"+5", "5"},
+		{"3.14", "157/50"},
+		{"1.5e3", "1500"},
+		{"2k", "2000"},
+		{"2K", "2000"},
+		{"3.3M", "3300000"},
+		{"1G", "1000000000"},
+		{"-0.5k", "-500"},
+		{"0x1F", "31"},
+		{"0XdeadBEEF", "3735928559"},
+		{"-0x10", "-16"},
+		{"0o17", "15"},
+		{"0b101", "5"},
+		{"9007199254740993", "9007199254740993"},
+		{"18446744073709551617", "18446744073709551617"},
+		{"", ""},
+		{"k", ""},
+		{"1/3", ""},
+		{"0x", ""},
+		{"0xZZ", ""},
+		{"1.5x", ""},
+		{"NaN", ""},
+		{"Inf", ""},
+		{"true", ""},
+	}
+	for _, tt := range tests {
+		r, err := parseWriteNumber(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseWriteNumber(%q) = %s, want an error", tt.in, r.RatString())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseWriteNumber(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.RatString(); got != tt.want {
+			t.Errorf("parseWriteNumber(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseWriteBool(t *testing.T) {
+	tests := []struct {
+		in       string
+		truncate bool
+		want     bool
+		wantErr  bool
+	}{
+		{"1", false, true, false},
+		{"0", false, false, false},
+		{"TRUE", false, true, false},
+		{"off", false, false, false},
+		{"On", false, true, false},
+		{"2", false, false, true},
+		{"2", true, true, false},
+		{"0.0", true, false, false},
+		{"yes", true, false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWriteBool(tt.in, tt.truncate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseWriteBool(%q, %v) = %v, %v", tt.in, tt.truncate, got, err)
+		}
+	}
+}
+
+func TestParseWriteInt(t *testing.T) {
+	tests := []struct {
+		in       string
+		bits     uint
+		truncate bool
+		want     uint64
+		wantErr  bool
+	}{
+		{"65535", 16, false, 0xffff, false},
+		{"-1", 16, false, 0xffff, false},
+		{"-32768", 16, false, 0x8000, false},
+		{"-32769", 16, false, 0, true},
+		{"65536", 16, false, 0, true},
+		{"70k", 16, false, 0, true},
+		{"70k", 16, true, 70000 & 0xffff, false},
+		{"1.5", 16, false, 0, true},
+		{"1.5", 16, true, 1, false},
+		{"0xBEEF", 16, false, 0xbeef, false},
+		{"4294967295", 32, false, 0xffffffff, false},
+		{"-789012", 32, false, uint64(uint32(0xfff3f5ec)), false},
+		{"-2147483648", 32, false, 0x80000000, false},
+		{"4294967296", 32, false, 0, true},
+		{"0xDEADBEEF", 32, false, 0xdeadbeef, false},
+		{"abc", 32, true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWriteInt(tt.in, tt.bits, tt.truncate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseWriteInt(%q, %d, %v) = %#x, %v", tt.in, tt.bits, tt.truncate, got, err)
+		}
+	}
+}
+
+func TestParseWriteFloat32(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float32
+		wantErr bool
+	}{
+		{"3.14", 3.14, false},
+		{"-2.71", -2.71, false},
+		{"1.5e3", 1500, false},
+		{"2k", 2000, false},
+		{"0x10", 16, false},
+		{"3.4e38", 3.4e38, false},
+		{"3.5e38", 0, true},
+		{"-1e39", 0, true},
+		{"1e400", 0, true},
+		{"NaN", 0, true},
+		{"inf", 0, true},
+		{"pi", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWriteFloat32(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseWriteFloat32(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestIsWriteValue(t *testing.T) {
+	for _, s := range []string{"1", "-1", "1.5e3", "2k", "0x1F", "true", "OFF", "{{ now.Unix }}"} {
+		if !isWriteValue(s) {
+			t.Errorf("isWriteValue(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"", "192.168.1.100", "/dev/ttyUSB0", "-t", "yes", "NaN", "1/2"} {
+		if isWriteValue(s) {
+			t.Errorf("isWriteValue(%q) = true", s)
+		}
+	}
+}
+
+func TestEncodeWriteRegisters(t *testing.T) {
+	tests := []struct {
+		dataType  string
+		bigEndian bool
+		args      []string
+		want      []uint16
+	}{
+		{"4", true, []string{"1", "-1", "0x1234"}, []uint16{1, 0xffff, 0x1234}},
+		{"4:int", true, []string{"4294967295", "0x12345678"}, []uint16{0xffff, 0xffff, 0x1234, 0x5678}},
+		{"4:int", false, []string{"0x12345678"}, []uint16{0x5678, 0x1234}},
+		{"4:float", true, []string{"1.5"}, []uint16{0x3fc0, 0x0000}},
+	}
+	for _, tt := range tests {
+		m := &ModbusCLI{config: &Config{DataType: tt.dataType, BigEndian: tt.bigEndian, WriteArgs: tt.args}}
+		got, err := m.encodeWriteRegisters()
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.dataType, tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q = %#x, want %#x", tt.dataType, tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestWriteLoopCSVKeepsValues checks that csv: patterns hand their values to
+// the data type's parser as written, so large integers aren't rounded.
+func TestWriteLoopCSVKeepsValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.csv")
+	if err := os.WriteFile(path, []byte("time,value\n0,9007199254740993\n1,0xDEADBEEF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := parseWritePattern("csv:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"9007199254740993", "0xDEADBEEF", "9007199254740993"} {
+		if got := p.next(); got != want {
+			t.Errorf("next() = %s, want %s", got, want)
+		}
+	}
+}
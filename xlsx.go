@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// An .xlsx workbook is a zip archive of XML parts. Only what is needed to
+// read the cell values of one sheet is decoded: the sheet list, the
+// relationships locating each sheet's part, and the shared strings.
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string, either plain or in rich text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSXSheet returns the cell values of the named sheet, or of the first
+// sheet when name is empty, row by row. Numbers are given as Excel shows
+// them, with at most 15 significant digits.
+func readXLSXSheet(file, name string) ([][]string, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer archive.Close()
+
+	parts := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		parts[f.Name] = f
+	}
+	readPart := func(name string, v interface{}) error {
+		f, ok := parts[name]
+		if !ok {
+			return fmt.Errorf("%s is not an Excel workbook: no %s", file, name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if err := xml.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %s: %v", file, name, err)
+		}
+		return nil
+	}
+
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if err := readPart("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := readPart("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	var strs xlsxSharedStrings
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := readPart("xl/sharedStrings.xml", &strs); err != nil {
+			return nil, err
+		}
+	}
+
+	var id string
+	var names []string
+	for _, sheet := range workbook.Sheets {
+		names = append(names, sheet.Name)
+		if id == "" && (name == "" || sheet.Name == name) {
+			id = sheet.ID
+		}
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%s has no sheet %q (sheets: %s)", file, name, strings.Join(names, ", "))
+	}
+	target := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == id {
+			target = rel.Target
+		}
+	}
+	if strings.HasPrefix(target, "/") {
+		target = target[1:]
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var sheet xlsxSheet
+	if err := readPart(target, &sheet); err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	for _, row := range sheet.Rows {
+		// Rows and cells may be left out when empty
+		for len(records) < row.Index-1 {
+			records = append(records, nil)
+		}
+		var record []string
+		for _, cell := range row.Cells {
+			col := xlsxColumn(cell.Ref)
+			if col < 0 {
+				col = len(record)
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				i, err := strconv.Atoi(cell.Value)
+				if err != nil || i < 0 || i >= len(strs.Items) {
+					return nil, fmt.Errorf("%s: cell %s refers to a missing string", file, cell.Ref)
+				}
+				record[col] = strs.Items[i].String()
+			case "inlineStr":
+				record[col] = cell.Inline.String()
+			case "b":
+				record[col] = strconv.FormatBool(cell.Value == "1")
+			case "", "n":
+				record[col] = xlsxNumber(cell.Value)
+			default:
+				record[col] = cell.Value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// xlsxColumn returns the zero-based column of a cell reference like "B3",
+// or -1 if there is none.
+func xlsxColumn(ref string) int {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// xlsxNumber rounds a stored number to the 15 significant digits Excel works
+// with, so a setpoint typed as 0.3 isn't written as 0.30000000000000004.
+func xlsxNumber(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeXLSX writes a workbook made of the given parts to a temporary file.
+func writeXLSX(t *testing.T, parts map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.xlsx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const (
+	testWorkbook = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" r:id="rId1"/><sheet name="Tuning" r:id="rId2"/></sheets></workbook>`
+	testRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`
+	testSharedStrings = `<sst><si><t>REF</t></si><si><r><t>VAL</t></r><r><t>UE</t></r></si></sst>`
+	testSheet1        = `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>summary</t></is></c></row></sheetData></worksheet>`
+	testSheet2        = `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3"><v>100</v></c><c r="B3" t="n"><v>0.30000000000000004</v></c></row>
+<row r="4"><c r="A4"><v>101</v></c><c r="B4" t="b"><v>1</v></c><c r="C4" t="str"><v>2k</v></c></row>
+</sheetData></worksheet>`
+)
+
+func testWorkbookParts() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   testSheet1,
+		"xl/worksheets/sheet2.xml":   testSheet2,
+	}
+}
+
+func TestReadXLSXSheet(t *testing.T) {
+	path := writeXLSX(t, testWorkbookParts())
+
+	records, err := readXLSXSheet(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"summary"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("first sheet = %q, want %q", records, want)
+	}
+
+	records, err = readXLSXSheet(path, "Tuning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"REF", "", "VALUE"},
+		nil,
+		{"100", "0.3"},
+		{"101", "true", "2k"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Tuning = %q, want %q", records, want)
+	}
+}
+
+func TestReadXLSXSheetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		parts func(map[string]string)
+		want  string
+	}{
+		{"missing sheet", "Setpoints", func(map[string]string) {}, `has no sheet "Setpoints" (sheets: Summary, Tuning)`},
+		{"no workbook part", "", func(p map[string]string) { delete(p, "xl/workbook.xml") }, "is not an Excel workbook"},
+		{"missing shared string", "Tuning", func(p map[string]string) {
+			p["xl/sharedStrings.xml"] = `<sst><si><t>REF</t></si></sst>`
+		}, "cell C1 refers to a missing string"},
+		{"broken XML", "", func(p map[string]string) { p["xl/worksheets/sheet1.xml"] = "<worksheet><sheetData><row>" }, "sheet1.xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := testWorkbookParts()
+			tt.parts(parts)
+			_, err := readXLSXSheet(writeXLSX(t, parts), tt.sheet)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "plain.xlsx")
+	if err := os.WriteFile(path, []byte("REF,VALUE\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readXLSXSheet(path, ""); err == nil {
+		t.Errorf("read a CSV file as a workbook")
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "B3": 1, "Z9": 25, "AA1": 26, "AZ2": 51, "": -1, "12": -1} {
+		if got := xlsxColumn(ref); got != want {
+			t.Errorf("xlsxColumn(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestXLSXNumber(t *testing.T) {
+	for value, want := range map[string]string{
+		"0.30000000000000004": "0.3",
+		"100":                 "100",
+		"1.5E-3":              "0.0015",
+		"123456789012345678":  "123456789012346000",
+		"abc":                 "abc",
+	} {
+		if got := xlsxNumber(value); got != want {
+			t.Errorf("xlsxNumber(%q) = %q, want %q", value, got, want)
+		}
+	}
+}